@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/infra/database"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 	"github.com/gharsallahmoez/palindrome/server/http"
 	logger "github.com/sirupsen/logrus"
 	"os"
 )
 
 // stop channel is used to stop the server
-var stop = make(chan os.Signal)
+var stop = make(chan os.Signal, 1)
 
 func main() {
 	conf := config.New()
@@ -18,25 +19,46 @@ func main() {
 	logger.Debug("Configuration loaded successfully")
 	logger.Debug(fmt.Sprintf("Starting messages service on port %v", conf.Server.Port))
 
+	if _, err := palindrome.ParseMode(conf.Palindrome.Mode); err != nil {
+		logger.Fatalf("invalid palindrome configuration : %v", err)
+	}
+
 	db, err := database.Create(conf.Database)
 	if err != nil {
 		logger.Fatalf("failed to create the database : %v", err)
 	}
 
+	// purge the expired deleted messages in the background
+	purger := database.NewPurger(db, conf.Trash)
+	purger.Start()
+
+	// sweep the expired messages in the background
+	sweeper := database.NewSweeper(db, conf.Expiry)
+	sweeper.Start()
+
 	// create the service
-	messageService := http.NewMessageService(db)
+	messageService := http.NewMessageService(db, conf)
 
 	srv := http.NewRunner(&conf.Server, messageService)
 
 	// register services
 	srv.RegisterServices()
 
-	if err := srv.Start(); err != nil {
-		logger.Panicf("failed to start the server : %v", err)
-	}
 	// schedule the stop action to wait for an os signal
+	stopped := make(chan struct{})
 	go func() {
 		srv.Stop(stop)
-		os.Exit(0)
+		close(stopped)
 	}()
+	if err := srv.Start(); err != nil {
+		logger.Panicf("failed to start the server : %v", err)
+	}
+	<-stopped
+
+	// stop the background workers, then close the database they use
+	purger.Stop()
+	sweeper.Stop()
+	if err := db.Close(); err != nil {
+		logger.Errorf("failed to close the database : %v", err)
+	}
 }
@@ -16,18 +16,78 @@ func TestConfig(t *testing.T) {
 		conf := config.New()
 		require.Equal(t, "localhost", conf.Server.Host)
 		require.Equal(t, "8080", conf.Server.Port)
+		require.False(t, conf.Server.RequireIfMatch)
+		require.Equal(t, 24*time.Hour, conf.Server.IdempotencyKeyTTL)
+		require.Equal(t, time.Minute, conf.Server.IdempotencyClaimTimeout)
+		require.Equal(t, 1<<20, conf.Server.MaxBodyBytes)
+		require.Equal(t, 10000, conf.Server.MaxContentLength)
+		require.Equal(t, time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC), conf.Server.UnversionedDeprecation)
+		require.Equal(t, time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC), conf.Server.UnversionedSunset)
 		require.Equal(t, "in-memory", conf.Database.Type)
+		require.Equal(t, "", conf.Database.DSN)
+		require.Equal(t, "messages.db", conf.Database.Path)
+		require.Equal(t, 10, conf.Database.MaxOpenConns)
+		require.Equal(t, 5, conf.Database.MaxIdleConns)
+		require.Equal(t, 30*time.Minute, conf.Database.ConnMaxLifetime)
+		require.Equal(t, 5*time.Minute, conf.Database.ConnMaxIdleTime)
+		require.Equal(t, "", conf.Database.WALDir)
+		require.Equal(t, "always", conf.Database.FsyncPolicy)
+		require.Equal(t, time.Second, conf.Database.FsyncInterval)
+		require.Equal(t, 5*time.Minute, conf.Database.SnapshotInterval)
+		require.Equal(t, "", conf.Database.Dedupe)
+		require.Equal(t, "alphanumeric", conf.Palindrome.Mode)
+		require.Equal(t, 720*time.Hour, conf.Trash.Retention)
+		require.Equal(t, time.Hour, conf.Trash.PurgeInterval)
+		require.Equal(t, time.Minute, conf.Expiry.SweepInterval)
 	})
 
 	// Test with custom config.
 	t.Run("server config set from env", func(t *testing.T) {
 		t.Setenv("SERVER_HOST", "1.1.1.1")
 		t.Setenv("SERVER_PORT", "8080")
+		t.Setenv("SERVER_REQUIRE_IF_MATCH", "true")
+		t.Setenv("SERVER_IDEMPOTENCY_KEY_TTL", "1h")
+		t.Setenv("SERVER_IDEMPOTENCY_CLAIM_TIMEOUT", "30s")
+		t.Setenv("SERVER_MAX_BODY_BYTES", "4096")
+		t.Setenv("SERVER_MAX_CONTENT_LENGTH", "280")
+		t.Setenv("SERVER_UNVERSIONED_DEPRECATION", "2026-01-01T00:00:00Z")
+		t.Setenv("SERVER_UNVERSIONED_SUNSET", "not a date")
 		t.Setenv("DATABASE_TYPE", "POSTGRES")
+		t.Setenv("DATABASE_DSN", "postgres://localhost:5432/messages")
+		t.Setenv("DATABASE_PATH", "/var/lib/messages/messages.db")
+		t.Setenv("DATABASE_MAX_OPEN_CONNS", "20")
+		t.Setenv("DATABASE_MAX_IDLE_CONNS", "not a number")
+		t.Setenv("DATABASE_CONN_MAX_LIFETIME", "1h")
+		t.Setenv("DATABASE_WAL_DIR", "/var/lib/messages")
+		t.Setenv("DATABASE_FSYNC_POLICY", "interval")
+		t.Setenv("DATABASE_SNAPSHOT_INTERVAL", "10m")
+		t.Setenv("DATABASE_DEDUPE", "reject")
+		t.Setenv("PALINDROME_MODE", "word")
+		t.Setenv("TRASH_RETENTION", "168h")
+		t.Setenv("EXPIRY_SWEEP_INTERVAL", "30s")
 		conf := config.New()
 		require.Equal(t, "1.1.1.1", conf.Server.Host)
 		require.Equal(t, "8080", conf.Server.Port)
+		require.True(t, conf.Server.RequireIfMatch)
+		require.Equal(t, time.Hour, conf.Server.IdempotencyKeyTTL)
+		require.Equal(t, 30*time.Second, conf.Server.IdempotencyClaimTimeout)
+		require.Equal(t, 4096, conf.Server.MaxBodyBytes)
+		require.Equal(t, 280, conf.Server.MaxContentLength)
+		require.Equal(t, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), conf.Server.UnversionedDeprecation)
+		require.Equal(t, time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC), conf.Server.UnversionedSunset)
 		require.Equal(t, "POSTGRES", conf.Database.Type)
+		require.Equal(t, "postgres://localhost:5432/messages", conf.Database.DSN)
+		require.Equal(t, "/var/lib/messages/messages.db", conf.Database.Path)
+		require.Equal(t, 20, conf.Database.MaxOpenConns)
+		require.Equal(t, 5, conf.Database.MaxIdleConns)
+		require.Equal(t, time.Hour, conf.Database.ConnMaxLifetime)
+		require.Equal(t, "/var/lib/messages", conf.Database.WALDir)
+		require.Equal(t, "interval", conf.Database.FsyncPolicy)
+		require.Equal(t, 10*time.Minute, conf.Database.SnapshotInterval)
+		require.Equal(t, "reject", conf.Database.Dedupe)
+		require.Equal(t, "word", conf.Palindrome.Mode)
+		require.Equal(t, 168*time.Hour, conf.Trash.Retention)
+		require.Equal(t, 30*time.Second, conf.Expiry.SweepInterval)
 	})
 }
 
@@ -0,0 +1,97 @@
+// Package diff computes the differences between two texts.
+package diff
+
+// Op is the operation of an edit.
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Edit is a run of consecutive runes sharing the same operation.
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// maxCells bounds the size of the quadratic table comparing the texts.
+const maxCells = 1 << 22
+
+// Runes returns the shortest edits turning a into b, rune by rune. The common prefix and suffix
+// are kept as is, and the rest is reported as replaced when too long to be compared.
+func Runes(a, b string) []Edit {
+	from, to := []rune(a), []rune(b)
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	var edits edits
+	edits.add(Equal, from[:prefix]...)
+	middleFrom, middleTo := from[prefix:len(from)-suffix], to[prefix:len(to)-suffix]
+	if len(middleFrom)*len(middleTo) > maxCells {
+		edits.add(Delete, middleFrom...)
+		edits.add(Insert, middleTo...)
+	} else {
+		edits.compare(middleFrom, middleTo)
+	}
+	edits.add(Equal, from[len(from)-suffix:]...)
+	return edits
+}
+
+// edits merges the consecutive runes sharing the same operation.
+type edits []Edit
+
+// add appends runes with the given operation.
+func (e *edits) add(op Op, runes ...rune) {
+	if len(runes) == 0 {
+		return
+	}
+	if n := len(*e); n > 0 && (*e)[n-1].Op == op {
+		(*e)[n-1].Text += string(runes)
+		return
+	}
+	*e = append(*e, Edit{Op: op, Text: string(runes)})
+}
+
+// compare appends the edits turning from into to, following their longest common subsequence.
+func (e *edits) compare(from, to []rune) {
+	// lcs[i*width+j] is the length of the longest common subsequence of from[i:] and to[j:].
+	width := len(to) + 1
+	lcs := make([]int32, (len(from)+1)*width)
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			switch {
+			case from[i] == to[j]:
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+				lcs[i*width+j] = lcs[(i+1)*width+j]
+			default:
+				lcs[i*width+j] = lcs[i*width+j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			e.add(Equal, from[i])
+			i, j = i+1, j+1
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			e.add(Delete, from[i])
+			i++
+		default:
+			e.add(Insert, to[j])
+			j++
+		}
+	}
+	e.add(Delete, from[i:]...)
+	e.add(Insert, to[j:]...)
+}
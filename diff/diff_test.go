@@ -0,0 +1,56 @@
+package diff_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunes(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected []diff.Edit
+	}{
+		{"BothEmpty", "", "", nil},
+		{"Identical", "kayak", "kayak", []diff.Edit{{Op: diff.Equal, Text: "kayak"}}},
+		{"Inserted", "", "kayak", []diff.Edit{{Op: diff.Insert, Text: "kayak"}}},
+		{"Deleted", "kayak", "", []diff.Edit{{Op: diff.Delete, Text: "kayak"}}},
+		{
+			"Substituted", "race car", "race cat",
+			[]diff.Edit{{Op: diff.Equal, Text: "race ca"}, {Op: diff.Delete, Text: "r"}, {Op: diff.Insert, Text: "t"}},
+		},
+		{
+			"InsertedInTheMiddle", "a plan", "a man a plan",
+			[]diff.Edit{{Op: diff.Equal, Text: "a "}, {Op: diff.Insert, Text: "man a "}, {Op: diff.Equal, Text: "plan"}},
+		},
+		{
+			"Interleaved", "kayak", "kyaak",
+			[]diff.Edit{
+				{Op: diff.Equal, Text: "k"}, {Op: diff.Delete, Text: "a"}, {Op: diff.Equal, Text: "y"},
+				{Op: diff.Insert, Text: "a"}, {Op: diff.Equal, Text: "ak"},
+			},
+		},
+		{
+			"Runes", "été", "étés",
+			[]diff.Edit{{Op: diff.Equal, Text: "été"}, {Op: diff.Insert, Text: "s"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, diff.Runes(tt.a, tt.b))
+		})
+	}
+
+	t.Run("TooLongToCompare", func(t *testing.T) {
+		a, b := "<"+strings.Repeat("ab", 2500)+">", "<"+strings.Repeat("ba", 2500)+">"
+		assert.Equal(t, []diff.Edit{
+			{Op: diff.Equal, Text: "<"},
+			{Op: diff.Delete, Text: strings.Repeat("ab", 2500)},
+			{Op: diff.Insert, Text: strings.Repeat("ba", 2500)},
+			{Op: diff.Equal, Text: ">"},
+		}, diff.Runes(a, b))
+	})
+}
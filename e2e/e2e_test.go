@@ -12,7 +12,7 @@ import (
 	"testing"
 )
 
-var host = "http://127.0.0.1:8080"
+var host = "http://127.0.0.1:8080/v1"
 
 func TestCreateMessage(t *testing.T) {
 	url := host + "/messages"
@@ -97,6 +97,7 @@ func TestRetrieveMessage(t *testing.T) {
 	assert.Equal(t, createdMessage.ID, retrievedMessage.ID, "Retrieved ID should match created ID")
 	assert.Equal(t, createdMessage.Content, retrievedMessage.Content, "Retrieved content should match created content")
 	assert.Equal(t, createdMessage.IsPalindrome, retrievedMessage.IsPalindrome, "Retrieved palindrome status should match")
+	assert.True(t, createdMessage.CreatedAt.Equal(retrievedMessage.CreatedAt), "Retrieved creation time should match")
 }
 
 func TestUpdateMessage(t *testing.T) {
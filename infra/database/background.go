@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// background runs a maintenance task of the database periodically until stopped.
+type background struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newBackground creates a stopped background task.
+func newBackground() background {
+	return background{stop: make(chan struct{})}
+}
+
+// run runs task every period in a goroutine, logging its failures.
+func (b *background) run(period time.Duration, name string, task func(ctx context.Context) error) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := task(context.Background()); err != nil {
+					logrus.Errorf("failed to %s: %v", name, err)
+				}
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the task and waits for its current run.
+func (b *background) Stop() {
+	close(b.stop)
+	b.wg.Wait()
+}
@@ -5,28 +5,98 @@ import (
 	"fmt"
 	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/infra/database/in-memory"
+	"github.com/gharsallahmoez/palindrome/infra/database/postgres"
+	"github.com/gharsallahmoez/palindrome/infra/database/sqlite"
 	"github.com/gharsallahmoez/palindrome/model"
+	"time"
 )
 
 // Database represents an interface for interacting with the messages data storage.
 type Database interface {
-	// SaveMessage saves a message to the database.
+	// SaveMessage saves a message to the database and sets its content hash.
+	// With deduplication, a message with the content hash of a stored message is not saved: the stored message
+	// is returned with ErrDuplicateMessage.
 	SaveMessage(message model.Message, ctx context.Context) (model.Message, error)
-	// GetMessage retrieves a message from the database.
+	// GetMessage retrieves a message from the database, the expired messages are not found.
 	GetMessage(id string, ctx context.Context) (model.Message, error)
-	// UpdateMessage updates a message in the database.
-	UpdateMessage(id string, content string, isPalindrome bool, ctx context.Context) (model.Message, error)
-	// DeleteMessage deletes a message from the database.
-	DeleteMessage(id string, ctx context.Context) error
-	// ListMessages retrieves all messages from the database.
-	ListMessages(ctx context.Context) ([]model.Message, error)
+	// UpdateMessage updates the message identified by message.ID in the database, sets its content hash and increments its version,
+	// keeping its expiry unless message.ExpiresAt is set.
+	// Unless message.Version is 0, the message is only updated at this version, ErrVersionMismatch is returned otherwise.
+	UpdateMessage(message model.Message, ctx context.Context) (model.Message, error)
+	// PatchMessage atomically replaces the message identified by id with the result of patch on its current state,
+	// sets its content hash and increments its version. Unlike UpdateMessage, the expiry of the result is stored
+	// as is, so that a patch can clear it. The error of patch fails the patch.
+	// Unless version is 0, the message is only patched at this version, ErrVersionMismatch is returned otherwise.
+	// patch may be called again when the message is concurrently updated, it should not have side effects.
+	PatchMessage(id string, version int64, patch func(model.Message) (model.Message, error), ctx context.Context) (model.Message, error)
+	// DeleteMessage moves a message to the trash, where it is hidden from the other methods.
+	// Unless version is 0, the message is only deleted at this version, ErrVersionMismatch is returned otherwise.
+	DeleteMessage(id string, version int64, ctx context.Context) error
+	// SaveMessages saves several messages at once like SaveMessage, returning the results in the order of the messages.
+	// A duplicate message fails its item only, any other error fails the whole batch.
+	SaveMessages(messages []model.Message, ctx context.Context) ([]model.BatchResult, error)
+	// PatchMessages patches several messages at once like PatchMessage, in order and atomically, returning the results
+	// in the order of the patches. A missing message or a version mismatch fails its item only,
+	// any other error, that of a patch included, fails the whole batch.
+	PatchMessages(patches []model.Patch, ctx context.Context) ([]model.BatchResult, error)
+	// DeleteMessages moves several messages to the trash at once like DeleteMessage, returning the error of every item
+	// in the order of the deletions. A missing message or a version mismatch fails its item only,
+	// any other error fails the whole batch.
+	DeleteMessages(deletions []model.Deletion, ctx context.Context) ([]error, error)
+	// ListMessages retrieves a page of the messages matching the query from the database.
+	ListMessages(query model.ListQuery, ctx context.Context) (model.MessagePage, error)
+	// SearchMessages retrieves the live messages matching every term of the query, most relevant first.
+	SearchMessages(query model.SearchQuery, ctx context.Context) ([]model.SearchHit, error)
+	// MessageStats aggregates the live messages created in the range of the query.
+	MessageStats(query model.StatsQuery, ctx context.Context) (model.Stats, error)
+	// DeleteExpiredMessages permanently removes the messages expired at the given time and returns their number.
+	DeleteExpiredMessages(now time.Time, ctx context.Context) (int64, error)
+	// RestoreMessage moves a message out of the trash.
+	RestoreMessage(id string, ctx context.Context) (model.Message, error)
+	// PurgeMessages permanently removes the messages moved to the trash before the given time
+	// and returns their number.
+	PurgeMessages(before time.Time, ctx context.Context) (int64, error)
+	// ListRevisions retrieves the revisions of a message, oldest first.
+	ListRevisions(id string, ctx context.Context) ([]model.Revision, error)
+	// GetRevision retrieves the revision of a message at the given version.
+	GetRevision(id string, number int64, ctx context.Context) (model.Revision, error)
+	// ClaimIdempotencyKey stores a new idempotency key without response. If the key is already stored and not
+	// claimable, the stored key is returned with ErrIdempotencyKeyClaimed.
+	ClaimIdempotencyKey(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error)
+	// CompleteIdempotencyKey stores the response of a claimed idempotency key.
+	CompleteIdempotencyKey(key model.IdempotencyKey, ctx context.Context) error
+	// ReleaseIdempotencyKey removes a claimed idempotency key, so that the request can be retried.
+	ReleaseIdempotencyKey(key string, ctx context.Context) error
+	// DeleteExpiredIdempotencyKeys removes the idempotency keys expired at the given time and returns their number.
+	DeleteExpiredIdempotencyKeys(now time.Time, ctx context.Context) (int64, error)
+	// Close releases the database, flushing its pending writes.
+	Close() error
 }
 
+// Deduplication modes of the messages with the same content hash.
+const (
+	// DedupeOff saves every message.
+	DedupeOff = ""
+	// DedupeReturn returns the stored message instead of saving a duplicate.
+	DedupeReturn = "return"
+	// DedupeReject rejects the duplicates.
+	DedupeReject = "reject"
+)
+
 // Create creates a new instance of a database based on the provided configuration.
 func Create(conf config.Database) (Database, error) {
+	switch conf.Dedupe {
+	case DedupeOff, DedupeReturn, DedupeReject:
+	default:
+		return nil, fmt.Errorf("%s is an unknown deduplication mode", conf.Dedupe)
+	}
 	switch conf.Type {
 	case "in-memory":
-		return in_memory.NewRepo(), nil
+		return in_memory.Open(conf)
+	case "postgres":
+		return postgres.NewRepo(conf)
+	case "sqlite":
+		return sqlite.NewRepo(conf)
 	default:
 		return nil, fmt.Errorf(fmt.Sprintf("%s is an unknown database type", conf.Type))
 	}
@@ -1,13 +1,21 @@
 package database_test
 
 import (
+	"context"
 	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/infra/database"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 // TestCreate tests Create function.
 func TestCreate(t *testing.T) {
+	dir := t.TempDir()
 	// test table
 	tt := []struct {
 		name     string
@@ -21,6 +29,48 @@ func TestCreate(t *testing.T) {
 			},
 			hasError: false,
 		},
+		{
+			name: "valid persisted in-memory database config",
+			db: config.Database{
+				Type:        "in-memory",
+				WALDir:      filepath.Join(dir, "wal"),
+				FsyncPolicy: "always",
+			},
+			hasError: false,
+		},
+		{
+			name: "unknown in-memory fsync policy",
+			db: config.Database{
+				Type:        "in-memory",
+				WALDir:      filepath.Join(dir, "wal"),
+				FsyncPolicy: "sometimes",
+			},
+			hasError: true,
+		},
+		{
+			name: "valid sqlite database config",
+			db: config.Database{
+				Type: "sqlite",
+				Path: filepath.Join(dir, "messages.db"),
+			},
+			hasError: false,
+		},
+		{
+			name: "invalid sqlite database path",
+			db: config.Database{
+				Type: "sqlite",
+				Path: filepath.Join(dir, "missing", "messages.db"),
+			},
+			hasError: true,
+		},
+		{
+			name: "unreachable postgres database config",
+			db: config.Database{
+				Type: "postgres",
+				DSN:  "postgres://localhost:1/messages?sslmode=disable&connect_timeout=1",
+			},
+			hasError: true,
+		},
 		{
 			name: "unsupported database config",
 			db: config.Database{
@@ -41,3 +91,41 @@ func TestCreate(t *testing.T) {
 		})
 	}
 }
+
+// TestExpiredMessageRevisions tests that every database hides the revisions of the expired messages.
+func TestExpiredMessageRevisions(t *testing.T) {
+	backends := map[string]config.Database{
+		"in-memory": {Type: "in-memory"},
+		"sqlite":    {Type: "sqlite", Path: filepath.Join(t.TempDir(), "messages.db")},
+	}
+	if dsn, ok := os.LookupEnv("POSTGRES_DSN"); ok {
+		backends["postgres"] = config.Database{Type: "postgres", DSN: dsn}
+	}
+	for name, conf := range backends {
+		t.Run(name, func(t *testing.T) {
+			db, err := database.Create(conf)
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+
+			// Save an expired message and a live one
+			expiresAt := time.Now().Add(-time.Second)
+			expired := model.NewMessage("expired message", false)
+			expired.ExpiresAt = &expiresAt
+			live := model.NewMessage("live message", false)
+			for _, message := range []model.Message{expired, live} {
+				_, err = db.SaveMessage(message, context.Background())
+				require.NoError(t, err)
+			}
+
+			_, err = db.ListRevisions(expired.ID, context.Background())
+			assert.ErrorIs(t, err, model.ErrMessageNotFound)
+			_, err = db.GetRevision(expired.ID, 1, context.Background())
+			assert.ErrorIs(t, err, model.ErrMessageNotFound)
+			revisions, err := db.ListRevisions(live.ID, context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, revisions, 1)
+			_, err = db.GetRevision(live.ID, 1, context.Background())
+			assert.NoError(t, err)
+		})
+	}
+}
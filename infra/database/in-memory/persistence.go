@@ -0,0 +1,454 @@
+package in_memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+)
+
+// Fsync policies of the write-ahead log.
+const (
+	// FsyncAlways flushes the log to disk before acknowledging every mutation.
+	FsyncAlways = "always"
+	// FsyncInterval flushes the log to disk periodically, a crash may lose the last interval.
+	FsyncInterval = "interval"
+	// FsyncNever leaves the flushes to the operating system.
+	FsyncNever = "never"
+)
+
+// Operations recorded in the write-ahead log. Replaying them is idempotent.
+const (
+	opPut       = "put"
+	opDelete    = "delete"
+	opPutKey    = "put_key"
+	opDeleteKey = "delete_key"
+	opBatch     = "batch"
+)
+
+// entry is a mutation recorded in the write-ahead log, one JSON document per line.
+type entry struct {
+	Op      string                `json:"op"`
+	ID      string                `json:"id,omitempty"`
+	Message *model.Message        `json:"message,omitempty"`
+	Key     *model.IdempotencyKey `json:"key,omitempty"`
+	// Entries are the mutations of a batch write, logged as a single entry so that they are replayed all or none.
+	Entries []entry `json:"entries,omitempty"`
+}
+
+// pendingBatch holds the mutations of a batch write until they are logged, and the state they replace.
+type pendingBatch struct {
+	entries []entry
+	// previous holds the state of the messages before their first mutation in the batch, by ID.
+	previous map[string]previousMessage
+}
+
+// previousMessage is the state of a message before a batch write.
+type previousMessage struct {
+	message   model.Message
+	exists    bool
+	revisions []model.Revision
+}
+
+// snapshot is the compacted state of the repository.
+type snapshot struct {
+	Messages        []model.Message        `json:"messages"`
+	Revisions       []model.Revision       `json:"revisions,omitempty"`
+	IdempotencyKeys []model.IdempotencyKey `json:"idempotency_keys,omitempty"`
+}
+
+// persistence appends the mutations of a repository to a write-ahead log and compacts it into snapshots.
+type persistence struct {
+	dir    string
+	wal    *os.File
+	policy string
+	// failed is the error of an append which could not be removed from the log, rejecting the later ones.
+	failed error
+	// snapshots serializes the snapshots, which are written without the lock of the repository.
+	snapshots sync.Mutex
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// Open creates a repository persisted in conf.WALDir: the latest snapshot and the write-ahead log
+// written since are replayed first, then every mutation is logged before being applied.
+// The repository is not persisted when conf.WALDir is empty.
+func Open(conf config.Database) (*Repo, error) {
+	repo := NewRepo()
+	repo.dedupe = conf.Dedupe != ""
+	if conf.WALDir == "" {
+		return repo, nil
+	}
+	switch conf.FsyncPolicy {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("%s is an unknown fsync policy", conf.FsyncPolicy)
+	}
+	if err := os.MkdirAll(conf.WALDir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := repo.loadSnapshot(filepath.Join(conf.WALDir, snapshotFileName)); err != nil {
+		return nil, fmt.Errorf("failed to load the snapshot: %w", err)
+	}
+	wal, err := os.OpenFile(filepath.Join(conf.WALDir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.replay(wal); err != nil {
+		_ = wal.Close()
+		return nil, fmt.Errorf("failed to replay the write-ahead log: %w", err)
+	}
+
+	repo.persistence = &persistence{dir: conf.WALDir, wal: wal, policy: conf.FsyncPolicy, stop: make(chan struct{})}
+	if conf.FsyncPolicy == FsyncInterval {
+		repo.every(conf.FsyncInterval, repo.sync)
+	}
+	repo.every(conf.SnapshotInterval, repo.Snapshot)
+	return repo, nil
+}
+
+// Close stops the background tasks and flushes the write-ahead log to disk.
+func (r *Repo) Close() error {
+	if r.persistence == nil {
+		return nil
+	}
+	close(r.persistence.stop)
+	r.persistence.wg.Wait()
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if err := r.persistence.wal.Sync(); err != nil {
+		return err
+	}
+	return r.persistence.wal.Close()
+}
+
+// Snapshot writes the state of the repository to a new snapshot and removes the entries it holds from the
+// write-ahead log. The state is copied under the lock but written without it, so that the mutations are not
+// stalled meanwhile: they are logged after the entries of the snapshot and kept in the log.
+func (r *Repo) Snapshot() error {
+	if r.persistence == nil {
+		return nil
+	}
+	r.persistence.snapshots.Lock()
+	defer r.persistence.snapshots.Unlock()
+
+	r.mx.Lock()
+	state := snapshot{Messages: make([]model.Message, 0, len(r.messages))}
+	for _, message := range r.messages {
+		state.Messages = append(state.Messages, message)
+		state.Revisions = append(state.Revisions, r.revisions[message.ID]...)
+	}
+	for _, key := range r.idempotencyKeys {
+		state.IdempotencyKeys = append(state.IdempotencyKeys, key)
+	}
+	offset, err := r.persistence.wal.Seek(0, io.SeekCurrent)
+	r.mx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomically(filepath.Join(r.persistence.dir, snapshotFileName), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(state)
+	})
+	if err != nil {
+		return err
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.persistence.compact(offset)
+}
+
+// compact removes the entries before offset from the write-ahead log, it must be called with the lock held.
+// A crash before the compaction replays entries already in the snapshot, which is harmless.
+func (p *persistence) compact(offset int64) error {
+	end, err := p.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if end == offset {
+		if err := p.wal.Truncate(0); err != nil {
+			return err
+		}
+		_, err := p.wal.Seek(0, io.SeekStart)
+		return err
+	}
+
+	// the entries logged since the copy of the state move to a new log, renamed over the current one
+	tail := make([]byte, end-offset)
+	if _, err := p.wal.ReadAt(tail, offset); err != nil {
+		return err
+	}
+	path := filepath.Join(p.dir, walFileName)
+	err = writeFileAtomically(path, func(w io.Writer) error {
+		_, err := w.Write(tail)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	wal, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := wal.Seek(0, io.SeekEnd); err != nil {
+		_ = wal.Close()
+		return err
+	}
+	_ = p.wal.Close()
+	p.wal = wal
+	return nil
+}
+
+// batch runs the items of a batch write, their mutations being logged as a single entry of the write-ahead log.
+// When write fails or the entry cannot be appended, the mutations are undone. It must be called with the lock held.
+func (r *Repo) batch(write func() error) error {
+	r.pending = &pendingBatch{previous: map[string]previousMessage{}}
+	pending := r.pending
+	err := write()
+	r.pending = nil
+	if err == nil && len(pending.entries) > 0 {
+		err = r.log(entry{Op: opBatch, Entries: pending.entries})
+	}
+	if err != nil {
+		for id, previous := range pending.previous {
+			r.remove(id)
+			if previous.exists {
+				r.revisions[id] = previous.revisions
+				r.store(previous.message)
+			}
+		}
+	}
+	return err
+}
+
+// log appends a mutation to the write-ahead log, it must be called with the lock held.
+// The mutations of a batch write are held until the end of the batch.
+func (r *Repo) log(e entry) error {
+	if r.pending != nil {
+		id := e.ID
+		if e.Message != nil {
+			id = e.Message.ID
+		}
+		if _, recorded := r.pending.previous[id]; !recorded && e.Key == nil {
+			message, exists := r.messages[id]
+			r.pending.previous[id] = previousMessage{message: message, exists: exists, revisions: r.revisions[id]}
+		}
+		r.pending.entries = append(r.pending.entries, e)
+		return nil
+	}
+	if r.persistence == nil {
+		return nil
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return r.persistence.append(append(line, '\n'))
+}
+
+// append writes a line to the write-ahead log. A failed append, e.g. a short write on a full disk,
+// is truncated from the log so that the next appends do not follow a torn line. When it cannot be,
+// the persistence fails and rejects the later appends rather than corrupting the log.
+func (p *persistence) append(line []byte) error {
+	if p.failed != nil {
+		return fmt.Errorf("write-ahead log unavailable: %w", p.failed)
+	}
+	offset, err := p.wal.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = p.wal.Write(line)
+	if err == nil && p.policy == FsyncAlways {
+		err = p.wal.Sync()
+	}
+	if err != nil {
+		p.rollback(offset)
+		return err
+	}
+	return nil
+}
+
+// rollback removes from the write-ahead log what a failed append wrote after offset.
+func (p *persistence) rollback(offset int64) {
+	if err := p.wal.Truncate(offset); err != nil {
+		p.failed = err
+		logrus.Errorf("failed to remove a failed write-ahead log entry: %v", err)
+		return
+	}
+	if _, err := p.wal.Seek(offset, io.SeekStart); err != nil {
+		p.failed = err
+		logrus.Errorf("failed to remove a failed write-ahead log entry: %v", err)
+	}
+}
+
+// apply applies a logged mutation to the messages or the idempotency keys, it must be called with the lock held.
+func (r *Repo) apply(e entry) error {
+	switch e.Op {
+	case opPut:
+		if e.Message == nil {
+			return errors.New("put entry without message")
+		}
+		r.store(upgrade(*e.Message))
+	case opDelete:
+		r.remove(e.ID)
+	case opPutKey:
+		if e.Key == nil {
+			return errors.New("put_key entry without key")
+		}
+		r.idempotencyKeys[e.Key.Key] = *e.Key
+	case opDeleteKey:
+		delete(r.idempotencyKeys, e.ID)
+	case opBatch:
+		for _, batched := range e.Entries {
+			if err := r.apply(batched); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s is an unknown operation", e.Op)
+	}
+	return nil
+}
+
+// replay applies the entries of the write-ahead log. An incomplete last line, left by a crash
+// in the middle of a write, is discarded.
+func (r *Repo) replay(wal *os.File) error {
+	reader := bufio.NewReader(wal)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				logrus.Warnf("discarding an incomplete write-ahead log entry of %d bytes", len(line))
+				if err := wal.Truncate(offset); err != nil {
+					return err
+				}
+			}
+			_, err = wal.Seek(offset, io.SeekStart)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		var e entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("corrupted entry at offset %d: %w", offset, err)
+		}
+		if err := r.apply(e); err != nil {
+			return fmt.Errorf("invalid entry at offset %d: %w", offset, err)
+		}
+		offset += int64(len(line))
+	}
+}
+
+// loadSnapshot loads the messages of the snapshot file, if any.
+func (r *Repo) loadSnapshot(path string) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state snapshot
+	if err := json.Unmarshal(content, &state); err != nil {
+		return err
+	}
+	for _, key := range state.IdempotencyKeys {
+		r.idempotencyKeys[key.Key] = key
+	}
+	for _, revision := range state.Revisions {
+		r.revisions[revision.MessageID] = append(r.revisions[revision.MessageID], revision)
+	}
+	// the snapshots taken before the revisions were recorded get the current state of the messages as first revision
+	for _, message := range state.Messages {
+		r.store(upgrade(message))
+	}
+	return nil
+}
+
+// upgrade sets the version of the messages persisted before versioning to the initial one,
+// and the content hash of the messages persisted before hashing.
+func upgrade(message model.Message) model.Message {
+	if message.Version == 0 {
+		message.Version = 1
+	}
+	if message.ContentHash == "" {
+		message.ContentHash = model.ContentHash(message.Content, message.Mode)
+	}
+	return message
+}
+
+// sync flushes the write-ahead log to disk.
+func (r *Repo) sync() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.persistence.wal.Sync()
+}
+
+// every runs task periodically until the repository is closed.
+func (r *Repo) every(period time.Duration, task func() error) {
+	if period <= 0 {
+		return
+	}
+	r.persistence.wg.Add(1)
+	go func() {
+		defer r.persistence.wg.Done()
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := task(); err != nil {
+					logrus.Errorf("in-memory database persistence failed: %v", err)
+				}
+			case <-r.persistence.stop:
+				return
+			}
+		}
+	}()
+}
+
+// writeFileAtomically writes the content written by write to a temporary file renamed over path once flushed,
+// so path always holds a complete document.
+func writeFileAtomically(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dir.Close() }()
+	return dir.Sync()
+}
@@ -0,0 +1,315 @@
+package in_memory
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// persistedConfig returns the configuration of a repository persisted in dir.
+func persistedConfig(dir string, policy string) config.Database {
+	return config.Database{Type: "in-memory", WALDir: dir, FsyncPolicy: policy, FsyncInterval: time.Millisecond}
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("NotPersisted", func(t *testing.T) {
+		repo, err := Open(config.Database{Type: "in-memory"})
+		require.NoError(t, err)
+		assert.Nil(t, repo.persistence)
+		assert.NoError(t, repo.Snapshot())
+		assert.NoError(t, repo.Close())
+	})
+
+	t.Run("UnknownFsyncPolicy", func(t *testing.T) {
+		_, err := Open(persistedConfig(t.TempDir(), "sometimes"))
+		assert.Error(t, err)
+	})
+
+	for _, policy := range []string{FsyncAlways, FsyncInterval, FsyncNever} {
+		t.Run("ReplayWithFsync"+policy, func(t *testing.T) {
+			dir := t.TempDir()
+			repo, err := Open(persistedConfig(dir, policy))
+			require.NoError(t, err)
+
+			// Save, update and delete messages
+			kept := model.NewMessage("kept message", false)
+			deleted := model.NewMessage("deleted message", false)
+			_, err = repo.SaveMessage(kept, context.Background())
+			require.NoError(t, err)
+			_, err = repo.SaveMessage(deleted, context.Background())
+			require.NoError(t, err)
+			updated, err := repo.UpdateMessage(model.Message{ID: kept.ID, Content: "kayak", IsPalindrome: true}, context.Background())
+			require.NoError(t, err)
+			require.NoError(t, repo.DeleteMessage(deleted.ID, 0, context.Background()))
+			require.NoError(t, repo.Close())
+
+			// Open the repository again and check the state has been replayed
+			repo, err = Open(persistedConfig(dir, policy))
+			require.NoError(t, err)
+			defer func() { _ = repo.Close() }()
+			page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+			messages := page.Messages
+			assert.NoError(t, err)
+			require.Len(t, messages, 1)
+			assert.Equal(t, updated.Content, messages[0].Content)
+			assert.True(t, updated.CreatedAt.Equal(messages[0].CreatedAt))
+			assert.True(t, updated.UpdatedAt.Equal(messages[0].UpdatedAt))
+			revisions, err := repo.ListRevisions(kept.ID, context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, revisions, 2)
+			trash, err := repo.ListMessages(model.ListQuery{Deleted: true}, context.Background())
+			assert.NoError(t, err)
+			require.Len(t, trash.Messages, 1)
+			assert.Equal(t, deleted.ID, trash.Messages[0].ID)
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Open(persistedConfig(dir, FsyncAlways))
+	require.NoError(t, err)
+
+	// Save messages and compact them into a snapshot
+	first := model.NewMessage("first message", false)
+	_, err = repo.SaveMessage(first, context.Background())
+	require.NoError(t, err)
+	require.NoError(t, repo.Snapshot())
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size(), "the write-ahead log should be truncated")
+
+	// Mutations after the snapshot are logged again
+	second := model.NewMessage("second message", true)
+	_, err = repo.SaveMessage(second, context.Background())
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteMessage(first.ID, 0, context.Background()))
+	require.NoError(t, repo.Close())
+
+	// Open the repository again and check both the snapshot and the log have been replayed
+	repo, err = Open(persistedConfig(dir, FsyncAlways))
+	require.NoError(t, err)
+	defer func() { _ = repo.Close() }()
+	_, err = repo.GetMessage(first.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	retrieved, err := repo.GetMessage(second.ID, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, second.Content, retrieved.Content)
+}
+
+func TestSnapshotCompaction(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Open(persistedConfig(dir, FsyncAlways))
+	require.NoError(t, err)
+
+	// Log a message after the offset of a copied state
+	first := model.NewMessage("first message", false)
+	_, err = repo.SaveMessage(first, context.Background())
+	require.NoError(t, err)
+	offset, err := repo.persistence.wal.Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	second := model.NewMessage("second message", false)
+	_, err = repo.SaveMessage(second, context.Background())
+	require.NoError(t, err)
+
+	// Only the entries logged after the offset are kept
+	require.NoError(t, repo.persistence.compact(offset))
+	content, err := os.ReadFile(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Equal(t, 1, bytes.Count(content, []byte("\n")), "the log should hold a single entry")
+	assert.Contains(t, string(content), second.ID)
+
+	// The compacted log is appended to
+	require.NoError(t, repo.DeleteMessage(second.ID, 0, context.Background()))
+	require.NoError(t, repo.Close())
+	repo, err = Open(persistedConfig(dir, FsyncAlways))
+	require.NoError(t, err)
+	defer func() { _ = repo.Close() }()
+	_, err = repo.GetMessage(second.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+}
+
+func TestSnapshotRevisions(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Open(persistedConfig(dir, FsyncAlways))
+	require.NoError(t, err)
+
+	// Record revisions before and after a snapshot
+	message := model.NewMessage("kayak", true)
+	_, err = repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+	_, err = repo.UpdateMessage(model.Message{ID: message.ID, Content: "kayaks"}, context.Background())
+	require.NoError(t, err)
+	require.NoError(t, repo.Snapshot())
+	_, err = repo.UpdateMessage(model.Message{ID: message.ID, Content: "level", IsPalindrome: true}, context.Background())
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	// Open the repository again and check every revision has been restored once
+	repo, err = Open(persistedConfig(dir, FsyncAlways))
+	require.NoError(t, err)
+	defer func() { _ = repo.Close() }()
+	revisions, err := repo.ListRevisions(message.ID, context.Background())
+	assert.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, "kayak", revisions[0].Content)
+	assert.Equal(t, "kayaks", revisions[1].Content)
+	assert.Equal(t, "level", revisions[2].Content)
+}
+
+func TestPersistIdempotencyKeys(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := Open(persistedConfig(dir, FsyncAlways))
+	require.NoError(t, err)
+
+	// Complete a key before a snapshot and claim another after it
+	completed := model.IdempotencyKey{Key: "completed", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	_, err = repo.ClaimIdempotencyKey(completed, context.Background())
+	require.NoError(t, err)
+	completed.Response, completed.ETag = []byte(`{"id":"1"}`), `"1"`
+	require.NoError(t, repo.CompleteIdempotencyKey(completed, context.Background()))
+	require.NoError(t, repo.Snapshot())
+	_, err = repo.ClaimIdempotencyKey(model.IdempotencyKey{Key: "claimed", ExpiresAt: completed.ExpiresAt, ClaimedUntil: completed.ExpiresAt}, context.Background())
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	// Open the repository again and check both keys have been restored
+	repo, err = Open(persistedConfig(dir, FsyncAlways))
+	require.NoError(t, err)
+	defer func() { _ = repo.Close() }()
+	claimed, err := repo.ClaimIdempotencyKey(completed, context.Background())
+	assert.ErrorIs(t, err, model.ErrIdempotencyKeyClaimed)
+	assert.Equal(t, completed.Response, claimed.Response)
+	_, err = repo.ClaimIdempotencyKey(model.IdempotencyKey{Key: "claimed"}, context.Background())
+	assert.ErrorIs(t, err, model.ErrIdempotencyKeyClaimed)
+}
+
+func TestReplay(t *testing.T) {
+	t.Run("IncompleteLastEntry", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := Open(persistedConfig(dir, FsyncAlways))
+		require.NoError(t, err)
+		message := model.NewMessage("test message", false)
+		_, err = repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+		require.NoError(t, repo.Close())
+
+		// Simulate a crash in the middle of a write
+		wal, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = wal.WriteString(`{"op":"delete","id":`)
+		require.NoError(t, err)
+		require.NoError(t, wal.Close())
+
+		// The incomplete entry is discarded and the log is usable again
+		repo, err = Open(persistedConfig(dir, FsyncAlways))
+		require.NoError(t, err)
+		_, err = repo.GetMessage(message.ID, context.Background())
+		assert.NoError(t, err)
+		require.NoError(t, repo.DeleteMessage(message.ID, 0, context.Background()))
+		require.NoError(t, repo.Close())
+
+		repo, err = Open(persistedConfig(dir, FsyncAlways))
+		require.NoError(t, err)
+		defer func() { _ = repo.Close() }()
+		_, err = repo.GetMessage(message.ID, context.Background())
+		assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	})
+
+	t.Run("FailedAppend", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := Open(persistedConfig(dir, FsyncAlways))
+		require.NoError(t, err)
+
+		// Simulate a short write removed by the rollback
+		offset, err := repo.persistence.wal.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		_, err = repo.persistence.wal.WriteString(`{"op":"delete","id":`)
+		require.NoError(t, err)
+		repo.persistence.rollback(offset)
+		require.NoError(t, repo.persistence.failed)
+
+		// The next entries do not follow a torn line
+		message := model.NewMessage("test message", false)
+		_, err = repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+		require.NoError(t, repo.Close())
+		repo, err = Open(persistedConfig(dir, FsyncAlways))
+		require.NoError(t, err)
+		defer func() { _ = repo.Close() }()
+		_, err = repo.GetMessage(message.ID, context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("UnremovableFailedAppend", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := Open(persistedConfig(dir, FsyncAlways))
+		require.NoError(t, err)
+
+		// A read-only log can be neither written nor truncated
+		readOnly, err := os.Open(filepath.Join(dir, walFileName))
+		require.NoError(t, err)
+		_ = repo.persistence.wal.Close()
+		repo.persistence.wal = readOnly
+		defer func() { _ = readOnly.Close() }()
+
+		_, err = repo.SaveMessage(model.NewMessage("first message", false), context.Background())
+		assert.Error(t, err)
+		_, err = repo.SaveMessage(model.NewMessage("second message", false), context.Background())
+		assert.ErrorContains(t, err, "write-ahead log unavailable")
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, err := Open(persistedConfig(dir, FsyncAlways))
+		require.NoError(t, err)
+		saves, err := repo.SaveMessages([]model.Message{model.NewMessage("kayak", true), model.NewMessage("level", true)}, context.Background())
+		require.NoError(t, err)
+
+		// A batch which cannot be logged is undone
+		readOnly, err := os.Open(filepath.Join(dir, walFileName))
+		require.NoError(t, err)
+		wal := repo.persistence.wal
+		repo.persistence.wal = readOnly
+		_, err = repo.SaveMessages([]model.Message{model.NewMessage("refer", true)}, context.Background())
+		assert.Error(t, err)
+		errs, err := repo.DeleteMessages([]model.Deletion{{ID: saves[0].Message.ID}}, context.Background())
+		assert.Error(t, err)
+		assert.Nil(t, errs)
+		repo.persistence.wal, repo.persistence.failed = wal, nil
+		_ = readOnly.Close()
+		page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, page.Messages, 2)
+		revisions, err := repo.ListRevisions(saves[0].Message.ID, context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 1)
+		require.NoError(t, repo.Close())
+
+		// The logged batch is replayed
+		repo, err = Open(persistedConfig(dir, FsyncAlways))
+		require.NoError(t, err)
+		defer func() { _ = repo.Close() }()
+		for _, save := range saves {
+			_, err = repo.GetMessage(save.Message.ID, context.Background())
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("CorruptedEntry", func(t *testing.T) {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, walFileName), []byte("not json\n"), 0o644)
+		require.NoError(t, err)
+		_, err = Open(persistedConfig(dir, FsyncAlways))
+		assert.Error(t, err)
+	})
+}
@@ -3,20 +3,47 @@ package in_memory
 import (
 	"context"
 	"github.com/gharsallahmoez/palindrome/model"
+	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // Repo represents an in-memory repository for messages.
 type Repo struct {
 	messages map[string]model.Message
-	mx       sync.Mutex
+	// revisions holds the revisions of every message, oldest first.
+	revisions map[string][]model.Revision
+	// idempotencyKeys holds the idempotency keys by key.
+	idempotencyKeys map[string]model.IdempotencyKey
+	// hashes indexes the IDs of the messages by content hash.
+	hashes map[string]map[string]struct{}
+	// words is the inverted index of the messages out of the trash, holding the IDs of the messages by word.
+	words map[string]map[string]struct{}
+	// lengths holds the number of words of the indexed messages by ID.
+	lengths map[string]int
+	// indexedWords is the total number of words of the indexed messages.
+	indexedWords int
+	// dedupe enables the deduplication of the saved messages.
+	dedupe bool
+	mx     sync.Mutex
+	// persistence is nil unless the repository has been opened with a write-ahead log.
+	persistence *persistence
+	// pending is nil unless a batch write is running.
+	pending *pendingBatch
 }
 
 // NewRepo creates a new instance of Repo with an empty map of messages.
 func NewRepo() *Repo {
 	return &Repo{
-		messages: map[string]model.Message{},
+		messages:        map[string]model.Message{},
+		revisions:       map[string][]model.Revision{},
+		idempotencyKeys: map[string]model.IdempotencyKey{},
+		hashes:          map[string]map[string]struct{}{},
+		words:           map[string]map[string]struct{}{},
+		lengths:         map[string]int{},
 	}
 }
 
@@ -24,8 +51,28 @@ func NewRepo() *Repo {
 func (r *Repo) SaveMessage(message model.Message, _ context.Context) (model.Message, error) {
 	r.mx.Lock()
 	defer r.mx.Unlock()
-	r.messages[message.ID] = message
-	return message, nil
+	return r.save(message)
+}
+
+// SaveMessages saves several messages to the database under a single lock acquisition.
+func (r *Repo) SaveMessages(messages []model.Message, _ context.Context) ([]model.BatchResult, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	results := make([]model.BatchResult, len(messages))
+	err := r.batch(func() error {
+		for i, message := range messages {
+			saved, err := r.save(message)
+			if err != nil && !model.IsItemError(err) {
+				return err
+			}
+			results[i] = model.BatchResult{Message: saved, Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
 // GetMessage retrieves a message from the database.
@@ -33,50 +80,528 @@ func (r *Repo) GetMessage(id string, _ context.Context) (model.Message, error) {
 	r.mx.Lock()
 	defer r.mx.Unlock()
 	msg, exists := r.messages[id]
-	if !exists {
+	if !exists || !live(msg) {
 		return model.Message{}, model.ErrMessageNotFound
 	}
 	return msg, nil
 }
 
-// UpdateMessage updates a message in the database.
-func (r *Repo) UpdateMessage(id string, content string, isPalindrome bool, _ context.Context) (model.Message, error) {
+// UpdateMessage updates the message identified by message.ID in the database.
+func (r *Repo) UpdateMessage(message model.Message, _ context.Context) (model.Message, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.update(message)
+}
+
+// PatchMessage replaces the message identified by id with the result of patch on its current state.
+func (r *Repo) PatchMessage(id string, version int64, patch func(model.Message) (model.Message, error), _ context.Context) (model.Message, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.patch(model.Patch{ID: id, Version: version, Apply: patch})
+}
+
+// PatchMessages patches several messages in the database under a single lock acquisition.
+func (r *Repo) PatchMessages(patches []model.Patch, _ context.Context) ([]model.BatchResult, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	results := make([]model.BatchResult, len(patches))
+	err := r.batch(func() error {
+		for i, patch := range patches {
+			patched, err := r.patch(patch)
+			if err != nil && !model.IsItemError(err) {
+				return err
+			}
+			results[i] = model.BatchResult{Message: patched, Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DeleteMessage moves a message to the trash.
+func (r *Repo) DeleteMessage(id string, version int64, _ context.Context) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.delete(id, version)
+}
+
+// DeleteMessages moves several messages to the trash under a single lock acquisition.
+func (r *Repo) DeleteMessages(deletions []model.Deletion, _ context.Context) ([]error, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	errs := make([]error, len(deletions))
+	err := r.batch(func() error {
+		for i, deletion := range deletions {
+			err := r.delete(deletion.ID, deletion.Version)
+			if err != nil && !model.IsItemError(err) {
+				return err
+			}
+			errs[i] = err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// DeleteExpiredMessages permanently removes the messages expired at the given time.
+func (r *Repo) DeleteExpiredMessages(now time.Time, _ context.Context) (int64, error) {
+	return r.removeAll(func(msg model.Message) bool { return msg.Expired(now) })
+}
+
+// RestoreMessage moves a message out of the trash.
+func (r *Repo) RestoreMessage(id string, _ context.Context) (model.Message, error) {
 	r.mx.Lock()
 	defer r.mx.Unlock()
 	msg, exists := r.messages[id]
-	if !exists {
+	if !exists || msg.DeletedAt == nil {
 		return model.Message{}, model.ErrMessageNotFound
 	}
-	message := model.Message{
-		ID:           id,
-		Content:      content,
-		IsPalindrome: isPalindrome,
-		CreatedAt:    msg.CreatedAt,
-		UpdatedAt:    time.Now(),
+	msg.DeletedAt = nil
+	if err := r.log(entry{Op: opPut, Message: &msg}); err != nil {
+		return model.Message{}, err
 	}
-	r.messages[id] = message
-	return message, nil
+	r.store(msg)
+	return msg, nil
+}
+
+// PurgeMessages permanently removes the messages moved to the trash before the given time.
+func (r *Repo) PurgeMessages(before time.Time, _ context.Context) (int64, error) {
+	return r.removeAll(func(msg model.Message) bool { return msg.DeletedAt != nil && msg.DeletedAt.Before(before) })
+}
+
+// ListMessages retrieves a page of the messages matching the query from the database.
+func (r *Repo) ListMessages(query model.ListQuery, _ context.Context) (model.MessagePage, error) {
+	query = query.WithDefaults()
+	cursor, err := query.DecodeCursor()
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+
+	r.mx.Lock()
+	var messages []model.Message
+	for _, m := range r.messages {
+		if query.Matches(m) && (cursor == nil || query.IsAfter(m, *cursor)) {
+			messages = append(messages, m)
+		}
+	}
+	r.mx.Unlock()
+
+	sort.Slice(messages, func(i, j int) bool { return query.Less(messages[i], messages[j]) })
+	page := model.MessagePage{Messages: messages}
+	if query.Limit > 0 && len(messages) > query.Limit {
+		page.Messages = messages[:query.Limit]
+		page.NextCursor = query.NewCursor(page.Messages[query.Limit-1])
+	}
+	return page, nil
+}
+
+// SearchMessages retrieves the live messages matching every term of the query, most relevant first.
+// The candidates are looked up in the inverted index, then ranked by BM25.
+func (r *Repo) SearchMessages(query model.SearchQuery, _ context.Context) ([]model.SearchHit, error) {
+	r.mx.Lock()
+	var (
+		candidates map[string]struct{}
+		frequency  = make([]int, len(query.Terms))
+	)
+	for i, term := range query.Terms {
+		ids := r.lookup(term)
+		frequency[i] = len(ids)
+		if candidates = intersect(candidates, ids); len(candidates) == 0 {
+			break
+		}
+	}
+	averageLength := float64(r.indexedWords) / float64(max(1, len(r.lengths)))
+	var hits []model.SearchHit
+	for id := range candidates {
+		msg := r.messages[id]
+		if !live(msg) {
+			continue
+		}
+		tokens := model.Tokenize(msg.Content)
+		hit := model.SearchHit{Message: msg}
+		for i, term := range query.Terms {
+			// the index does not know the positions, the phrases are checked here
+			occurrences := len(term.Occurrences(tokens))
+			if occurrences == 0 {
+				hit.Score = -1
+				break
+			}
+			hit.Score += bm25(occurrences, frequency[i], len(r.lengths), len(tokens), averageLength)
+		}
+		if hit.Score >= 0 {
+			hits = append(hits, hit)
+		}
+	}
+	r.mx.Unlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Message.ID < hits[j].Message.ID
+	})
+	hits = hits[min(query.Offset, len(hits)):]
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	for i := range hits {
+		hits[i].Snippet = query.Snippet(hits[i].Message.Content)
+	}
+	return hits, nil
+}
+
+// MessageStats aggregates the live messages created in the range of the query.
+func (r *Repo) MessageStats(query model.StatsQuery, _ context.Context) (model.Stats, error) {
+	var (
+		stats     model.Stats
+		length    int64
+		created   = map[time.Time]int64{}
+		frequency = map[string]int64{}
+	)
+	r.mx.Lock()
+	for _, msg := range r.messages {
+		if !query.Matches(msg) {
+			continue
+		}
+		stats.Total++
+		length += int64(utf8.RuneCountInString(msg.Content))
+		created[query.Bucket.Truncate(msg.CreatedAt)]++
+		if msg.IsPalindrome {
+			stats.Palindromes++
+			frequency[msg.Content]++
+		}
+	}
+	r.mx.Unlock()
+
+	if stats.Total > 0 {
+		stats.AverageLength = float64(length) / float64(stats.Total)
+	}
+	for start, count := range created {
+		stats.Created = append(stats.Created, model.BucketCount{Start: start, Count: count})
+	}
+	sort.Slice(stats.Created, func(i, j int) bool { return stats.Created[i].Start.Before(stats.Created[j].Start) })
+	for content, count := range frequency {
+		stats.TopPalindromes = append(stats.TopPalindromes, model.ContentCount{Content: content, Count: count})
+	}
+	sort.Slice(stats.TopPalindromes, func(i, j int) bool {
+		a, b := stats.TopPalindromes[i], stats.TopPalindromes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Content < b.Content
+	})
+	if len(stats.TopPalindromes) > query.Top {
+		stats.TopPalindromes = stats.TopPalindromes[:query.Top]
+	}
+	return stats, nil
+}
+
+// ListRevisions retrieves the revisions of a message, oldest first.
+func (r *Repo) ListRevisions(id string, _ context.Context) ([]model.Revision, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if msg, exists := r.messages[id]; !exists || !live(msg) {
+		return nil, model.ErrMessageNotFound
+	}
+	return append([]model.Revision(nil), r.revisions[id]...), nil
 }
 
-// DeleteMessage deletes a message from the database.
-func (r *Repo) DeleteMessage(id string, _ context.Context) error {
+// GetRevision retrieves the revision of a message at the given version.
+func (r *Repo) GetRevision(id string, number int64, _ context.Context) (model.Revision, error) {
 	r.mx.Lock()
 	defer r.mx.Unlock()
-	_, exists := r.messages[id]
-	if !exists {
+	if msg, exists := r.messages[id]; !exists || !live(msg) {
+		return model.Revision{}, model.ErrMessageNotFound
+	}
+	for _, revision := range r.revisions[id] {
+		if revision.Number == number {
+			return revision, nil
+		}
+	}
+	return model.Revision{}, model.ErrRevisionNotFound
+}
+
+// ClaimIdempotencyKey stores a new idempotency key without response, unless it is already stored and not claimable.
+func (r *Repo) ClaimIdempotencyKey(key model.IdempotencyKey, _ context.Context) (model.IdempotencyKey, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if claimed, exists := r.idempotencyKeys[key.Key]; exists && !claimed.Claimable(time.Now()) {
+		return claimed, model.ErrIdempotencyKeyClaimed
+	}
+	key.Response, key.ETag = nil, ""
+	if err := r.log(entry{Op: opPutKey, Key: &key}); err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	r.idempotencyKeys[key.Key] = key
+	return key, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed idempotency key.
+func (r *Repo) CompleteIdempotencyKey(key model.IdempotencyKey, _ context.Context) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if err := r.log(entry{Op: opPutKey, Key: &key}); err != nil {
+		return err
+	}
+	r.idempotencyKeys[key.Key] = key
+	return nil
+}
+
+// ReleaseIdempotencyKey removes a claimed idempotency key.
+func (r *Repo) ReleaseIdempotencyKey(key string, _ context.Context) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if err := r.log(entry{Op: opDeleteKey, ID: key}); err != nil {
+		return err
+	}
+	delete(r.idempotencyKeys, key)
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys removes the idempotency keys expired at the given time.
+func (r *Repo) DeleteExpiredIdempotencyKeys(now time.Time, _ context.Context) (int64, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	var removed int64
+	for key, claimed := range r.idempotencyKeys {
+		if now.Before(claimed.ExpiresAt) {
+			continue
+		}
+		if err := r.log(entry{Op: opDeleteKey, ID: key}); err != nil {
+			return removed, err
+		}
+		delete(r.idempotencyKeys, key)
+		removed++
+	}
+	return removed, nil
+}
+
+// save logs and stores a new message, unless it is a duplicate or its ID is taken.
+// It must be called with the lock held.
+func (r *Repo) save(message model.Message) (model.Message, error) {
+	message.ContentHash = model.ContentHash(message.Content, message.Mode)
+	if r.dedupe {
+		for id := range r.hashes[message.ContentHash] {
+			if msg := r.messages[id]; live(msg) {
+				return msg, model.ErrDuplicateMessage
+			}
+		}
+	}
+	if _, exists := r.messages[message.ID]; exists {
+		return model.Message{}, model.ErrMessageIDTaken
+	}
+	if err := r.log(entry{Op: opPut, Message: &message}); err != nil {
+		return model.Message{}, err
+	}
+	r.store(message)
+	return message, nil
+}
+
+// update logs and stores the update of a message, it must be called with the lock held.
+func (r *Repo) update(message model.Message) (model.Message, error) {
+	msg, exists := r.messages[message.ID]
+	if !exists || !live(msg) {
+		return model.Message{}, model.ErrMessageNotFound
+	}
+	if message.Version != 0 && message.Version != msg.Version {
+		return model.Message{}, model.ErrVersionMismatch
+	}
+	if message.ExpiresAt == nil {
+		message.ExpiresAt = msg.ExpiresAt
+	}
+	message.ContentHash = model.ContentHash(message.Content, message.Mode)
+	message.Version = msg.Version + 1
+	message.CreatedAt = msg.CreatedAt
+	message.UpdatedAt = time.Now()
+	if err := r.log(entry{Op: opPut, Message: &message}); err != nil {
+		return model.Message{}, err
+	}
+	r.store(message)
+	return message, nil
+}
+
+// patch logs and stores the result of a patch on a message, it must be called with the lock held.
+func (r *Repo) patch(patch model.Patch) (model.Message, error) {
+	msg, exists := r.messages[patch.ID]
+	if !exists || !live(msg) {
+		return model.Message{}, model.ErrMessageNotFound
+	}
+	if patch.Version != 0 && patch.Version != msg.Version {
+		return model.Message{}, model.ErrVersionMismatch
+	}
+	message, err := patch.Apply(msg)
+	if err != nil {
+		return model.Message{}, err
+	}
+	message.ID = msg.ID
+	message.ContentHash = model.ContentHash(message.Content, message.Mode)
+	message.Version = msg.Version + 1
+	message.CreatedAt = msg.CreatedAt
+	message.UpdatedAt = time.Now()
+	message.DeletedAt = nil
+	if err := r.log(entry{Op: opPut, Message: &message}); err != nil {
+		return model.Message{}, err
+	}
+	r.store(message)
+	return message, nil
+}
+
+// delete logs and stores the move of a message to the trash, it must be called with the lock held.
+func (r *Repo) delete(id string, version int64) error {
+	msg, exists := r.messages[id]
+	if !exists || !live(msg) {
 		return model.ErrMessageNotFound
 	}
-	delete(r.messages, id)
+	if version != 0 && version != msg.Version {
+		return model.ErrVersionMismatch
+	}
+	now := time.Now()
+	msg.DeletedAt = &now
+	if err := r.log(entry{Op: opPut, Message: &msg}); err != nil {
+		return err
+	}
+	r.store(msg)
 	return nil
 }
 
-// ListMessages retrieves all messages from the database.
-func (r *Repo) ListMessages(_ context.Context) ([]model.Message, error) {
+// store stores a message and records its revision, unless already recorded.
+// It must be called with the lock held.
+func (r *Repo) store(message model.Message) {
+	if previous, exists := r.messages[message.ID]; exists {
+		r.unindex(previous)
+	}
+	r.messages[message.ID] = message
+	if r.hashes[message.ContentHash] == nil {
+		r.hashes[message.ContentHash] = map[string]struct{}{}
+	}
+	r.hashes[message.ContentHash][message.ID] = struct{}{}
+	if message.DeletedAt == nil {
+		tokens := model.Tokenize(message.Content)
+		for _, token := range tokens {
+			if r.words[token.Text] == nil {
+				r.words[token.Text] = map[string]struct{}{}
+			}
+			r.words[token.Text][message.ID] = struct{}{}
+		}
+		r.lengths[message.ID] = len(tokens)
+		r.indexedWords += len(tokens)
+	}
+	revisions := r.revisions[message.ID]
+	if n := len(revisions); n == 0 || revisions[n-1].Number < message.Version {
+		r.revisions[message.ID] = append(revisions, model.NewRevision(message))
+	}
+}
+
+// removeAll permanently removes the messages matching the predicate and returns their number.
+func (r *Repo) removeAll(matches func(model.Message) bool) (int64, error) {
 	r.mx.Lock()
 	defer r.mx.Unlock()
-	var messages []model.Message
-	for _, m := range r.messages {
-		messages = append(messages, m)
+	var removed int64
+	for id, msg := range r.messages {
+		if !matches(msg) {
+			continue
+		}
+		if err := r.log(entry{Op: opDelete, ID: id}); err != nil {
+			return removed, err
+		}
+		r.remove(id)
+		removed++
 	}
-	return messages, nil
+	return removed, nil
+}
+
+// live reports whether a message is neither deleted nor expired.
+func live(msg model.Message) bool {
+	return msg.DeletedAt == nil && !msg.Expired(time.Now())
+}
+
+// remove removes a message and its revisions, it must be called with the lock held.
+func (r *Repo) remove(id string) {
+	if msg, exists := r.messages[id]; exists {
+		r.unindex(msg)
+	}
+	delete(r.messages, id)
+	delete(r.revisions, id)
+}
+
+// unindex removes a message from the content hash and word indexes, it must be called with the lock held.
+func (r *Repo) unindex(msg model.Message) {
+	delete(r.hashes[msg.ContentHash], msg.ID)
+	if len(r.hashes[msg.ContentHash]) == 0 {
+		delete(r.hashes, msg.ContentHash)
+	}
+	length, indexed := r.lengths[msg.ID]
+	if !indexed {
+		return
+	}
+	for _, token := range model.Tokenize(msg.Content) {
+		delete(r.words[token.Text], msg.ID)
+		if len(r.words[token.Text]) == 0 {
+			delete(r.words, token.Text)
+		}
+	}
+	delete(r.lengths, msg.ID)
+	r.indexedWords -= length
+}
+
+// lookup returns the IDs of the indexed messages holding every word of a term,
+// it must be called with the lock held.
+func (r *Repo) lookup(term model.SearchTerm) map[string]struct{} {
+	// nil stands for every ID until the first word, an unindexed word holding none
+	var ids map[string]struct{}
+	for i, word := range term.Words {
+		if !term.Prefix || i < len(term.Words)-1 {
+			indexed, exists := r.words[word]
+			if !exists {
+				return map[string]struct{}{}
+			}
+			ids = intersect(ids, indexed)
+			continue
+		}
+		prefixed := map[string]struct{}{}
+		for indexed, wordIDs := range r.words {
+			if strings.HasPrefix(indexed, word) {
+				for id := range wordIDs {
+					prefixed[id] = struct{}{}
+				}
+			}
+		}
+		ids = intersect(ids, prefixed)
+	}
+	if ids == nil {
+		return map[string]struct{}{}
+	}
+	return ids
+}
+
+// intersect returns the IDs held by both sets, a nil set standing for every ID.
+func intersect(a, b map[string]struct{}) map[string]struct{} {
+	if a == nil {
+		return b
+	}
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	ids := map[string]struct{}{}
+	for id := range a {
+		if _, exists := b[id]; exists {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids
+}
+
+// bm25 scores the occurrences of a term in a message of the given length,
+// the term being held by frequency of the total messages.
+func bm25(occurrences, frequency, total, length int, averageLength float64) float64 {
+	const k1, b = 1.2, 0.75
+	idf := math.Log(1 + (float64(total-frequency)+0.5)/(float64(frequency)+0.5))
+	tf := float64(occurrences)
+	return idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(length)/averageLength))
 }
@@ -3,8 +3,11 @@ package in_memory
 import (
 	"context"
 	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestSaveMessage(t *testing.T) {
@@ -13,6 +16,7 @@ func TestSaveMessage(t *testing.T) {
 
 	// Create a message to save
 	message := model.NewMessage("test message", false)
+	message.ContentHash = model.ContentHash(message.Content, message.Mode)
 
 	// Save the message
 	savedMessage, err := repo.SaveMessage(message, context.Background())
@@ -20,6 +24,10 @@ func TestSaveMessage(t *testing.T) {
 	// Check for errors
 	assert.NoError(t, err)
 	assert.Equal(t, message, savedMessage)
+
+	// Saving the same id twice should fail
+	_, err = repo.SaveMessage(message, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageIDTaken)
 }
 
 func TestGetMessage(t *testing.T) {
@@ -29,6 +37,7 @@ func TestGetMessage(t *testing.T) {
 
 		// Create and save a message
 		message := model.NewMessage("test message", false)
+		message.ContentHash = model.ContentHash(message.Content, message.Mode)
 		_, err := repo.SaveMessage(message, context.Background())
 		if err != nil {
 			assert.NoError(t, err)
@@ -67,13 +76,16 @@ func TestUpdateMessage(t *testing.T) {
 
 		// Update the message
 		updatedContent := "updated message"
-		updatedMessage, err := repo.UpdateMessage(message.ID, updatedContent, true, context.Background())
+		update := model.Message{ID: message.ID, Content: updatedContent, IsPalindrome: true, Mode: palindrome.Word}
+		updatedMessage, err := repo.UpdateMessage(update, context.Background())
 
 		// Check for errors
 		assert.NoError(t, err)
 		assert.Equal(t, updatedContent, updatedMessage.Content)
 		assert.True(t, updatedMessage.IsPalindrome)
+		assert.Equal(t, palindrome.Word, updatedMessage.Mode)
 		assert.Equal(t, message.ID, updatedMessage.ID)
+		assert.Equal(t, message.CreatedAt, updatedMessage.CreatedAt)
 		assert.NotEqual(t, message.UpdatedAt, updatedMessage.UpdatedAt)
 	})
 
@@ -82,12 +94,65 @@ func TestUpdateMessage(t *testing.T) {
 		repo := NewRepo()
 
 		// Try to update a non-existent message
-		_, err := repo.UpdateMessage("non-existent-id", "new content", false, context.Background())
+		_, err := repo.UpdateMessage(model.Message{ID: "non-existent-id", Content: "new content"}, context.Background())
 		assert.Error(t, err)
 		assert.Equal(t, err.Error(), "message not found")
 	})
 }
 
+func TestPatchMessage(t *testing.T) {
+	repo := NewRepo()
+
+	// Create and save a message expiring in an hour
+	message := model.NewMessage("test message", false)
+	expiresAt := time.Now().Add(time.Hour)
+	message.ExpiresAt = &expiresAt
+	_, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+
+	// Patch the content and clear the expiry
+	patched, err := repo.PatchMessage(message.ID, 1, func(current model.Message) (model.Message, error) {
+		assert.Equal(t, "test message", current.Content)
+		current.Content = "kayak"
+		current.IsPalindrome = true
+		current.ExpiresAt = nil
+		return current, nil
+	}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "kayak", patched.Content)
+	assert.True(t, patched.IsPalindrome)
+	assert.Nil(t, patched.ExpiresAt)
+	assert.Equal(t, int64(2), patched.Version)
+	assert.Equal(t, model.ContentHash("kayak", patched.Mode), patched.ContentHash)
+
+	// The patch is recorded as a revision
+	revisions, err := repo.ListRevisions(message.ID, context.Background())
+	require.NoError(t, err)
+	assert.Len(t, revisions, 2)
+
+	// A stale version is rejected without patching
+	_, err = repo.PatchMessage(message.ID, 1, func(current model.Message) (model.Message, error) {
+		t.Error("patch should not be called")
+		return current, nil
+	}, context.Background())
+	assert.ErrorIs(t, err, model.ErrVersionMismatch)
+
+	// The error of the patch fails the patch
+	_, err = repo.PatchMessage(message.ID, 0, func(model.Message) (model.Message, error) {
+		return model.Message{}, model.ErrInvalidRequest
+	}, context.Background())
+	assert.ErrorIs(t, err, model.ErrInvalidRequest)
+	stored, err := repo.GetMessage(message.ID, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stored.Version)
+
+	// A missing message is not found
+	_, err = repo.PatchMessage("non-existent-id", 0, func(current model.Message) (model.Message, error) {
+		return current, nil
+	}, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+}
+
 func TestDeleteMessage(t *testing.T) {
 	t.Run("DeleteExistingMessage", func(t *testing.T) {
 		// Create a Repo instance
@@ -101,7 +166,7 @@ func TestDeleteMessage(t *testing.T) {
 		}
 
 		// Delete the message
-		err = repo.DeleteMessage(message.ID, context.Background())
+		err = repo.DeleteMessage(message.ID, 0, context.Background())
 
 		// Check for errors
 		assert.NoError(t, err)
@@ -116,12 +181,454 @@ func TestDeleteMessage(t *testing.T) {
 		repo := NewRepo()
 
 		// Try to delete a non-existent message
-		err := repo.DeleteMessage("non-existent-id", context.Background())
+		err := repo.DeleteMessage("non-existent-id", 0, context.Background())
 		assert.Error(t, err)
 		assert.Equal(t, err.Error(), "message not found")
 	})
 }
 
+func TestConditionalWrites(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Create and save a message at version 1
+	message := model.NewMessage("test message", false)
+	_, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+
+	// Updating at the current version should increment it
+	update := model.Message{ID: message.ID, Content: "kayak", IsPalindrome: true, Version: 1}
+	updatedMessage, err := repo.UpdateMessage(update, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updatedMessage.Version)
+
+	// Updating or deleting at a stale version should fail
+	_, err = repo.UpdateMessage(update, context.Background())
+	assert.ErrorIs(t, err, model.ErrVersionMismatch)
+	err = repo.DeleteMessage(message.ID, 1, context.Background())
+	assert.ErrorIs(t, err, model.ErrVersionMismatch)
+
+	// Updating without a version should always succeed
+	update.Version = 0
+	updatedMessage, err = repo.UpdateMessage(update, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), updatedMessage.Version)
+
+	// A missing message is not found whatever the version
+	_, err = repo.UpdateMessage(model.Message{ID: "non-existent-id", Version: 1}, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	err = repo.DeleteMessage("non-existent-id", 1, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+
+	// Deleting at the current version should succeed
+	err = repo.DeleteMessage(message.ID, 3, context.Background())
+	assert.NoError(t, err)
+}
+
+func TestRevisions(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Create, save and update a message twice
+	message := model.NewMessage("kayak", true)
+	_, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+	for _, content := range []string{"kayaks", "level"} {
+		_, err = repo.UpdateMessage(model.Message{ID: message.ID, Content: content, IsPalindrome: content == "level"}, context.Background())
+		require.NoError(t, err)
+	}
+
+	// Every state should be recorded, oldest first
+	revisions, err := repo.ListRevisions(message.ID, context.Background())
+	assert.NoError(t, err)
+	require.Len(t, revisions, 3)
+	for i, content := range []string{"kayak", "kayaks", "level"} {
+		assert.Equal(t, message.ID, revisions[i].MessageID)
+		assert.Equal(t, int64(i+1), revisions[i].Number)
+		assert.Equal(t, content, revisions[i].Content)
+	}
+	assert.False(t, revisions[1].IsPalindrome)
+
+	// Retrieve a revision
+	revision, err := repo.GetRevision(message.ID, 2, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "kayaks", revision.Content)
+	_, err = repo.GetRevision(message.ID, 4, context.Background())
+	assert.ErrorIs(t, err, model.ErrRevisionNotFound)
+
+	// The revisions are deleted with their message
+	require.NoError(t, repo.DeleteMessage(message.ID, 0, context.Background()))
+	_, err = repo.ListRevisions(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	_, err = repo.GetRevision(message.ID, 1, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+}
+
+func TestTrash(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Create, save and delete a message
+	message := model.NewMessage("kayak", true)
+	_, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteMessage(message.ID, 0, context.Background()))
+
+	// The deleted message is hidden
+	_, err = repo.GetMessage(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	_, err = repo.UpdateMessage(model.Message{ID: message.ID, Content: "level"}, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	assert.ErrorIs(t, repo.DeleteMessage(message.ID, 0, context.Background()), model.ErrMessageNotFound)
+	_, err = repo.ListRevisions(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, page.Messages)
+
+	// but listed in the trash
+	page, err = repo.ListMessages(model.ListQuery{Deleted: true, SortBy: model.SortByDeletedAt}, context.Background())
+	assert.NoError(t, err)
+	require.Len(t, page.Messages, 1)
+	assert.Equal(t, message.ID, page.Messages[0].ID)
+	assert.NotNil(t, page.Messages[0].DeletedAt)
+
+	// Restore the message
+	restored, err := repo.RestoreMessage(message.ID, context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, message.Content, restored.Content)
+	_, err = repo.GetMessage(message.ID, context.Background())
+	assert.NoError(t, err)
+	_, err = repo.RestoreMessage(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+
+	// Delete it again and purge the trash
+	require.NoError(t, repo.DeleteMessage(message.ID, 0, context.Background()))
+	purged, err := repo.PurgeMessages(time.Now().Add(-time.Hour), context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = repo.PurgeMessages(time.Now().Add(time.Second), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = repo.RestoreMessage(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+}
+
+func TestExpiry(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Save a message expiring in an hour and an expired one
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	live := model.NewMessage("kayak", true)
+	live.ExpiresAt = &expiresAt
+	_, err := repo.SaveMessage(live, context.Background())
+	require.NoError(t, err)
+	expiredAt := time.Now().Add(-time.Second)
+	expired := model.NewMessage("level", true)
+	expired.ExpiresAt = &expiredAt
+	_, err = repo.SaveMessage(expired, context.Background())
+	require.NoError(t, err)
+
+	// The expired message is hidden
+	_, err = repo.GetMessage(expired.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	_, err = repo.UpdateMessage(model.Message{ID: expired.ID, Content: "racecar"}, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+	assert.NoError(t, err)
+	require.Len(t, page.Messages, 1)
+	assert.Equal(t, live.ID, page.Messages[0].ID)
+
+	// An update without expiry keeps the current one
+	updated, err := repo.UpdateMessage(model.Message{ID: live.ID, Content: "racecar"}, context.Background())
+	assert.NoError(t, err)
+	require.NotNil(t, updated.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*updated.ExpiresAt))
+
+	// Only the expired message is deleted
+	deleted, err := repo.DeleteExpiredMessages(time.Now(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, err = repo.GetMessage(live.ID, context.Background())
+	assert.NoError(t, err)
+}
+
+func TestBatchWrites(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Save two messages at once
+	saves, err := repo.SaveMessages([]model.Message{model.NewMessage("kayak", true), model.NewMessage("test", false)}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, saves, 2)
+	var saved []model.Message
+	for _, save := range saves {
+		require.NoError(t, save.Err)
+		_, err := repo.GetMessage(save.Message.ID, context.Background())
+		assert.NoError(t, err)
+		saved = append(saved, save.Message)
+	}
+
+	// Patch them along a missing message and a stale version, the first one twice in order
+	appending := func(suffix string) func(model.Message) (model.Message, error) {
+		return func(message model.Message) (model.Message, error) {
+			message.Content += suffix
+			return message, nil
+		}
+	}
+	results, err := repo.PatchMessages([]model.Patch{
+		{ID: saved[0].ID, Apply: appending("s")},
+		{ID: "missing", Apply: appending("s")},
+		{ID: saved[1].ID, Version: 2, Apply: appending("s")},
+		{ID: saved[0].ID, Version: 2, Apply: appending("!")},
+	}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "kayaks", results[0].Message.Content)
+	assert.ErrorIs(t, results[1].Err, model.ErrMessageNotFound)
+	assert.ErrorIs(t, results[2].Err, model.ErrVersionMismatch)
+	assert.NoError(t, results[3].Err)
+	assert.Equal(t, "kayaks!", results[3].Message.Content)
+	assert.Equal(t, int64(3), results[3].Message.Version)
+	revisions, err := repo.ListRevisions(saved[0].ID, context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+
+	// A failing patch fails the whole batch
+	_, err = repo.PatchMessages([]model.Patch{
+		{ID: saved[1].ID, Apply: appending("s")},
+		{ID: saved[0].ID, Apply: func(model.Message) (model.Message, error) { return model.Message{}, assert.AnError }},
+	}, context.Background())
+	assert.ErrorIs(t, err, assert.AnError)
+	unchanged, err := repo.GetMessage(saved[1].ID, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, saved[1].Content, unchanged.Content)
+	assert.Equal(t, saved[1].Version, unchanged.Version)
+
+	// Delete them along a missing message
+	errs, err := repo.DeleteMessages([]model.Deletion{
+		{ID: saved[0].ID, Version: 3},
+		{ID: "missing"},
+		{ID: saved[1].ID},
+	}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], model.ErrMessageNotFound)
+	assert.NoError(t, errs[2])
+	page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, page.Messages)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Claim a new key
+	key := model.IdempotencyKey{Key: "key", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour), ClaimedUntil: time.Now().Add(time.Minute)}
+	claimed, err := repo.ClaimIdempotencyKey(key, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", claimed.RequestHash)
+	assert.False(t, claimed.Completed())
+
+	// Claim it again while it is processed then once completed
+	claimed, err = repo.ClaimIdempotencyKey(model.IdempotencyKey{Key: "key", RequestHash: "other", ExpiresAt: key.ExpiresAt}, context.Background())
+	assert.ErrorIs(t, err, model.ErrIdempotencyKeyClaimed)
+	assert.Equal(t, "hash", claimed.RequestHash)
+	assert.False(t, claimed.Completed())
+	key.Response, key.ETag = []byte(`{"id":"1"}`), `"1"`
+	require.NoError(t, repo.CompleteIdempotencyKey(key, context.Background()))
+	claimed, err = repo.ClaimIdempotencyKey(key, context.Background())
+	assert.ErrorIs(t, err, model.ErrIdempotencyKeyClaimed)
+	assert.Equal(t, key.Response, claimed.Response)
+	assert.Equal(t, key.ETag, claimed.ETag)
+
+	// A released key can be claimed again
+	require.NoError(t, repo.ReleaseIdempotencyKey("key", context.Background()))
+	_, err = repo.ClaimIdempotencyKey(key, context.Background())
+	assert.NoError(t, err)
+
+	// So does a key whose claim lapsed before its response was recorded
+	lapsed := model.IdempotencyKey{Key: "lapsed", RequestHash: "hash", ExpiresAt: key.ExpiresAt, ClaimedUntil: time.Now().Add(-time.Second)}
+	_, err = repo.ClaimIdempotencyKey(lapsed, context.Background())
+	require.NoError(t, err)
+	claimed, err = repo.ClaimIdempotencyKey(model.IdempotencyKey{Key: "lapsed", RequestHash: "hash", ExpiresAt: key.ExpiresAt, ClaimedUntil: key.ClaimedUntil}, context.Background())
+	assert.NoError(t, err)
+	assert.WithinDuration(t, key.ClaimedUntil, claimed.ClaimedUntil, time.Second)
+	_, err = repo.ClaimIdempotencyKey(lapsed, context.Background())
+	assert.ErrorIs(t, err, model.ErrIdempotencyKeyClaimed)
+
+	// So does an expired key, until it is deleted
+	expired := model.IdempotencyKey{Key: "expired", RequestHash: "hash", ExpiresAt: time.Now().Add(-time.Second)}
+	_, err = repo.ClaimIdempotencyKey(expired, context.Background())
+	require.NoError(t, err)
+	claimed, err = repo.ClaimIdempotencyKey(model.IdempotencyKey{Key: "expired", RequestHash: "other", ExpiresAt: key.ExpiresAt}, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "other", claimed.RequestHash)
+	require.NoError(t, repo.ReleaseIdempotencyKey("expired", context.Background()))
+	_, err = repo.ClaimIdempotencyKey(expired, context.Background())
+	require.NoError(t, err)
+	deleted, err := repo.DeleteExpiredIdempotencyKeys(time.Now(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestDedupe(t *testing.T) {
+	// Create a Repo instance with deduplication
+	repo := NewRepo()
+	repo.dedupe = true
+
+	// Save a message and its duplicate, canonically equivalent
+	message := model.NewMessage("E\u0301sope reste ici et se repose", true)
+	saved, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.ContentHash(message.Content, message.Mode), saved.ContentHash)
+	duplicate, err := repo.SaveMessage(model.NewMessage("\u00c9sope reste ici et se repose", true), context.Background())
+	assert.ErrorIs(t, err, model.ErrDuplicateMessage)
+	assert.Equal(t, message.ID, duplicate.ID)
+
+	// Within a batch, only the duplicates fail
+	other := model.NewMessage("kayak", true)
+	results, err := repo.SaveMessages([]model.Message{other, model.NewMessage("kayak", true), message}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, model.ErrDuplicateMessage)
+	assert.Equal(t, other.ID, results[1].Message.ID)
+	assert.ErrorIs(t, results[2].Err, model.ErrDuplicateMessage)
+
+	// An updated message is no longer a duplicate
+	_, err = repo.UpdateMessage(model.Message{ID: message.ID, Content: "level", IsPalindrome: true}, context.Background())
+	require.NoError(t, err)
+	_, err = repo.SaveMessage(model.NewMessage("\u00c9sope reste ici et se repose", true), context.Background())
+	assert.NoError(t, err)
+
+	// Neither is a deleted message
+	require.NoError(t, repo.DeleteMessage(other.ID, 0, context.Background()))
+	_, err = repo.SaveMessage(model.NewMessage("kayak", true), context.Background())
+	assert.NoError(t, err)
+}
+
+func TestSearchMessages(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Save the messages to search
+	kayak := model.NewMessage("A kayak, a racecar and a level", false)
+	level := model.NewMessage("Level up: the level of a Kayak", false)
+	plan := model.NewMessage("A man, a plan, a canal: Panama", true)
+	trashed := model.NewMessage("a kayak in the trash", false)
+	for _, message := range []model.Message{kayak, level, plan, trashed} {
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.DeleteMessage(trashed.ID, 0, context.Background()))
+
+	search := func(text string) []string {
+		query := model.ParseSearchQuery(text)
+		hits, err := repo.SearchMessages(query, context.Background())
+		require.NoError(t, err)
+		ids := make([]string, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.Message.ID
+			assert.Positive(t, hit.Score)
+			assert.Equal(t, query.Snippet(hit.Message.Content), hit.Snippet)
+		}
+		return ids
+	}
+
+	// Words, prefixes and phrases all match, the deleted messages never do
+	assert.ElementsMatch(t, []string{kayak.ID, level.ID}, search("KAYAK"))
+	assert.Equal(t, []string{level.ID, kayak.ID}, search("level"), "more occurrences should rank higher")
+	assert.Empty(t, search("race* pan*"), "every term should match")
+	assert.Equal(t, []string{plan.ID}, search("pan*"))
+	assert.Equal(t, []string{plan.ID}, search(`"a canal"`))
+	assert.Empty(t, search(`"canal a"`))
+	assert.Empty(t, search("kay"))
+
+	// An unindexed word holds no message rather than every one
+	assert.Empty(t, search("unknown kayak"))
+	unindexed := repo.lookup(model.ParseSearchQuery("unknown").Terms[0])
+	assert.NotNil(t, unindexed)
+	assert.Empty(t, unindexed)
+
+	// Limit and offset page through the hits
+	hits, err := repo.SearchMessages(model.SearchQuery{Terms: model.ParseSearchQuery("a").Terms, Limit: 1, Offset: 1}, context.Background())
+	require.NoError(t, err)
+	assert.Len(t, hits, 1)
+
+	// The index follows the updates and the restorations
+	_, err = repo.UpdateMessage(model.Message{ID: plan.ID, Content: "no lemon, no melon", IsPalindrome: true}, context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, search("panama"))
+	assert.Equal(t, []string{plan.ID}, search("melon"))
+	_, err = repo.RestoreMessage(trashed.ID, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{trashed.ID}, search("trash"))
+}
+
+func TestMessageStats(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Save messages created at known times, one of them deleted
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var deleted string
+	for _, m := range []struct {
+		content      string
+		isPalindrome bool
+		createdAt    time.Time
+	}{
+		{"kayak", true, day.Add(10*time.Hour + 15*time.Minute)},
+		{"kayak", true, day.Add(10*time.Hour + 45*time.Minute)},
+		{"level", true, day.Add(12 * time.Hour)},
+		{"hello", false, day.Add(33 * time.Hour)},
+		{"kayak", true, day.Add(33 * time.Hour)},
+	} {
+		message := model.NewMessage(m.content, m.isPalindrome)
+		message.CreatedAt, message.UpdatedAt = m.createdAt, m.createdAt
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+		deleted = message.ID
+	}
+	require.NoError(t, repo.DeleteMessage(deleted, 0, context.Background()))
+
+	// Aggregate every message by hour
+	stats, err := repo.MessageStats(model.StatsQuery{Bucket: model.BucketHour, Top: 10}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.Stats{
+		Total:         4,
+		Palindromes:   3,
+		AverageLength: 5,
+		Created: []model.BucketCount{
+			{Start: day.Add(10 * time.Hour), Count: 2},
+			{Start: day.Add(12 * time.Hour), Count: 1},
+			{Start: day.Add(33 * time.Hour), Count: 1},
+		},
+		TopPalindromes: []model.ContentCount{{Content: "kayak", Count: 2}, {Content: "level", Count: 1}},
+	}, stats)
+
+	// Aggregate a range by day, the ties of the palindromes being sorted by content
+	stats, err = repo.MessageStats(model.StatsQuery{From: day.Add(10*time.Hour + 30*time.Minute), To: day.Add(24 * time.Hour), Bucket: model.BucketDay, Top: 1}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.Stats{
+		Total:          2,
+		Palindromes:    2,
+		AverageLength:  5,
+		Created:        []model.BucketCount{{Start: day, Count: 2}},
+		TopPalindromes: []model.ContentCount{{Content: "kayak", Count: 1}},
+	}, stats)
+
+	// Nothing to aggregate
+	stats, err = repo.MessageStats(model.StatsQuery{From: day.Add(48 * time.Hour), Bucket: model.BucketDay, Top: 10}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.Stats{}, stats)
+}
+
 func TestListMessages(t *testing.T) {
 	// Create a Repo instance
 	repo := NewRepo()
@@ -129,6 +636,8 @@ func TestListMessages(t *testing.T) {
 	// Create and save multiple messages
 	message1 := model.NewMessage("message 1", false)
 	message2 := model.NewMessage("message 2", true)
+	message1.ContentHash = model.ContentHash(message1.Content, message1.Mode)
+	message2.ContentHash = model.ContentHash(message2.Content, message2.Mode)
 	_, err := repo.SaveMessage(message1, context.Background())
 	if err != nil {
 		assert.NoError(t, err)
@@ -139,7 +648,8 @@ func TestListMessages(t *testing.T) {
 	}
 
 	// List all messages
-	messages, err := repo.ListMessages(context.Background())
+	page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+	messages := page.Messages
 	assert.NoError(t, err)
 
 	// Check if the retrieved list matches the saved messages
@@ -147,3 +657,65 @@ func TestListMessages(t *testing.T) {
 	assert.Contains(t, messages, message1)
 	assert.Contains(t, messages, message2)
 }
+
+func TestListMessagesQuery(t *testing.T) {
+	// Create a Repo instance
+	repo := NewRepo()
+
+	// Create and save messages created one second apart, the palindromes are the even ones
+	contents := []string{"kayak", "message 1", "level", "message 3", "noon"}
+	start := time.Now().UTC().Truncate(time.Second)
+	var ids []string
+	for i, content := range contents {
+		message := model.NewMessage(content, i%2 == 0)
+		message.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		message.UpdatedAt = start.Add(time.Duration(len(contents)-i) * time.Second)
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+		ids = append(ids, message.ID)
+	}
+
+	// list collects the ids of every page of the query
+	list := func(query model.ListQuery) []string {
+		var listed []string
+		for {
+			page, err := repo.ListMessages(query, context.Background())
+			require.NoError(t, err)
+			for _, message := range page.Messages {
+				listed = append(listed, message.ID)
+			}
+			if page.NextCursor == "" {
+				return listed
+			}
+			require.Len(t, page.Messages, query.Limit)
+			query.Cursor = page.NextCursor
+		}
+	}
+	isPalindrome := true
+
+	tests := []struct {
+		name     string
+		query    model.ListQuery
+		expected []string
+	}{
+		{"PaginateByCreationTime", model.ListQuery{Limit: 2}, ids},
+		{"PaginateDescending", model.ListQuery{Limit: 2, Order: model.Descending}, []string{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{"SortByUpdateTime", model.ListQuery{Limit: 3, SortBy: model.SortByUpdatedAt}, []string{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{"FilterPalindromes", model.ListQuery{Limit: 1, IsPalindrome: &isPalindrome}, []string{ids[0], ids[2], ids[4]}},
+		{"FilterContent", model.ListQuery{Contains: "message"}, []string{ids[1], ids[3]}},
+		{"FilterCreationRange", model.ListQuery{CreatedAfter: start.Add(time.Second), CreatedBefore: start.Add(3 * time.Second)}, []string{ids[1], ids[2]}},
+		{"FilterUpdateRange", model.ListQuery{UpdatedAfter: start.Add(4 * time.Second)}, []string{ids[0], ids[1]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, list(tt.query))
+		})
+	}
+
+	t.Run("CursorOfAnotherSort", func(t *testing.T) {
+		page, err := repo.ListMessages(model.ListQuery{Limit: 1}, context.Background())
+		require.NoError(t, err)
+		_, err = repo.ListMessages(model.ListQuery{Limit: 1, Cursor: page.NextCursor, Order: model.Descending}, context.Background())
+		assert.ErrorIs(t, err, model.ErrInvalidCursor)
+	})
+}
@@ -0,0 +1,89 @@
+// Package sqlutil holds the helpers shared by the SQL implementations of the Database interface.
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/sirupsen/logrus"
+)
+
+// Migration is a schema change identified by the numeric prefix of its file name.
+type Migration struct {
+	Version int
+	Name    string
+	Query   string
+}
+
+// LoadMigrations reads the migrations of the dir directory of fsys sorted by version.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var all []Migration
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s should start with its version: %w", entry.Name(), err)
+		}
+		query, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, Migration{Version: version, Name: entry.Name(), Query: string(query)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// BackfillContentHashes sets the content hash of the messages stored before it was recorded, with the update
+// query setting the content_hash bound first of the message whose id is bound second.
+// The hashes are computed here rather than in a migration, as the databases cannot normalize the contents alike.
+func BackfillContentHashes(ctx context.Context, db *sql.DB, update string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	rows, err := tx.QueryContext(ctx, "SELECT id, content, mode FROM messages WHERE content_hash = ''")
+	if err != nil {
+		return err
+	}
+	hashes := map[string]string{}
+	for rows.Next() {
+		var (
+			id, content string
+			mode        palindrome.Mode
+		)
+		if err := rows.Scan(&id, &content, &mode); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		hashes[id] = model.ContentHash(content, mode)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, update, hash, id); err != nil {
+			return err
+		}
+	}
+	if len(hashes) > 0 {
+		logrus.Infof("content hash of %d messages backfilled", len(hashes))
+	}
+	return tx.Commit()
+}
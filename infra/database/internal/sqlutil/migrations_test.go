@@ -0,0 +1,38 @@
+package sqlutil_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/gharsallahmoez/palindrome/infra/database/internal/sqlutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("SortedByVersion", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/0010_add_index.sql":       {Data: []byte("CREATE INDEX")},
+			"migrations/0002_add_column.sql":      {Data: []byte("ALTER TABLE")},
+			"migrations/0001_create_messages.sql": {Data: []byte("CREATE TABLE")},
+		}
+		all, err := sqlutil.LoadMigrations(fsys, "migrations")
+		require.NoError(t, err)
+		assert.Equal(t, []sqlutil.Migration{
+			{Version: 1, Name: "0001_create_messages.sql", Query: "CREATE TABLE"},
+			{Version: 2, Name: "0002_add_column.sql", Query: "ALTER TABLE"},
+			{Version: 10, Name: "0010_add_index.sql", Query: "CREATE INDEX"},
+		}, all)
+	})
+
+	t.Run("MissingVersion", func(t *testing.T) {
+		fsys := fstest.MapFS{"migrations/create_messages.sql": {Data: []byte("CREATE TABLE")}}
+		_, err := sqlutil.LoadMigrations(fsys, "migrations")
+		assert.Error(t, err)
+	})
+
+	t.Run("MissingDirectory", func(t *testing.T) {
+		_, err := sqlutil.LoadMigrations(fstest.MapFS{}, "migrations")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,17 @@
+package sqlutil
+
+// Scanner is implemented by *sql.Row and *sql.Rows.
+type Scanner interface {
+	Scan(dest ...any) error
+}
+
+// ScoreScanner scans a row holding a relevance score after the columns scanned by its caller.
+type ScoreScanner struct {
+	Row   Scanner
+	Score *float64
+}
+
+// Scan scans the row into dest and the score.
+func (s ScoreScanner) Scan(dest ...any) error {
+	return s.Row.Scan(append(dest, s.Score)...)
+}
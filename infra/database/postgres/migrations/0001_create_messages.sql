@@ -0,0 +1,11 @@
+CREATE TABLE messages (
+    id            TEXT PRIMARY KEY,
+    content       TEXT        NOT NULL,
+    is_palindrome BOOLEAN     NOT NULL,
+    mode          TEXT        NOT NULL DEFAULT '',
+    analysis      JSONB       NOT NULL DEFAULT '{}',
+    created_at    TIMESTAMPTZ NOT NULL,
+    updated_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX messages_created_at_idx ON messages (created_at);
@@ -0,0 +1,4 @@
+DROP INDEX messages_created_at_idx;
+
+CREATE INDEX messages_created_at_id_idx ON messages (created_at, id);
+CREATE INDEX messages_updated_at_id_idx ON messages (updated_at, id);
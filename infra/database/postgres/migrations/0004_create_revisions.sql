@@ -0,0 +1,13 @@
+CREATE TABLE revisions (
+    message_id    TEXT        NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    number        BIGINT      NOT NULL,
+    content       TEXT        NOT NULL,
+    is_palindrome BOOLEAN     NOT NULL,
+    mode          TEXT        NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (message_id, number)
+);
+
+-- the messages stored before the revisions were recorded get their current state as first revision
+INSERT INTO revisions (message_id, number, content, is_palindrome, mode, created_at)
+SELECT id, version, content, is_palindrome, mode, updated_at FROM messages;
@@ -0,0 +1,9 @@
+CREATE TABLE idempotency_keys (
+    id           TEXT PRIMARY KEY,
+    request_hash TEXT NOT NULL,
+    response     BYTEA,
+    etag         TEXT NOT NULL DEFAULT '',
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,4 @@
+-- the hashes of the existing messages are backfilled at startup
+ALTER TABLE messages ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX messages_content_hash_idx ON messages (content_hash);
@@ -0,0 +1,4 @@
+-- the simple configuration lower-cases the words without stemming them, like the other storages
+ALTER TABLE messages ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX messages_search_vector_idx ON messages USING GIN (search_vector);
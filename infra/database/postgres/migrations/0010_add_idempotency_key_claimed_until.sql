@@ -0,0 +1 @@
+ALTER TABLE idempotency_keys ADD COLUMN claimed_until TIMESTAMPTZ NOT NULL DEFAULT 'epoch';
@@ -0,0 +1,708 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/infra/database/internal/sqlutil"
+	"github.com/gharsallahmoez/palindrome/model"
+	// register the postgres driver.
+	_ "github.com/lib/pq"
+)
+
+// connectTimeout bounds the time spent connecting and migrating at startup.
+const connectTimeout = 30 * time.Second
+
+// messageColumns lists the columns of the messages table in the order scanned by scanMessage.
+const messageColumns = "id, content, is_palindrome, mode, analysis, version, created_at, updated_at, deleted_at, expires_at, content_hash"
+
+// Repo represents a PostgreSQL repository for messages.
+type Repo struct {
+	db *sql.DB
+	// dedupe enables the deduplication of the saved messages.
+	dedupe bool
+}
+
+// NewRepo opens a connection pool to PostgreSQL and applies the schema migrations.
+func NewRepo(conf config.Database) (*Repo, error) {
+	db, err := sql.Open("postgres", conf.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(conf.MaxOpenConns)
+	db.SetMaxIdleConns(conf.MaxIdleConns)
+	db.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := sqlutil.BackfillContentHashes(ctx, db, "UPDATE messages SET content_hash = $1 WHERE id = $2"); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Repo{db: db, dedupe: conf.Dedupe != ""}, nil
+}
+
+// Close closes the connection pool.
+func (r *Repo) Close() error {
+	return r.db.Close()
+}
+
+// SaveMessage saves a message to the database.
+func (r *Repo) SaveMessage(message model.Message, ctx context.Context) (model.Message, error) {
+	results, err := r.SaveMessages([]model.Message{message}, ctx)
+	if err != nil {
+		return model.Message{}, err
+	}
+	return results[0].Message, results[0].Err
+}
+
+// SaveMessages saves several messages to the database in a single transaction.
+func (r *Repo) SaveMessages(messages []model.Message, ctx context.Context) ([]model.BatchResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	results := make([]model.BatchResult, len(messages))
+	for i, message := range messages {
+		saved, err := insertMessage(ctx, tx, message, r.dedupe)
+		if err != nil && !model.IsItemError(err) {
+			return nil, err
+		}
+		results[i] = model.BatchResult{Message: saved, Err: err}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetMessage retrieves a message from the database.
+func (r *Repo) GetMessage(id string, ctx context.Context) (model.Message, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE id = $1 AND "+live("$2"), id, time.Now())
+	return scanMessage(row)
+}
+
+// UpdateMessage updates the message identified by message.ID in the database.
+func (r *Repo) UpdateMessage(message model.Message, ctx context.Context) (model.Message, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Message{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	updated, err := updateMessage(ctx, tx, message)
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Message{}, err
+	}
+	return updated, nil
+}
+
+// PatchMessage replaces the message identified by id with the result of patch on its current state.
+func (r *Repo) PatchMessage(id string, version int64, patch func(model.Message) (model.Message, error), ctx context.Context) (model.Message, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Message{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	patched, err := patchMessage(ctx, tx, model.Patch{ID: id, Version: version, Apply: patch})
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Message{}, err
+	}
+	return patched, nil
+}
+
+// PatchMessages patches several messages in the database in a single transaction.
+func (r *Repo) PatchMessages(patches []model.Patch, ctx context.Context) ([]model.BatchResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	results := make([]model.BatchResult, len(patches))
+	for i, patch := range patches {
+		patched, err := patchMessage(ctx, tx, patch)
+		if err != nil && !model.IsItemError(err) {
+			return nil, err
+		}
+		results[i] = model.BatchResult{Message: patched, Err: err}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DeleteMessage moves a message to the trash.
+func (r *Repo) DeleteMessage(id string, version int64, ctx context.Context) error {
+	return deleteMessage(ctx, r.db, id, version)
+}
+
+// DeleteMessages moves several messages to the trash in a single transaction.
+func (r *Repo) DeleteMessages(deletions []model.Deletion, ctx context.Context) ([]error, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	errs := make([]error, len(deletions))
+	for i, deletion := range deletions {
+		err := deleteMessage(ctx, tx, deletion.ID, deletion.Version)
+		if err != nil && !model.IsItemError(err) {
+			return nil, err
+		}
+		errs[i] = err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// insertMessage inserts a new message with its content hash and first revision. With dedupe,
+// the stored message with the same content hash is returned with ErrDuplicateMessage instead.
+func insertMessage(ctx context.Context, tx *sql.Tx, message model.Message, dedupe bool) (model.Message, error) {
+	message.ContentHash = model.ContentHash(message.Content, message.Mode)
+	if dedupe {
+		// the lock serializes the concurrent insertions of the same content until the end of the transaction
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", message.ContentHash); err != nil {
+			return model.Message{}, err
+		}
+		row := tx.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE content_hash = $1 AND "+live("$2")+" LIMIT 1",
+			message.ContentHash, time.Now())
+		duplicate, err := scanMessage(row)
+		if err == nil {
+			return duplicate, model.ErrDuplicateMessage
+		}
+		if !errors.Is(err, model.ErrMessageNotFound) {
+			return model.Message{}, err
+		}
+	}
+	analysis, err := json.Marshal(message.Analysis)
+	if err != nil {
+		return model.Message{}, err
+	}
+	// the conflict is reported without failing the transaction
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO messages ("+messageColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) ON CONFLICT (id) DO NOTHING",
+		message.ID, message.Content, message.IsPalindrome, message.Mode, analysis, message.Version,
+		message.CreatedAt, message.UpdatedAt, message.DeletedAt, message.ExpiresAt, message.ContentHash,
+	)
+	if err != nil {
+		return model.Message{}, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return model.Message{}, err
+	}
+	if inserted == 0 {
+		return model.Message{}, model.ErrMessageIDTaken
+	}
+	return message, insertRevision(ctx, tx, message)
+}
+
+// patchMessage replaces a message with the result of a patch on its current state, the message being locked
+// from its read to its write.
+func patchMessage(ctx context.Context, tx *sql.Tx, patch model.Patch) (model.Message, error) {
+	row := tx.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE id = $1 AND "+live("$2")+" FOR UPDATE", patch.ID, time.Now())
+	current, err := scanMessage(row)
+	if err != nil {
+		return model.Message{}, err
+	}
+	if patch.Version != 0 && patch.Version != current.Version {
+		return model.Message{}, model.ErrVersionMismatch
+	}
+	message, err := patch.Apply(current)
+	if err != nil {
+		return model.Message{}, err
+	}
+	analysis, err := json.Marshal(message.Analysis)
+	if err != nil {
+		return model.Message{}, err
+	}
+	row = tx.QueryRowContext(ctx,
+		`UPDATE messages SET content = $2, is_palindrome = $3, mode = $4, analysis = $5, updated_at = $6, version = version + 1,
+		expires_at = $7, content_hash = $8
+		WHERE id = $1 RETURNING `+messageColumns,
+		current.ID, message.Content, message.IsPalindrome, message.Mode, analysis, time.Now(), message.ExpiresAt,
+		model.ContentHash(message.Content, message.Mode),
+	)
+	patched, err := scanMessage(row)
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := insertRevision(ctx, tx, patched); err != nil {
+		return model.Message{}, err
+	}
+	return patched, nil
+}
+
+// updateMessage updates a message, preserving its creation time, and records its new revision.
+func updateMessage(ctx context.Context, tx *sql.Tx, message model.Message) (model.Message, error) {
+	analysis, err := json.Marshal(message.Analysis)
+	if err != nil {
+		return model.Message{}, err
+	}
+	row := tx.QueryRowContext(ctx,
+		`UPDATE messages SET content = $2, is_palindrome = $3, mode = $4, analysis = $5, updated_at = $6, version = version + 1,
+		expires_at = COALESCE($8, expires_at), content_hash = $9
+		WHERE id = $1 AND `+live("$6")+` AND ($7 = 0 OR version = $7) RETURNING `+messageColumns,
+		message.ID, message.Content, message.IsPalindrome, message.Mode, analysis, time.Now(), message.Version, message.ExpiresAt,
+		model.ContentHash(message.Content, message.Mode),
+	)
+	updated, err := scanMessage(row)
+	if errors.Is(err, model.ErrMessageNotFound) && message.Version != 0 {
+		return model.Message{}, versionMismatch(ctx, tx, message.ID)
+	}
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := insertRevision(ctx, tx, updated); err != nil {
+		return model.Message{}, err
+	}
+	return updated, nil
+}
+
+// deleteMessage moves a message to the trash.
+func deleteMessage(ctx context.Context, q queryer, id string, version int64) error {
+	result, err := q.ExecContext(ctx,
+		"UPDATE messages SET deleted_at = $3 WHERE id = $1 AND "+live("$3")+" AND ($2 = 0 OR version = $2)",
+		id, version, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		if version != 0 {
+			return versionMismatch(ctx, q, id)
+		}
+		return model.ErrMessageNotFound
+	}
+	return nil
+}
+
+// versionMismatch tells apart, after a conditional write matched no row, a message
+// at another version from a missing message.
+func versionMismatch(ctx context.Context, q queryer, id string) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM messages WHERE id = $1 AND "+live("$2")+")", id, time.Now()).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return model.ErrVersionMismatch
+	}
+	return model.ErrMessageNotFound
+}
+
+// ListMessages retrieves a page of the messages matching the query from the database.
+func (r *Repo) ListMessages(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+	query = query.WithDefaults()
+	cursor, err := query.DecodeCursor()
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+
+	var (
+		conditions []string
+		args       []any
+	)
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if query.Deleted {
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	conditions = append(conditions, "(expires_at IS NULL OR expires_at > "+arg(time.Now())+")")
+	if query.IsPalindrome != nil {
+		conditions = append(conditions, "is_palindrome = "+arg(*query.IsPalindrome))
+	}
+	if !query.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(query.CreatedAfter))
+	}
+	if !query.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(query.CreatedBefore))
+	}
+	if !query.UpdatedAfter.IsZero() {
+		conditions = append(conditions, "updated_at >= "+arg(query.UpdatedAfter))
+	}
+	if !query.UpdatedBefore.IsZero() {
+		conditions = append(conditions, "updated_at < "+arg(query.UpdatedBefore))
+	}
+	if query.Contains != "" {
+		conditions = append(conditions, fmt.Sprintf("strpos(content, %s) > 0", arg(query.Contains)))
+	}
+	column, direction, comparison := "created_at", "ASC", ">"
+	switch query.SortBy {
+	case model.SortByUpdatedAt:
+		column = "updated_at"
+	case model.SortByDeletedAt:
+		column = "deleted_at"
+	}
+	if query.Order == model.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if cursor != nil {
+		// the row comparison resumes right after the last message of the previous page
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", column, comparison, arg(cursor.Time), arg(cursor.ID)))
+	}
+
+	statement := "SELECT " + messageColumns + " FROM messages WHERE " + strings.Join(conditions, " AND ")
+	statement += fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+	if query.Limit > 0 {
+		// one more message tells whether there is a next page
+		statement += " LIMIT " + arg(query.Limit+1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	var page model.MessagePage
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return model.MessagePage{}, err
+		}
+		page.Messages = append(page.Messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return model.MessagePage{}, err
+	}
+	if query.Limit > 0 && len(page.Messages) > query.Limit {
+		page.Messages = page.Messages[:query.Limit]
+		page.NextCursor = query.NewCursor(page.Messages[query.Limit-1])
+	}
+	return page, nil
+}
+
+// SearchMessages retrieves the live messages matching every term of the query, most relevant first.
+// The messages are matched by their generated text search vector and ranked by ts_rank.
+func (r *Repo) SearchMessages(query model.SearchQuery, ctx context.Context) ([]model.SearchHit, error) {
+	if len(query.Terms) == 0 {
+		return nil, nil
+	}
+	statement := "SELECT " + messageColumns + ", ts_rank(search_vector, search_query) AS score" +
+		" FROM messages, to_tsquery('simple', $1) search_query" +
+		" WHERE search_vector @@ search_query AND " + live("$2") +
+		" ORDER BY score DESC, id LIMIT $3 OFFSET $4"
+	// a null limit means no limit
+	var limit any
+	if query.Limit > 0 {
+		limit = query.Limit
+	}
+
+	rows, err := r.db.QueryContext(ctx, statement, tsQuery(query), time.Now(), limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var hits []model.SearchHit
+	for rows.Next() {
+		var hit model.SearchHit
+		if hit.Message, err = scanMessage(sqlutil.ScoreScanner{Row: rows, Score: &hit.Score}); err != nil {
+			return nil, err
+		}
+		hit.Snippet = query.Snippet(hit.Message.Content)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// tsQuery returns the text search query matching every term of the query.
+// The words hold letters, marks and numbers only, they need no quoting.
+func tsQuery(query model.SearchQuery) string {
+	terms := make([]string, len(query.Terms))
+	for i, term := range query.Terms {
+		terms[i] = "(" + strings.Join(term.Words, " <-> ")
+		if term.Prefix {
+			terms[i] += ":*"
+		}
+		terms[i] += ")"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// MessageStats aggregates the live messages created in the range of the query, in a single read-only
+// transaction seeing a consistent snapshot. The buckets are truncated in UTC.
+func (r *Repo) MessageStats(query model.StatsQuery, ctx context.Context) (model.Stats, error) {
+	args := []any{time.Now()}
+	conditions := live("$1")
+	if !query.From.IsZero() {
+		args = append(args, query.From)
+		conditions += " AND created_at >= $" + strconv.Itoa(len(args))
+	}
+	if !query.To.IsZero() {
+		args = append(args, query.To)
+		conditions += " AND created_at < $" + strconv.Itoa(len(args))
+	}
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return model.Stats{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var stats model.Stats
+	row := tx.QueryRowContext(ctx, "SELECT COUNT(*), COUNT(*) FILTER (WHERE is_palindrome), COALESCE(AVG(char_length(content)), 0)"+
+		" FROM messages WHERE "+conditions, args...)
+	if err := row.Scan(&stats.Total, &stats.Palindromes, &stats.AverageLength); err != nil {
+		return model.Stats{}, err
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT date_trunc($%d, created_at AT TIME ZONE 'UTC') AS bucket, COUNT(*)"+
+		" FROM messages WHERE %s GROUP BY bucket ORDER BY bucket", len(args)+1, conditions), append(args, string(query.Bucket))...)
+	if err != nil {
+		return model.Stats{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var count model.BucketCount
+		if err := rows.Scan(&count.Start, &count.Count); err != nil {
+			return model.Stats{}, err
+		}
+		// the truncated timestamp has no time zone, it is read as UTC
+		count.Start = time.Date(count.Start.Year(), count.Start.Month(), count.Start.Day(), count.Start.Hour(), 0, 0, 0, time.UTC)
+		stats.Created = append(stats.Created, count)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Stats{}, err
+	}
+
+	rows, err = tx.QueryContext(ctx, fmt.Sprintf("SELECT content, COUNT(*) AS count FROM messages WHERE %s"+
+		" AND is_palindrome GROUP BY content ORDER BY count DESC, content COLLATE \"C\" LIMIT $%d", conditions, len(args)+1), append(args, query.Top)...)
+	if err != nil {
+		return model.Stats{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var count model.ContentCount
+		if err := rows.Scan(&count.Content, &count.Count); err != nil {
+			return model.Stats{}, err
+		}
+		stats.TopPalindromes = append(stats.TopPalindromes, count)
+	}
+	return stats, rows.Err()
+}
+
+// DeleteExpiredMessages permanently removes the messages expired at the given time,
+// their revisions being removed by cascade.
+func (r *Repo) DeleteExpiredMessages(now time.Time, ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM messages WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RestoreMessage moves a message out of the trash.
+func (r *Repo) RestoreMessage(id string, ctx context.Context) (model.Message, error) {
+	row := r.db.QueryRowContext(ctx,
+		"UPDATE messages SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING "+messageColumns, id,
+	)
+	return scanMessage(row)
+}
+
+// PurgeMessages permanently removes the messages moved to the trash before the given time,
+// their revisions being removed by cascade.
+func (r *Repo) PurgeMessages(before time.Time, ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM messages WHERE deleted_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// revisionColumns lists the columns of the revisions table in the order scanned by scanRevision.
+const revisionColumns = "message_id, number, content, is_palindrome, mode, created_at"
+
+// ListRevisions retrieves the revisions of a message, oldest first.
+func (r *Repo) ListRevisions(id string, ctx context.Context) ([]model.Revision, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+revisionColumns+" FROM revisions WHERE message_id = $1 AND message_id IN (SELECT id FROM messages WHERE "+live("$2")+") ORDER BY number",
+		id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var revisions []model.Revision
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		// every stored message has at least one revision
+		return nil, model.ErrMessageNotFound
+	}
+	return revisions, nil
+}
+
+// GetRevision retrieves the revision of a message at the given version.
+func (r *Repo) GetRevision(id string, number int64, ctx context.Context) (model.Revision, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+revisionColumns+" FROM revisions WHERE message_id = $1 AND message_id IN (SELECT id FROM messages WHERE "+live("$3")+") AND number = $2",
+		id, number, time.Now())
+	revision, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.GetMessage(id, ctx); err != nil {
+			return model.Revision{}, err
+		}
+		return model.Revision{}, model.ErrRevisionNotFound
+	}
+	return revision, err
+}
+
+// idempotencyKeyColumns lists the columns of the idempotency_keys table in the order scanned by scanIdempotencyKey.
+const idempotencyKeyColumns = "id, request_hash, response, etag, expires_at, claimed_until"
+
+// ClaimIdempotencyKey stores a new idempotency key without response, unless it is already stored and not claimable.
+func (r *Repo) ClaimIdempotencyKey(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	// an expired key, or one whose claim lapsed before the response was recorded, is claimed again as if it was not stored
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (id, request_hash, expires_at, claimed_until) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET request_hash = excluded.request_hash, response = NULL, etag = '',
+			expires_at = excluded.expires_at, claimed_until = excluded.claimed_until
+		WHERE idempotency_keys.expires_at <= $5 OR (idempotency_keys.response IS NULL AND idempotency_keys.claimed_until <= $5)`,
+		key.Key, key.RequestHash, key.ExpiresAt, key.ClaimedUntil, time.Now(),
+	)
+	if err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	if claimed == 0 {
+		stored, err := scanIdempotencyKey(tx.QueryRowContext(ctx, "SELECT "+idempotencyKeyColumns+" FROM idempotency_keys WHERE id = $1", key.Key))
+		if err != nil {
+			return model.IdempotencyKey{}, err
+		}
+		return stored, model.ErrIdempotencyKeyClaimed
+	}
+	if err := tx.Commit(); err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	key.Response, key.ETag = nil, ""
+	return key, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed idempotency key.
+func (r *Repo) CompleteIdempotencyKey(key model.IdempotencyKey, ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE idempotency_keys SET response = $1, etag = $2 WHERE id = $3", key.Response, key.ETag, key.Key)
+	return err
+}
+
+// ReleaseIdempotencyKey removes a claimed idempotency key.
+func (r *Repo) ReleaseIdempotencyKey(key string, ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE id = $1", key)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys removes the idempotency keys expired at the given time.
+func (r *Repo) DeleteExpiredIdempotencyKeys(now time.Time, ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// insertRevision records the revision of the current state of a message.
+func insertRevision(ctx context.Context, tx *sql.Tx, message model.Message) error {
+	revision := model.NewRevision(message)
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO revisions ("+revisionColumns+") VALUES ($1, $2, $3, $4, $5, $6)",
+		revision.MessageID, revision.Number, revision.Content, revision.IsPalindrome, revision.Mode, revision.CreatedAt,
+	)
+	return err
+}
+
+// live returns the condition on the messages neither deleted nor expired at the time of the given placeholder.
+func live(now string) string {
+	return "deleted_at IS NULL AND (expires_at IS NULL OR expires_at > " + now + ")"
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// scanMessage reads a message from a row holding messageColumns.
+func scanMessage(row sqlutil.Scanner) (model.Message, error) {
+	var (
+		message   model.Message
+		analysis  []byte
+		deletedAt sql.NullTime
+		expiresAt sql.NullTime
+	)
+	err := row.Scan(&message.ID, &message.Content, &message.IsPalindrome, &message.Mode, &analysis, &message.Version,
+		&message.CreatedAt, &message.UpdatedAt, &deletedAt, &expiresAt, &message.ContentHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Message{}, model.ErrMessageNotFound
+	}
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := json.Unmarshal(analysis, &message.Analysis); err != nil {
+		return model.Message{}, err
+	}
+	if deletedAt.Valid {
+		message.DeletedAt = &deletedAt.Time
+	}
+	if expiresAt.Valid {
+		message.ExpiresAt = &expiresAt.Time
+	}
+	return message, nil
+}
+
+// scanIdempotencyKey reads an idempotency key from a row holding idempotencyKeyColumns.
+func scanIdempotencyKey(row sqlutil.Scanner) (model.IdempotencyKey, error) {
+	var key model.IdempotencyKey
+	err := row.Scan(&key.Key, &key.RequestHash, &key.Response, &key.ETag, &key.ExpiresAt, &key.ClaimedUntil)
+	return key, err
+}
+
+// scanRevision reads a revision from a row holding revisionColumns.
+func scanRevision(row sqlutil.Scanner) (model.Revision, error) {
+	var revision model.Revision
+	err := row.Scan(&revision.MessageID, &revision.Number, &revision.Content, &revision.IsPalindrome, &revision.Mode, &revision.CreatedAt)
+	return revision, err
+}
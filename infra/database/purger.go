@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Purger periodically and permanently removes the messages deleted for longer than the trash retention.
+type Purger struct {
+	background
+	database Database
+	conf     config.Trash
+}
+
+// NewPurger creates a purger of the database trash.
+func NewPurger(db Database, conf config.Trash) *Purger {
+	return &Purger{background: newBackground(), database: db, conf: conf}
+}
+
+// Start runs the purges in the background until Stop is called.
+// Nothing is purged when the retention or the purge interval is 0.
+func (p *Purger) Start() {
+	if p.conf.Retention <= 0 || p.conf.PurgeInterval <= 0 {
+		return
+	}
+	p.run(p.conf.PurgeInterval, "purge the trash", func(ctx context.Context) error {
+		_, err := p.Purge(ctx)
+		return err
+	})
+}
+
+// Purge permanently removes the messages deleted for longer than the retention and returns their number.
+func (p *Purger) Purge(ctx context.Context) (int64, error) {
+	purged, err := p.database.PurgeMessages(time.Now().Add(-p.conf.Retention), ctx)
+	if purged > 0 {
+		logrus.Infof("%d messages purged from the trash", purged)
+	}
+	return purged, err
+}
@@ -0,0 +1,67 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/infra/database"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPurger tests the trash purges.
+func TestPurger(t *testing.T) {
+	t.Run("purge expired messages", func(t *testing.T) {
+		db, err := database.Create(config.Database{Type: "in-memory"})
+		require.NoError(t, err)
+
+		// Delete a message and keep another one
+		deleted := model.NewMessage("deleted message", false)
+		kept := model.NewMessage("kept message", false)
+		for _, message := range []model.Message{deleted, kept} {
+			_, err = db.SaveMessage(message, context.Background())
+			require.NoError(t, err)
+		}
+		require.NoError(t, db.DeleteMessage(deleted.ID, 0, context.Background()))
+
+		// The deleted message is kept within the retention
+		purged, err := database.NewPurger(db, config.Trash{Retention: time.Hour}).Purge(context.Background())
+		assert.NoError(t, err)
+		assert.Zero(t, purged)
+
+		// Then it is removed for good
+		purged, err = database.NewPurger(db, config.Trash{Retention: time.Nanosecond}).Purge(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		_, err = db.RestoreMessage(deleted.ID, context.Background())
+		assert.ErrorIs(t, err, model.ErrMessageNotFound)
+		_, err = db.GetMessage(kept.ID, context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("purge in the background", func(t *testing.T) {
+		db, err := database.Create(config.Database{Type: "in-memory"})
+		require.NoError(t, err)
+		message := model.NewMessage("deleted message", false)
+		_, err = db.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+		require.NoError(t, db.DeleteMessage(message.ID, 0, context.Background()))
+
+		purger := database.NewPurger(db, config.Trash{Retention: time.Nanosecond, PurgeInterval: time.Millisecond})
+		purger.Start()
+		defer purger.Stop()
+		assert.Eventually(t, func() bool {
+			page, err := db.ListMessages(model.ListQuery{Deleted: true}, context.Background())
+			return err == nil && len(page.Messages) == 0
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		purger := database.NewPurger(nil, config.Trash{PurgeInterval: time.Millisecond})
+		purger.Start()
+		purger.Stop()
+	})
+}
@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/gharsallahmoez/palindrome/infra/database/internal/sqlutil"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrate applies, in order and each in its own transaction, the migrations newer than
+// the schema version, which is tracked in the user_version pragma of the database file.
+func migrate(ctx context.Context, db *sql.DB) error {
+	pending, err := sqlutil.LoadMigrations(migrations, "migrations")
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&current); err != nil {
+		return fmt.Errorf("failed to read the schema version: %w", err)
+	}
+
+	for _, m := range pending {
+		if m.Version <= current {
+			continue
+		}
+		if err := apply(ctx, db, m); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", m.Name, err)
+		}
+		logrus.Infof("migration %s applied successfully", m.Name)
+	}
+	return nil
+}
+
+// apply runs a migration and bumps the schema version in the same transaction.
+func apply(ctx context.Context, db *sql.DB, m sqlutil.Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, m.Query); err != nil {
+		return err
+	}
+	// pragmas do not accept bound parameters.
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", m.Version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,11 @@
+CREATE TABLE messages (
+    id            TEXT PRIMARY KEY,
+    content       TEXT    NOT NULL,
+    is_palindrome INTEGER NOT NULL,
+    mode          TEXT    NOT NULL DEFAULT '',
+    analysis      TEXT    NOT NULL DEFAULT '{}',
+    created_at    TEXT    NOT NULL,
+    updated_at    TEXT    NOT NULL
+);
+
+CREATE INDEX messages_created_at_idx ON messages (created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE messages ADD COLUMN deleted_at TEXT;
+
+CREATE INDEX messages_deleted_at_idx ON messages (deleted_at) WHERE deleted_at IS NOT NULL;
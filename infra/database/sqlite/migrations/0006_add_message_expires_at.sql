@@ -0,0 +1,3 @@
+ALTER TABLE messages ADD COLUMN expires_at TEXT;
+
+CREATE INDEX messages_expires_at_idx ON messages (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,17 @@
+-- the words of the contents are indexed by FTS5, kept in sync by triggers. The index holds its own copy
+-- of the contents keyed by message id, as the implicit rowid of the messages may change on VACUUM.
+CREATE VIRTUAL TABLE messages_fts USING fts5(id UNINDEXED, content, tokenize = 'unicode61 remove_diacritics 0');
+
+INSERT INTO messages_fts (id, content) SELECT id, content FROM messages;
+
+CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_fts (id, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+    UPDATE messages_fts SET content = new.content WHERE id = old.id;
+END;
+
+CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+    DELETE FROM messages_fts WHERE id = old.id;
+END;
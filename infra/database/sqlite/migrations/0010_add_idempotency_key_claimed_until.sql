@@ -0,0 +1 @@
+ALTER TABLE idempotency_keys ADD COLUMN claimed_until TEXT NOT NULL DEFAULT '1970-01-01T00:00:00.000000000Z';
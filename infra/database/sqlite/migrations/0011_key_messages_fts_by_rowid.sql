@@ -0,0 +1,31 @@
+-- the index is keyed by a stable integer rowid rather than an unindexed id column, which made the triggers scan
+-- the whole index: messages_fts_ids assigns a rowid of the index to every message id.
+DROP TRIGGER messages_fts_insert;
+DROP TRIGGER messages_fts_update;
+DROP TRIGGER messages_fts_delete;
+DROP TABLE messages_fts;
+
+CREATE TABLE messages_fts_ids (
+    fts_rowid INTEGER PRIMARY KEY,
+    id        TEXT NOT NULL UNIQUE
+);
+
+CREATE VIRTUAL TABLE messages_fts USING fts5(content, tokenize = 'unicode61 remove_diacritics 0');
+
+INSERT INTO messages_fts_ids (id) SELECT id FROM messages;
+
+INSERT INTO messages_fts (rowid, content) SELECT fts_rowid, content FROM messages JOIN messages_fts_ids USING (id);
+
+CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+    INSERT INTO messages_fts_ids (id) VALUES (new.id);
+    INSERT INTO messages_fts (rowid, content) VALUES ((SELECT fts_rowid FROM messages_fts_ids WHERE id = new.id), new.content);
+END;
+
+CREATE TRIGGER messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+    UPDATE messages_fts SET content = new.content WHERE rowid = (SELECT fts_rowid FROM messages_fts_ids WHERE id = old.id);
+END;
+
+CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+    DELETE FROM messages_fts WHERE rowid = (SELECT fts_rowid FROM messages_fts_ids WHERE id = old.id);
+    DELETE FROM messages_fts_ids WHERE id = old.id;
+END;
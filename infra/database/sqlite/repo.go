@@ -0,0 +1,807 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/infra/database/internal/sqlutil"
+	"github.com/gharsallahmoez/palindrome/model"
+	// register the sqlite driver.
+	_ "modernc.org/sqlite"
+)
+
+// timeLayout stores the timestamps in UTC with a fixed width, so they sort as text.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// live is the condition on the messages neither deleted nor expired at the time of its placeholder.
+const live = "deleted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)"
+
+// messageColumns lists the columns of the messages table in the order scanned by scanMessage.
+const messageColumns = "id, content, is_palindrome, mode, analysis, version, created_at, updated_at, deleted_at, expires_at, content_hash"
+
+// maxPatchAttempts bounds the attempts of PatchMessage to patch a message updated concurrently.
+const maxPatchAttempts = 3
+
+// Repo represents a SQLite repository for messages, stored in a single file.
+type Repo struct {
+	db *sql.DB
+	// dedupe enables the deduplication of the saved messages.
+	dedupe bool
+}
+
+// NewRepo opens the SQLite database file, creating it if needed, and applies the schema migrations.
+func NewRepo(conf config.Database) (*Repo, error) {
+	// WAL journaling lets the readers run along the single writer, which waits for the lock up to the busy timeout.
+	// The transactions take the write lock as they begin, so that the messages they read cannot change before their writes.
+	pragmas := url.Values{
+		"_pragma": {"busy_timeout(5000)", "journal_mode(WAL)", "synchronous(NORMAL)", "foreign_keys(1)"},
+		"_txlock": {"immediate"},
+	}
+	db, err := sql.Open("sqlite", "file:"+conf.Path+"?"+pragmas.Encode())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(conf.MaxOpenConns)
+	db.SetMaxIdleConns(conf.MaxIdleConns)
+	db.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+
+	if err := migrate(context.Background(), db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := sqlutil.BackfillContentHashes(context.Background(), db, "UPDATE messages SET content_hash = ? WHERE id = ?"); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Repo{db: db, dedupe: conf.Dedupe != ""}, nil
+}
+
+// Close closes the database file.
+func (r *Repo) Close() error {
+	return r.db.Close()
+}
+
+// SaveMessage saves a message to the database.
+func (r *Repo) SaveMessage(message model.Message, ctx context.Context) (model.Message, error) {
+	results, err := r.SaveMessages([]model.Message{message}, ctx)
+	if err != nil {
+		return model.Message{}, err
+	}
+	return results[0].Message, results[0].Err
+}
+
+// SaveMessages saves several messages to the database in a single transaction.
+func (r *Repo) SaveMessages(messages []model.Message, ctx context.Context) ([]model.BatchResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	results := make([]model.BatchResult, len(messages))
+	for i, message := range messages {
+		saved, err := insertMessage(ctx, tx, message, r.dedupe)
+		if err != nil && !model.IsItemError(err) {
+			return nil, err
+		}
+		results[i] = model.BatchResult{Message: saved, Err: err}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetMessage retrieves a message from the database.
+func (r *Repo) GetMessage(id string, ctx context.Context) (model.Message, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE id = ? AND "+live, id, formatTime(time.Now()))
+	return scanMessage(row)
+}
+
+// UpdateMessage updates the message identified by message.ID in the database,
+// preserving its creation time.
+func (r *Repo) UpdateMessage(message model.Message, ctx context.Context) (model.Message, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Message{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	updated, err := updateMessage(ctx, tx, message)
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Message{}, err
+	}
+	return updated, nil
+}
+
+// PatchMessage replaces the message identified by id with the result of patch on its current state.
+// The message is read out of the write transaction, so that the readers are not blocked by the patch:
+// it is only written at the version read, the patch being applied again when it has changed meanwhile.
+func (r *Repo) PatchMessage(id string, version int64, patch func(model.Message) (model.Message, error), ctx context.Context) (model.Message, error) {
+	for attempt := 1; ; attempt++ {
+		current, err := r.GetMessage(id, ctx)
+		if err != nil {
+			return model.Message{}, err
+		}
+		if version != 0 && version != current.Version {
+			return model.Message{}, model.ErrVersionMismatch
+		}
+		message, err := patch(current)
+		if err != nil {
+			return model.Message{}, err
+		}
+		message.ID, message.Version = current.ID, current.Version
+		patched, err := r.replaceMessage(message, ctx)
+		if errors.Is(err, model.ErrVersionMismatch) && version == 0 && attempt < maxPatchAttempts {
+			continue
+		}
+		return patched, err
+	}
+}
+
+// replaceMessage replaces a message at message.Version in its own transaction.
+func (r *Repo) replaceMessage(message model.Message, ctx context.Context) (model.Message, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Message{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	patched, err := replaceMessage(ctx, tx, message)
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Message{}, err
+	}
+	return patched, nil
+}
+
+// PatchMessages patches several messages in the database in a single transaction,
+// which reads every message along with its write.
+func (r *Repo) PatchMessages(patches []model.Patch, ctx context.Context) ([]model.BatchResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	results := make([]model.BatchResult, len(patches))
+	for i, patch := range patches {
+		patched, err := patchMessage(ctx, tx, patch)
+		if err != nil && !model.IsItemError(err) {
+			return nil, err
+		}
+		results[i] = model.BatchResult{Message: patched, Err: err}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DeleteMessage moves a message to the trash.
+func (r *Repo) DeleteMessage(id string, version int64, ctx context.Context) error {
+	return deleteMessage(ctx, r.db, id, version)
+}
+
+// DeleteMessages moves several messages to the trash in a single transaction.
+func (r *Repo) DeleteMessages(deletions []model.Deletion, ctx context.Context) ([]error, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	errs := make([]error, len(deletions))
+	for i, deletion := range deletions {
+		err := deleteMessage(ctx, tx, deletion.ID, deletion.Version)
+		if err != nil && !model.IsItemError(err) {
+			return nil, err
+		}
+		errs[i] = err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// insertMessage inserts a new message with its content hash and first revision. With dedupe,
+// the stored message with the same content hash is returned with ErrDuplicateMessage instead.
+func insertMessage(ctx context.Context, tx *sql.Tx, message model.Message, dedupe bool) (model.Message, error) {
+	message.ContentHash = model.ContentHash(message.Content, message.Mode)
+	if dedupe {
+		// the transaction fails rather than inserting a duplicate if another one inserts it concurrently
+		row := tx.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE content_hash = ? AND "+live+" LIMIT 1",
+			message.ContentHash, formatTime(time.Now()))
+		duplicate, err := scanMessage(row)
+		if err == nil {
+			return duplicate, model.ErrDuplicateMessage
+		}
+		if !errors.Is(err, model.ErrMessageNotFound) {
+			return model.Message{}, err
+		}
+	}
+	analysis, err := json.Marshal(message.Analysis)
+	if err != nil {
+		return model.Message{}, err
+	}
+	// the conflict is reported without failing the transaction
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO messages ("+messageColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING",
+		message.ID, message.Content, message.IsPalindrome, message.Mode, string(analysis), message.Version,
+		formatTime(message.CreatedAt), formatTime(message.UpdatedAt), formatNullTime(message.DeletedAt), formatNullTime(message.ExpiresAt),
+		message.ContentHash,
+	)
+	if err != nil {
+		return model.Message{}, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return model.Message{}, err
+	}
+	if inserted == 0 {
+		return model.Message{}, model.ErrMessageIDTaken
+	}
+	return message, insertRevision(ctx, tx, message)
+}
+
+// patchMessage replaces a message with the result of a patch on its current state.
+func patchMessage(ctx context.Context, tx *sql.Tx, patch model.Patch) (model.Message, error) {
+	row := tx.QueryRowContext(ctx, "SELECT "+messageColumns+" FROM messages WHERE id = ? AND "+live, patch.ID, formatTime(time.Now()))
+	current, err := scanMessage(row)
+	if err != nil {
+		return model.Message{}, err
+	}
+	if patch.Version != 0 && patch.Version != current.Version {
+		return model.Message{}, model.ErrVersionMismatch
+	}
+	message, err := patch.Apply(current)
+	if err != nil {
+		return model.Message{}, err
+	}
+	message.ID, message.Version = current.ID, current.Version
+	return replaceMessage(ctx, tx, message)
+}
+
+// replaceMessage replaces a message at message.Version, its expiry included, and records its new revision.
+func replaceMessage(ctx context.Context, tx *sql.Tx, message model.Message) (model.Message, error) {
+	analysis, err := json.Marshal(message.Analysis)
+	if err != nil {
+		return model.Message{}, err
+	}
+	now := time.Now()
+	row := tx.QueryRowContext(ctx,
+		`UPDATE messages SET content = ?, is_palindrome = ?, mode = ?, analysis = ?, updated_at = ?, version = version + 1,
+		expires_at = ?, content_hash = ?
+		WHERE id = ? AND `+live+` AND version = ? RETURNING `+messageColumns,
+		message.Content, message.IsPalindrome, message.Mode, string(analysis), formatTime(now), formatNullTime(message.ExpiresAt),
+		model.ContentHash(message.Content, message.Mode), message.ID, formatTime(now), message.Version,
+	)
+	patched, err := scanMessage(row)
+	if errors.Is(err, model.ErrMessageNotFound) {
+		return model.Message{}, versionMismatch(ctx, tx, message.ID)
+	}
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := insertRevision(ctx, tx, patched); err != nil {
+		return model.Message{}, err
+	}
+	return patched, nil
+}
+
+// updateMessage updates a message, preserving its creation time, and records its new revision.
+func updateMessage(ctx context.Context, tx *sql.Tx, message model.Message) (model.Message, error) {
+	analysis, err := json.Marshal(message.Analysis)
+	if err != nil {
+		return model.Message{}, err
+	}
+	now := time.Now()
+	row := tx.QueryRowContext(ctx,
+		`UPDATE messages SET content = ?, is_palindrome = ?, mode = ?, analysis = ?, updated_at = ?, version = version + 1,
+		expires_at = COALESCE(?, expires_at), content_hash = ?
+		WHERE id = ? AND `+live+` AND (? = 0 OR version = ?) RETURNING `+messageColumns,
+		message.Content, message.IsPalindrome, message.Mode, string(analysis), formatTime(now), formatNullTime(message.ExpiresAt),
+		model.ContentHash(message.Content, message.Mode), message.ID, formatTime(now), message.Version, message.Version,
+	)
+	updated, err := scanMessage(row)
+	if errors.Is(err, model.ErrMessageNotFound) && message.Version != 0 {
+		return model.Message{}, versionMismatch(ctx, tx, message.ID)
+	}
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := insertRevision(ctx, tx, updated); err != nil {
+		return model.Message{}, err
+	}
+	return updated, nil
+}
+
+// deleteMessage moves a message to the trash.
+func deleteMessage(ctx context.Context, q queryer, id string, version int64) error {
+	now := time.Now()
+	result, err := q.ExecContext(ctx,
+		"UPDATE messages SET deleted_at = ? WHERE id = ? AND "+live+" AND (? = 0 OR version = ?)",
+		formatTime(now), id, formatTime(now), version, version,
+	)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		if version != 0 {
+			return versionMismatch(ctx, q, id)
+		}
+		return model.ErrMessageNotFound
+	}
+	return nil
+}
+
+// versionMismatch tells apart, after a conditional write matched no row, a message
+// at another version from a missing message.
+func versionMismatch(ctx context.Context, q queryer, id string) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM messages WHERE id = ? AND "+live+")", id, formatTime(time.Now())).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return model.ErrVersionMismatch
+	}
+	return model.ErrMessageNotFound
+}
+
+// ListMessages retrieves a page of the messages matching the query from the database.
+func (r *Repo) ListMessages(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+	query = query.WithDefaults()
+	cursor, err := query.DecodeCursor()
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+
+	var (
+		conditions []string
+		args       []any
+	)
+	arg := func(value any) string {
+		args = append(args, value)
+		return "?"
+	}
+	if query.Deleted {
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	conditions = append(conditions, "(expires_at IS NULL OR expires_at > "+arg(formatTime(time.Now()))+")")
+	if query.IsPalindrome != nil {
+		conditions = append(conditions, "is_palindrome = "+arg(*query.IsPalindrome))
+	}
+	if !query.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(formatTime(query.CreatedAfter)))
+	}
+	if !query.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(formatTime(query.CreatedBefore)))
+	}
+	if !query.UpdatedAfter.IsZero() {
+		conditions = append(conditions, "updated_at >= "+arg(formatTime(query.UpdatedAfter)))
+	}
+	if !query.UpdatedBefore.IsZero() {
+		conditions = append(conditions, "updated_at < "+arg(formatTime(query.UpdatedBefore)))
+	}
+	if query.Contains != "" {
+		conditions = append(conditions, fmt.Sprintf("instr(content, %s) > 0", arg(query.Contains)))
+	}
+	column, direction, comparison := "created_at", "ASC", ">"
+	switch query.SortBy {
+	case model.SortByUpdatedAt:
+		column = "updated_at"
+	case model.SortByDeletedAt:
+		column = "deleted_at"
+	}
+	if query.Order == model.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if cursor != nil {
+		// the row comparison resumes right after the last message of the previous page
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", column, comparison, arg(formatTime(cursor.Time)), arg(cursor.ID)))
+	}
+
+	statement := "SELECT " + messageColumns + " FROM messages WHERE " + strings.Join(conditions, " AND ")
+	statement += fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+	if query.Limit > 0 {
+		// one more message tells whether there is a next page
+		statement += " LIMIT " + arg(query.Limit+1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	var page model.MessagePage
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return model.MessagePage{}, err
+		}
+		page.Messages = append(page.Messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return model.MessagePage{}, err
+	}
+	if query.Limit > 0 && len(page.Messages) > query.Limit {
+		page.Messages = page.Messages[:query.Limit]
+		page.NextCursor = query.NewCursor(page.Messages[query.Limit-1])
+	}
+	return page, nil
+}
+
+// SearchMessages retrieves the live messages matching every term of the query, most relevant first.
+// The messages are matched by the FTS5 index and ranked by BM25.
+func (r *Repo) SearchMessages(query model.SearchQuery, ctx context.Context) ([]model.SearchHit, error) {
+	if len(query.Terms) == 0 {
+		return nil, nil
+	}
+	statement := "SELECT " + messageColumns + ", score FROM messages JOIN messages_fts_ids USING (id)" +
+		" JOIN (SELECT rowid AS fts_rowid, -bm25(messages_fts) AS score FROM messages_fts WHERE messages_fts MATCH ?) USING (fts_rowid)" +
+		" WHERE " + live + " ORDER BY score DESC, id LIMIT ? OFFSET ?"
+	// a negative limit means no limit
+	limit := -1
+	if query.Limit > 0 {
+		limit = query.Limit
+	}
+	args := []any{matchExpression(query), formatTime(time.Now()), limit, query.Offset}
+
+	rows, err := r.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var hits []model.SearchHit
+	for rows.Next() {
+		var hit model.SearchHit
+		if hit.Message, err = scanMessage(sqlutil.ScoreScanner{Row: rows, Score: &hit.Score}); err != nil {
+			return nil, err
+		}
+		hit.Snippet = query.Snippet(hit.Message.Content)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// matchExpression returns the FTS5 expression matching every term of the query.
+// The words hold letters, marks and numbers only, they are quoted as FTS5 strings.
+func matchExpression(query model.SearchQuery) string {
+	terms := make([]string, len(query.Terms))
+	for i, term := range query.Terms {
+		terms[i] = `"` + strings.Join(term.Words, " ") + `"`
+		if term.Prefix {
+			terms[i] += " *"
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// bucketLayouts are the layouts of the created_at prefixes starting the buckets.
+var bucketLayouts = map[model.BucketSize]string{
+	model.BucketHour: "2006-01-02T15",
+	model.BucketDay:  "2006-01-02",
+}
+
+// MessageStats aggregates the live messages created in the range of the query, in a single transaction.
+// The buckets group the messages by the prefix of their UTC creation time.
+func (r *Repo) MessageStats(query model.StatsQuery, ctx context.Context) (model.Stats, error) {
+	conditions, args := live, []any{formatTime(time.Now())}
+	if !query.From.IsZero() {
+		conditions += " AND created_at >= ?"
+		args = append(args, formatTime(query.From))
+	}
+	if !query.To.IsZero() {
+		conditions += " AND created_at < ?"
+		args = append(args, formatTime(query.To))
+	}
+	layout := bucketLayouts[query.Bucket]
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Stats{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var stats model.Stats
+	row := tx.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(is_palindrome), 0), COALESCE(AVG(length(content)), 0)"+
+		" FROM messages WHERE "+conditions, args...)
+	if err := row.Scan(&stats.Total, &stats.Palindromes, &stats.AverageLength); err != nil {
+		return model.Stats{}, err
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT substr(created_at, 1, %d) AS bucket, COUNT(*) FROM messages WHERE %s"+
+		" GROUP BY bucket ORDER BY bucket", len(layout), conditions), args...)
+	if err != nil {
+		return model.Stats{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			bucket string
+			count  model.BucketCount
+		)
+		if err := rows.Scan(&bucket, &count.Count); err != nil {
+			return model.Stats{}, err
+		}
+		if count.Start, err = time.Parse(layout, bucket); err != nil {
+			return model.Stats{}, err
+		}
+		stats.Created = append(stats.Created, count)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Stats{}, err
+	}
+
+	rows, err = tx.QueryContext(ctx, "SELECT content, COUNT(*) AS count FROM messages WHERE "+conditions+
+		" AND is_palindrome GROUP BY content ORDER BY count DESC, content LIMIT ?", append(args, query.Top)...)
+	if err != nil {
+		return model.Stats{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var count model.ContentCount
+		if err := rows.Scan(&count.Content, &count.Count); err != nil {
+			return model.Stats{}, err
+		}
+		stats.TopPalindromes = append(stats.TopPalindromes, count)
+	}
+	return stats, rows.Err()
+}
+
+// DeleteExpiredMessages permanently removes the messages expired at the given time,
+// their revisions being removed by cascade.
+func (r *Repo) DeleteExpiredMessages(now time.Time, ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM messages WHERE expires_at <= ?", formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RestoreMessage moves a message out of the trash.
+func (r *Repo) RestoreMessage(id string, ctx context.Context) (model.Message, error) {
+	row := r.db.QueryRowContext(ctx,
+		"UPDATE messages SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL RETURNING "+messageColumns, id,
+	)
+	return scanMessage(row)
+}
+
+// PurgeMessages permanently removes the messages moved to the trash before the given time,
+// their revisions being removed by cascade.
+func (r *Repo) PurgeMessages(before time.Time, ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM messages WHERE deleted_at < ?", formatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// revisionColumns lists the columns of the revisions table in the order scanned by scanRevision.
+const revisionColumns = "message_id, number, content, is_palindrome, mode, created_at"
+
+// ListRevisions retrieves the revisions of a message, oldest first.
+func (r *Repo) ListRevisions(id string, ctx context.Context) ([]model.Revision, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+revisionColumns+" FROM revisions WHERE message_id = ? AND message_id IN (SELECT id FROM messages WHERE "+live+") ORDER BY number",
+		id, formatTime(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var revisions []model.Revision
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		// every stored message has at least one revision
+		return nil, model.ErrMessageNotFound
+	}
+	return revisions, nil
+}
+
+// GetRevision retrieves the revision of a message at the given version.
+func (r *Repo) GetRevision(id string, number int64, ctx context.Context) (model.Revision, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+revisionColumns+" FROM revisions WHERE message_id = ? AND message_id IN (SELECT id FROM messages WHERE "+live+") AND number = ?",
+		id, formatTime(time.Now()), number)
+	revision, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.GetMessage(id, ctx); err != nil {
+			return model.Revision{}, err
+		}
+		return model.Revision{}, model.ErrRevisionNotFound
+	}
+	return revision, err
+}
+
+// idempotencyKeyColumns lists the columns of the idempotency_keys table in the order scanned by scanIdempotencyKey.
+const idempotencyKeyColumns = "id, request_hash, response, etag, expires_at, claimed_until"
+
+// ClaimIdempotencyKey stores a new idempotency key without response, unless it is already stored and not claimable.
+func (r *Repo) ClaimIdempotencyKey(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	// an expired key, or one whose claim lapsed before the response was recorded, is claimed again as if it was not stored
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (id, request_hash, expires_at, claimed_until) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET request_hash = excluded.request_hash, response = NULL, etag = '',
+			expires_at = excluded.expires_at, claimed_until = excluded.claimed_until
+		WHERE idempotency_keys.expires_at <= ? OR (idempotency_keys.response IS NULL AND idempotency_keys.claimed_until <= ?)`,
+		key.Key, key.RequestHash, formatTime(key.ExpiresAt), formatTime(key.ClaimedUntil), formatTime(time.Now()), formatTime(time.Now()),
+	)
+	if err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	if claimed == 0 {
+		stored, err := scanIdempotencyKey(tx.QueryRowContext(ctx, "SELECT "+idempotencyKeyColumns+" FROM idempotency_keys WHERE id = ?", key.Key))
+		if err != nil {
+			return model.IdempotencyKey{}, err
+		}
+		return stored, model.ErrIdempotencyKeyClaimed
+	}
+	if err := tx.Commit(); err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	key.Response, key.ETag = nil, ""
+	return key, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed idempotency key.
+func (r *Repo) CompleteIdempotencyKey(key model.IdempotencyKey, ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE idempotency_keys SET response = ?, etag = ? WHERE id = ?", key.Response, key.ETag, key.Key)
+	return err
+}
+
+// ReleaseIdempotencyKey removes a claimed idempotency key.
+func (r *Repo) ReleaseIdempotencyKey(key string, ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE id = ?", key)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys removes the idempotency keys expired at the given time.
+func (r *Repo) DeleteExpiredIdempotencyKeys(now time.Time, ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= ?", formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// insertRevision records the revision of the current state of a message.
+func insertRevision(ctx context.Context, tx *sql.Tx, message model.Message) error {
+	revision := model.NewRevision(message)
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO revisions ("+revisionColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		revision.MessageID, revision.Number, revision.Content, revision.IsPalindrome, revision.Mode, formatTime(revision.CreatedAt),
+	)
+	return err
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// scanMessage reads a message from a row holding messageColumns.
+func scanMessage(row sqlutil.Scanner) (model.Message, error) {
+	var (
+		message              model.Message
+		analysis             string
+		createdAt, updatedAt string
+		deletedAt, expiresAt sql.NullString
+	)
+	err := row.Scan(&message.ID, &message.Content, &message.IsPalindrome, &message.Mode, &analysis, &message.Version,
+		&createdAt, &updatedAt, &deletedAt, &expiresAt, &message.ContentHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Message{}, model.ErrMessageNotFound
+	}
+	if err != nil {
+		return model.Message{}, err
+	}
+	if err := json.Unmarshal([]byte(analysis), &message.Analysis); err != nil {
+		return model.Message{}, err
+	}
+	if message.CreatedAt, err = parseTime(createdAt); err != nil {
+		return model.Message{}, err
+	}
+	if message.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return model.Message{}, err
+	}
+	if message.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return model.Message{}, err
+	}
+	if message.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+		return model.Message{}, err
+	}
+	return message, nil
+}
+
+// scanRevision reads a revision from a row holding revisionColumns.
+func scanRevision(row sqlutil.Scanner) (model.Revision, error) {
+	var (
+		revision  model.Revision
+		createdAt string
+	)
+	if err := row.Scan(&revision.MessageID, &revision.Number, &revision.Content, &revision.IsPalindrome, &revision.Mode, &createdAt); err != nil {
+		return model.Revision{}, err
+	}
+	var err error
+	revision.CreatedAt, err = parseTime(createdAt)
+	return revision, err
+}
+
+// scanIdempotencyKey reads an idempotency key from a row holding idempotencyKeyColumns.
+func scanIdempotencyKey(row sqlutil.Scanner) (model.IdempotencyKey, error) {
+	var (
+		key                     model.IdempotencyKey
+		expiresAt, claimedUntil string
+	)
+	if err := row.Scan(&key.Key, &key.RequestHash, &key.Response, &key.ETag, &expiresAt, &claimedUntil); err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	var err error
+	if key.ExpiresAt, err = parseTime(expiresAt); err != nil {
+		return model.IdempotencyKey{}, err
+	}
+	key.ClaimedUntil, err = parseTime(claimedUntil)
+	return key, err
+}
+
+// formatTime formats t as stored in the database.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// formatNullTime formats t as stored in the database, nil being stored as NULL.
+func formatNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+// parseTime parses a time stored in the database.
+func parseTime(value string) (time.Time, error) {
+	return time.Parse(timeLayout, value)
+}
+
+// parseNullTime parses a nullable time stored in the database.
+func parseNullTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := parseTime(value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
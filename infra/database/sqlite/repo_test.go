@@ -0,0 +1,757 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/infra/database/internal/sqlutil"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRepo creates a repository backed by a fresh database file.
+func newTestRepo(t *testing.T) *Repo {
+	t.Helper()
+	repo, err := NewRepo(config.Database{Type: "sqlite", Path: filepath.Join(t.TempDir(), "messages.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	return repo
+}
+
+func TestNewRepo(t *testing.T) {
+	t.Run("MigrationsAreIdempotent", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "messages.db")
+		repo, err := NewRepo(config.Database{Type: "sqlite", Path: path})
+		require.NoError(t, err)
+		message := model.NewMessage("kayak", true)
+		_, err = repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+		require.NoError(t, repo.Close())
+
+		// Opening the file again should keep the data and not apply the migrations twice
+		repo, err = NewRepo(config.Database{Type: "sqlite", Path: path})
+		require.NoError(t, err)
+		defer func() { _ = repo.Close() }()
+
+		var version int
+		err = repo.db.QueryRow("PRAGMA user_version").Scan(&version)
+		assert.NoError(t, err)
+		all, err := sqlutil.LoadMigrations(migrations, "migrations")
+		assert.NoError(t, err)
+		assert.Equal(t, all[len(all)-1].Version, version)
+
+		_, err = repo.GetMessage(message.ID, context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("FullTextIndexKeyedByRowid", func(t *testing.T) {
+		// Save a message in a database indexed by message id, before the migration 11
+		path := filepath.Join(t.TempDir(), "messages.db")
+		db, err := sql.Open("sqlite", "file:"+path)
+		require.NoError(t, err)
+		all, err := sqlutil.LoadMigrations(migrations, "migrations")
+		require.NoError(t, err)
+		for _, m := range all {
+			if m.Version < 11 {
+				require.NoError(t, apply(context.Background(), db, m))
+			}
+		}
+		kept := model.NewMessage("a kayak", true)
+		_, err = (&Repo{db: db}).SaveMessage(kept, context.Background())
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		// The migrated index holds the message and follows the writes by rowid
+		repo, err := NewRepo(config.Database{Type: "sqlite", Path: path})
+		require.NoError(t, err)
+		defer func() { _ = repo.Close() }()
+		removed := model.NewMessage("a kayak to remove", true)
+		_, err = repo.SaveMessage(removed, context.Background())
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteMessage(removed.ID, 0, context.Background()))
+		_, err = repo.PurgeMessages(time.Now().Add(time.Minute), context.Background())
+		require.NoError(t, err)
+		hits, err := repo.SearchMessages(model.ParseSearchQuery("kayak"), context.Background())
+		require.NoError(t, err)
+		require.Len(t, hits, 1)
+		assert.Equal(t, kept.ID, hits[0].Message.ID)
+		var indexed int
+		require.NoError(t, repo.db.QueryRow("SELECT COUNT(*) FROM messages_fts_ids").Scan(&indexed))
+		assert.Equal(t, 1, indexed)
+	})
+
+	t.Run("InvalidPath", func(t *testing.T) {
+		_, err := NewRepo(config.Database{Type: "sqlite", Path: filepath.Join(t.TempDir(), "missing", "messages.db")})
+		assert.Error(t, err)
+	})
+}
+
+func TestSaveMessage(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Create and save a message
+	message := model.NewMessage("kayak", true)
+	message.Mode = palindrome.Alphanumeric
+	message.Analysis = palindrome.Analyze("kayak", palindrome.Alphanumeric)
+	message.ContentHash = model.ContentHash(message.Content, message.Mode)
+	savedMessage, err := repo.SaveMessage(message, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, message, savedMessage)
+
+	// Saving the same id twice should fail
+	_, err = repo.SaveMessage(message, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageIDTaken)
+}
+
+func TestGetMessage(t *testing.T) {
+	t.Run("RetrieveExistingMessage", func(t *testing.T) {
+		repo := newTestRepo(t)
+
+		// Create and save a message
+		message := model.NewMessage("kayak", true)
+		message.Mode = palindrome.Alphanumeric
+		message.Analysis = palindrome.Analyze("kayak", palindrome.Alphanumeric)
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+
+		// Retrieve the message
+		retrievedMessage, err := repo.GetMessage(message.ID, context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, message.ID, retrievedMessage.ID)
+		assert.Equal(t, message.Content, retrievedMessage.Content)
+		assert.Equal(t, message.IsPalindrome, retrievedMessage.IsPalindrome)
+		assert.Equal(t, message.Mode, retrievedMessage.Mode)
+		assert.Equal(t, message.Analysis, retrievedMessage.Analysis)
+		assert.True(t, message.CreatedAt.Equal(retrievedMessage.CreatedAt))
+		assert.True(t, message.UpdatedAt.Equal(retrievedMessage.UpdatedAt))
+	})
+
+	t.Run("RetrieveNonExistentMessage", func(t *testing.T) {
+		repo := newTestRepo(t)
+
+		_, err := repo.GetMessage("non-existent-id", context.Background())
+		assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	})
+}
+
+func TestUpdateMessage(t *testing.T) {
+	t.Run("UpdateExistingMessage", func(t *testing.T) {
+		repo := newTestRepo(t)
+
+		// Create and save a message
+		message := model.NewMessage("test message", false)
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+
+		// Update the message
+		update := model.Message{ID: message.ID, Content: "updated message", IsPalindrome: true, Mode: palindrome.Word}
+		updatedMessage, err := repo.UpdateMessage(update, context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "updated message", updatedMessage.Content)
+		assert.True(t, updatedMessage.IsPalindrome)
+		assert.Equal(t, palindrome.Word, updatedMessage.Mode)
+		assert.True(t, message.CreatedAt.Equal(updatedMessage.CreatedAt))
+		assert.True(t, updatedMessage.UpdatedAt.After(message.UpdatedAt))
+	})
+
+	t.Run("UpdateNonExistentMessage", func(t *testing.T) {
+		repo := newTestRepo(t)
+
+		_, err := repo.UpdateMessage(model.Message{ID: "non-existent-id", Content: "new content"}, context.Background())
+		assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	})
+}
+
+func TestPatchMessage(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Create and save a message expiring in an hour
+	message := model.NewMessage("test message", false)
+	expiresAt := time.Now().Add(time.Hour)
+	message.ExpiresAt = &expiresAt
+	_, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+
+	// Patch the content and clear the expiry
+	patched, err := repo.PatchMessage(message.ID, 1, func(current model.Message) (model.Message, error) {
+		assert.Equal(t, "test message", current.Content)
+		current.Content = "kayak"
+		current.IsPalindrome = true
+		current.ExpiresAt = nil
+		return current, nil
+	}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "kayak", patched.Content)
+	assert.True(t, patched.IsPalindrome)
+	assert.Nil(t, patched.ExpiresAt)
+	assert.Equal(t, int64(2), patched.Version)
+	assert.Equal(t, model.ContentHash("kayak", patched.Mode), patched.ContentHash)
+
+	// The patch is recorded as a revision
+	revisions, err := repo.ListRevisions(message.ID, context.Background())
+	require.NoError(t, err)
+	assert.Len(t, revisions, 2)
+
+	// A stale version is rejected without patching
+	_, err = repo.PatchMessage(message.ID, 1, func(current model.Message) (model.Message, error) {
+		t.Error("patch should not be called")
+		return current, nil
+	}, context.Background())
+	assert.ErrorIs(t, err, model.ErrVersionMismatch)
+
+	// The error of the patch fails the patch
+	_, err = repo.PatchMessage(message.ID, 0, func(model.Message) (model.Message, error) {
+		return model.Message{}, model.ErrInvalidRequest
+	}, context.Background())
+	assert.ErrorIs(t, err, model.ErrInvalidRequest)
+	stored, err := repo.GetMessage(message.ID, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stored.Version)
+
+	// A missing message is not found
+	_, err = repo.PatchMessage("non-existent-id", 0, func(current model.Message) (model.Message, error) {
+		return current, nil
+	}, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+}
+
+func TestDeleteMessage(t *testing.T) {
+	t.Run("DeleteExistingMessage", func(t *testing.T) {
+		repo := newTestRepo(t)
+
+		// Create and save a message
+		message := model.NewMessage("test message", false)
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+
+		// Delete the message
+		err = repo.DeleteMessage(message.ID, 0, context.Background())
+		assert.NoError(t, err)
+
+		// Try to retrieve the deleted message
+		_, err = repo.GetMessage(message.ID, context.Background())
+		assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	})
+
+	t.Run("DeleteNonExistentMessage", func(t *testing.T) {
+		repo := newTestRepo(t)
+
+		err := repo.DeleteMessage("non-existent-id", 0, context.Background())
+		assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	})
+}
+
+func TestConditionalWrites(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Create and save a message at version 1
+	message := model.NewMessage("test message", false)
+	_, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+
+	// Updating at the current version should increment it
+	update := model.Message{ID: message.ID, Content: "kayak", IsPalindrome: true, Version: 1}
+	updatedMessage, err := repo.UpdateMessage(update, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updatedMessage.Version)
+
+	// Updating or deleting at a stale version should fail
+	_, err = repo.UpdateMessage(update, context.Background())
+	assert.ErrorIs(t, err, model.ErrVersionMismatch)
+	err = repo.DeleteMessage(message.ID, 1, context.Background())
+	assert.ErrorIs(t, err, model.ErrVersionMismatch)
+
+	// Updating without a version should always succeed
+	update.Version = 0
+	updatedMessage, err = repo.UpdateMessage(update, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), updatedMessage.Version)
+
+	// A missing message is not found whatever the version
+	_, err = repo.UpdateMessage(model.Message{ID: "non-existent-id", Version: 1}, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	err = repo.DeleteMessage("non-existent-id", 1, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+
+	// Deleting at the current version should succeed
+	err = repo.DeleteMessage(message.ID, 3, context.Background())
+	assert.NoError(t, err)
+}
+
+func TestRevisions(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Create, save and update a message twice
+	message := model.NewMessage("kayak", true)
+	_, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+	for _, content := range []string{"kayaks", "level"} {
+		_, err = repo.UpdateMessage(model.Message{ID: message.ID, Content: content, IsPalindrome: content == "level"}, context.Background())
+		require.NoError(t, err)
+	}
+
+	// Every state should be recorded, oldest first
+	revisions, err := repo.ListRevisions(message.ID, context.Background())
+	assert.NoError(t, err)
+	require.Len(t, revisions, 3)
+	for i, content := range []string{"kayak", "kayaks", "level"} {
+		assert.Equal(t, message.ID, revisions[i].MessageID)
+		assert.Equal(t, int64(i+1), revisions[i].Number)
+		assert.Equal(t, content, revisions[i].Content)
+	}
+	assert.False(t, revisions[1].IsPalindrome)
+
+	// Retrieve a revision
+	revision, err := repo.GetRevision(message.ID, 2, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "kayaks", revision.Content)
+	_, err = repo.GetRevision(message.ID, 4, context.Background())
+	assert.ErrorIs(t, err, model.ErrRevisionNotFound)
+
+	// The revisions are deleted with their message
+	require.NoError(t, repo.DeleteMessage(message.ID, 0, context.Background()))
+	_, err = repo.ListRevisions(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	_, err = repo.GetRevision(message.ID, 1, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+}
+
+func TestTrash(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Create, save and delete a message
+	message := model.NewMessage("kayak", true)
+	_, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteMessage(message.ID, 0, context.Background()))
+
+	// The deleted message is hidden
+	_, err = repo.GetMessage(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	_, err = repo.UpdateMessage(model.Message{ID: message.ID, Content: "level"}, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	assert.ErrorIs(t, repo.DeleteMessage(message.ID, 0, context.Background()), model.ErrMessageNotFound)
+	_, err = repo.ListRevisions(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, page.Messages)
+
+	// but listed in the trash
+	page, err = repo.ListMessages(model.ListQuery{Deleted: true, SortBy: model.SortByDeletedAt}, context.Background())
+	assert.NoError(t, err)
+	require.Len(t, page.Messages, 1)
+	assert.Equal(t, message.ID, page.Messages[0].ID)
+	assert.NotNil(t, page.Messages[0].DeletedAt)
+
+	// Restore the message
+	restored, err := repo.RestoreMessage(message.ID, context.Background())
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, message.Content, restored.Content)
+	_, err = repo.GetMessage(message.ID, context.Background())
+	assert.NoError(t, err)
+	_, err = repo.RestoreMessage(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+
+	// Delete it again and purge the trash
+	require.NoError(t, repo.DeleteMessage(message.ID, 0, context.Background()))
+	purged, err := repo.PurgeMessages(time.Now().Add(-time.Hour), context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = repo.PurgeMessages(time.Now().Add(time.Second), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = repo.RestoreMessage(message.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+}
+
+func TestExpiry(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Save a message expiring in an hour and an expired one
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	live := model.NewMessage("kayak", true)
+	live.ExpiresAt = &expiresAt
+	_, err := repo.SaveMessage(live, context.Background())
+	require.NoError(t, err)
+	expiredAt := time.Now().Add(-time.Second)
+	expired := model.NewMessage("level", true)
+	expired.ExpiresAt = &expiredAt
+	_, err = repo.SaveMessage(expired, context.Background())
+	require.NoError(t, err)
+
+	// The expired message is hidden
+	_, err = repo.GetMessage(expired.ID, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	_, err = repo.UpdateMessage(model.Message{ID: expired.ID, Content: "racecar"}, context.Background())
+	assert.ErrorIs(t, err, model.ErrMessageNotFound)
+	page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+	assert.NoError(t, err)
+	require.Len(t, page.Messages, 1)
+	assert.Equal(t, live.ID, page.Messages[0].ID)
+
+	// An update without expiry keeps the current one
+	updated, err := repo.UpdateMessage(model.Message{ID: live.ID, Content: "racecar"}, context.Background())
+	assert.NoError(t, err)
+	require.NotNil(t, updated.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*updated.ExpiresAt))
+
+	// Only the expired message is deleted
+	deleted, err := repo.DeleteExpiredMessages(time.Now(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	_, err = repo.GetMessage(live.ID, context.Background())
+	assert.NoError(t, err)
+}
+
+func TestBatchWrites(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Save two messages at once
+	saves, err := repo.SaveMessages([]model.Message{model.NewMessage("kayak", true), model.NewMessage("test", false)}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, saves, 2)
+	var saved []model.Message
+	for _, save := range saves {
+		require.NoError(t, save.Err)
+		_, err := repo.GetMessage(save.Message.ID, context.Background())
+		assert.NoError(t, err)
+		saved = append(saved, save.Message)
+	}
+
+	// Patch them along a missing message and a stale version, the first one twice in order
+	appending := func(suffix string) func(model.Message) (model.Message, error) {
+		return func(message model.Message) (model.Message, error) {
+			message.Content += suffix
+			return message, nil
+		}
+	}
+	results, err := repo.PatchMessages([]model.Patch{
+		{ID: saved[0].ID, Apply: appending("s")},
+		{ID: "missing", Apply: appending("s")},
+		{ID: saved[1].ID, Version: 2, Apply: appending("s")},
+		{ID: saved[0].ID, Version: 2, Apply: appending("!")},
+	}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "kayaks", results[0].Message.Content)
+	assert.ErrorIs(t, results[1].Err, model.ErrMessageNotFound)
+	assert.ErrorIs(t, results[2].Err, model.ErrVersionMismatch)
+	assert.NoError(t, results[3].Err)
+	assert.Equal(t, "kayaks!", results[3].Message.Content)
+	assert.Equal(t, int64(3), results[3].Message.Version)
+	revisions, err := repo.ListRevisions(saved[0].ID, context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 3)
+
+	// A failing patch fails the whole batch
+	_, err = repo.PatchMessages([]model.Patch{
+		{ID: saved[1].ID, Apply: appending("s")},
+		{ID: saved[0].ID, Apply: func(model.Message) (model.Message, error) { return model.Message{}, assert.AnError }},
+	}, context.Background())
+	assert.ErrorIs(t, err, assert.AnError)
+	unchanged, err := repo.GetMessage(saved[1].ID, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, saved[1].Content, unchanged.Content)
+	assert.Equal(t, saved[1].Version, unchanged.Version)
+
+	// Delete them along a missing message
+	errs, err := repo.DeleteMessages([]model.Deletion{
+		{ID: saved[0].ID, Version: 3},
+		{ID: "missing"},
+		{ID: saved[1].ID},
+	}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], model.ErrMessageNotFound)
+	assert.NoError(t, errs[2])
+	page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, page.Messages)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Claim a new key
+	key := model.IdempotencyKey{Key: "key", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Hour), ClaimedUntil: time.Now().Add(time.Minute)}
+	claimed, err := repo.ClaimIdempotencyKey(key, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", claimed.RequestHash)
+	assert.False(t, claimed.Completed())
+
+	// Claim it again while it is processed then once completed
+	claimed, err = repo.ClaimIdempotencyKey(model.IdempotencyKey{Key: "key", RequestHash: "other", ExpiresAt: key.ExpiresAt}, context.Background())
+	assert.ErrorIs(t, err, model.ErrIdempotencyKeyClaimed)
+	assert.Equal(t, "hash", claimed.RequestHash)
+	assert.False(t, claimed.Completed())
+	key.Response, key.ETag = []byte(`{"id":"1"}`), `"1"`
+	require.NoError(t, repo.CompleteIdempotencyKey(key, context.Background()))
+	claimed, err = repo.ClaimIdempotencyKey(key, context.Background())
+	assert.ErrorIs(t, err, model.ErrIdempotencyKeyClaimed)
+	assert.Equal(t, key.Response, claimed.Response)
+	assert.Equal(t, key.ETag, claimed.ETag)
+
+	// A released key can be claimed again
+	require.NoError(t, repo.ReleaseIdempotencyKey("key", context.Background()))
+	_, err = repo.ClaimIdempotencyKey(key, context.Background())
+	assert.NoError(t, err)
+
+	// So does a key whose claim lapsed before its response was recorded
+	lapsed := model.IdempotencyKey{Key: "lapsed", RequestHash: "hash", ExpiresAt: key.ExpiresAt, ClaimedUntil: time.Now().Add(-time.Second)}
+	_, err = repo.ClaimIdempotencyKey(lapsed, context.Background())
+	require.NoError(t, err)
+	claimed, err = repo.ClaimIdempotencyKey(model.IdempotencyKey{Key: "lapsed", RequestHash: "hash", ExpiresAt: key.ExpiresAt, ClaimedUntil: key.ClaimedUntil}, context.Background())
+	assert.NoError(t, err)
+	assert.WithinDuration(t, key.ClaimedUntil, claimed.ClaimedUntil, time.Second)
+	_, err = repo.ClaimIdempotencyKey(lapsed, context.Background())
+	assert.ErrorIs(t, err, model.ErrIdempotencyKeyClaimed)
+
+	// So does an expired key, until it is deleted
+	expired := model.IdempotencyKey{Key: "expired", RequestHash: "hash", ExpiresAt: time.Now().Add(-time.Second)}
+	_, err = repo.ClaimIdempotencyKey(expired, context.Background())
+	require.NoError(t, err)
+	claimed, err = repo.ClaimIdempotencyKey(model.IdempotencyKey{Key: "expired", RequestHash: "other", ExpiresAt: key.ExpiresAt}, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "other", claimed.RequestHash)
+	require.NoError(t, repo.ReleaseIdempotencyKey("expired", context.Background()))
+	_, err = repo.ClaimIdempotencyKey(expired, context.Background())
+	require.NoError(t, err)
+	deleted, err := repo.DeleteExpiredIdempotencyKeys(time.Now(), context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestDedupe(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.dedupe = true
+
+	// Save a message and its duplicate, canonically equivalent
+	message := model.NewMessage("E\u0301sope reste ici et se repose", true)
+	saved, err := repo.SaveMessage(message, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.ContentHash(message.Content, message.Mode), saved.ContentHash)
+	duplicate, err := repo.SaveMessage(model.NewMessage("\u00c9sope reste ici et se repose", true), context.Background())
+	assert.ErrorIs(t, err, model.ErrDuplicateMessage)
+	assert.Equal(t, message.ID, duplicate.ID)
+
+	// Within a batch, only the duplicates fail
+	other := model.NewMessage("kayak", true)
+	results, err := repo.SaveMessages([]model.Message{other, model.NewMessage("kayak", true), message}, context.Background())
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, model.ErrDuplicateMessage)
+	assert.Equal(t, other.ID, results[1].Message.ID)
+	assert.ErrorIs(t, results[2].Err, model.ErrDuplicateMessage)
+
+	// An updated message is no longer a duplicate
+	_, err = repo.UpdateMessage(model.Message{ID: message.ID, Content: "level", IsPalindrome: true}, context.Background())
+	require.NoError(t, err)
+	_, err = repo.SaveMessage(model.NewMessage("\u00c9sope reste ici et se repose", true), context.Background())
+	assert.NoError(t, err)
+
+	// Neither is a deleted message
+	require.NoError(t, repo.DeleteMessage(other.ID, 0, context.Background()))
+	_, err = repo.SaveMessage(model.NewMessage("kayak", true), context.Background())
+	assert.NoError(t, err)
+}
+
+func TestSearchMessages(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Save the messages to search
+	kayak := model.NewMessage("A kayak, a racecar and a level", false)
+	level := model.NewMessage("Level up: the level of a Kayak", false)
+	plan := model.NewMessage("A man, a plan, a canal: Panama", true)
+	trashed := model.NewMessage("a kayak in the trash", false)
+	for _, message := range []model.Message{kayak, level, plan, trashed} {
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.DeleteMessage(trashed.ID, 0, context.Background()))
+
+	search := func(text string) []string {
+		query := model.ParseSearchQuery(text)
+		hits, err := repo.SearchMessages(query, context.Background())
+		require.NoError(t, err)
+		ids := make([]string, len(hits))
+		for i, hit := range hits {
+			ids[i] = hit.Message.ID
+			assert.Positive(t, hit.Score)
+			assert.Equal(t, query.Snippet(hit.Message.Content), hit.Snippet)
+		}
+		return ids
+	}
+
+	// Words, prefixes and phrases all match, the deleted messages never do
+	assert.ElementsMatch(t, []string{kayak.ID, level.ID}, search("KAYAK"))
+	assert.Equal(t, []string{level.ID, kayak.ID}, search("level"), "more occurrences should rank higher")
+	assert.Empty(t, search("race* pan*"), "every term should match")
+	assert.Equal(t, []string{plan.ID}, search("pan*"))
+	assert.Equal(t, []string{plan.ID}, search(`"a canal"`))
+	assert.Empty(t, search(`"canal a"`))
+	assert.Empty(t, search("kay"))
+
+	// Limit and offset page through the hits
+	hits, err := repo.SearchMessages(model.SearchQuery{Terms: model.ParseSearchQuery("a").Terms, Limit: 1, Offset: 1}, context.Background())
+	require.NoError(t, err)
+	assert.Len(t, hits, 1)
+
+	// The index follows the updates and the restorations
+	_, err = repo.UpdateMessage(model.Message{ID: plan.ID, Content: "no lemon, no melon", IsPalindrome: true}, context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, search("panama"))
+	assert.Equal(t, []string{plan.ID}, search("melon"))
+	_, err = repo.RestoreMessage(trashed.ID, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{trashed.ID}, search("trash"))
+}
+
+func TestMessageStats(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Save messages created at known times, one of them deleted
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var deleted string
+	for _, m := range []struct {
+		content      string
+		isPalindrome bool
+		createdAt    time.Time
+	}{
+		{"kayak", true, day.Add(10*time.Hour + 15*time.Minute)},
+		{"kayak", true, day.Add(10*time.Hour + 45*time.Minute)},
+		{"level", true, day.Add(12 * time.Hour)},
+		{"hello", false, day.Add(33 * time.Hour)},
+		{"kayak", true, day.Add(33 * time.Hour)},
+	} {
+		message := model.NewMessage(m.content, m.isPalindrome)
+		message.CreatedAt, message.UpdatedAt = m.createdAt, m.createdAt
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+		deleted = message.ID
+	}
+	require.NoError(t, repo.DeleteMessage(deleted, 0, context.Background()))
+
+	// Aggregate every message by hour
+	stats, err := repo.MessageStats(model.StatsQuery{Bucket: model.BucketHour, Top: 10}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.Stats{
+		Total:         4,
+		Palindromes:   3,
+		AverageLength: 5,
+		Created: []model.BucketCount{
+			{Start: day.Add(10 * time.Hour), Count: 2},
+			{Start: day.Add(12 * time.Hour), Count: 1},
+			{Start: day.Add(33 * time.Hour), Count: 1},
+		},
+		TopPalindromes: []model.ContentCount{{Content: "kayak", Count: 2}, {Content: "level", Count: 1}},
+	}, stats)
+
+	// Aggregate a range by day, the ties of the palindromes being sorted by content
+	stats, err = repo.MessageStats(model.StatsQuery{From: day.Add(10*time.Hour + 30*time.Minute), To: day.Add(24 * time.Hour), Bucket: model.BucketDay, Top: 1}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.Stats{
+		Total:          2,
+		Palindromes:    2,
+		AverageLength:  5,
+		Created:        []model.BucketCount{{Start: day, Count: 2}},
+		TopPalindromes: []model.ContentCount{{Content: "kayak", Count: 1}},
+	}, stats)
+
+	// Nothing to aggregate
+	stats, err = repo.MessageStats(model.StatsQuery{From: day.Add(48 * time.Hour), Bucket: model.BucketDay, Top: 10}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, model.Stats{}, stats)
+}
+
+func TestListMessages(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Create and save multiple messages
+	message1 := model.NewMessage("message 1", false)
+	message2 := model.NewMessage("message 2", true)
+	message2.CreatedAt = message1.CreatedAt.Add(time.Second)
+	_, err := repo.SaveMessage(message1, context.Background())
+	require.NoError(t, err)
+	_, err = repo.SaveMessage(message2, context.Background())
+	require.NoError(t, err)
+
+	// List all messages
+	page, err := repo.ListMessages(model.ListQuery{}, context.Background())
+	messages := page.Messages
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, message1.ID, messages[0].ID)
+	assert.Equal(t, message2.ID, messages[1].ID)
+}
+
+func TestListMessagesQuery(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// Create and save messages created one second apart, the palindromes are the even ones
+	contents := []string{"kayak", "message 1", "level", "message 3", "noon"}
+	start := time.Now().UTC().Truncate(time.Second)
+	var ids []string
+	for i, content := range contents {
+		message := model.NewMessage(content, i%2 == 0)
+		message.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		message.UpdatedAt = start.Add(time.Duration(len(contents)-i) * time.Second)
+		_, err := repo.SaveMessage(message, context.Background())
+		require.NoError(t, err)
+		ids = append(ids, message.ID)
+	}
+
+	// list collects the ids of every page of the query
+	list := func(query model.ListQuery) []string {
+		var listed []string
+		for {
+			page, err := repo.ListMessages(query, context.Background())
+			require.NoError(t, err)
+			for _, message := range page.Messages {
+				listed = append(listed, message.ID)
+			}
+			if page.NextCursor == "" {
+				return listed
+			}
+			require.Len(t, page.Messages, query.Limit)
+			query.Cursor = page.NextCursor
+		}
+	}
+	isPalindrome := true
+
+	tests := []struct {
+		name     string
+		query    model.ListQuery
+		expected []string
+	}{
+		{"PaginateByCreationTime", model.ListQuery{Limit: 2}, ids},
+		{"PaginateDescending", model.ListQuery{Limit: 2, Order: model.Descending}, []string{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{"SortByUpdateTime", model.ListQuery{Limit: 3, SortBy: model.SortByUpdatedAt}, []string{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{"FilterPalindromes", model.ListQuery{Limit: 1, IsPalindrome: &isPalindrome}, []string{ids[0], ids[2], ids[4]}},
+		{"FilterContent", model.ListQuery{Contains: "message"}, []string{ids[1], ids[3]}},
+		{"FilterCreationRange", model.ListQuery{CreatedAfter: start.Add(time.Second), CreatedBefore: start.Add(3 * time.Second)}, []string{ids[1], ids[2]}},
+		{"FilterUpdateRange", model.ListQuery{UpdatedAfter: start.Add(4 * time.Second)}, []string{ids[0], ids[1]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, list(tt.query))
+		})
+	}
+
+	t.Run("CursorOfAnotherSort", func(t *testing.T) {
+		page, err := repo.ListMessages(model.ListQuery{Limit: 1}, context.Background())
+		require.NoError(t, err)
+		_, err = repo.ListMessages(model.ListQuery{Limit: 1, Cursor: page.NextCursor, Order: model.Descending}, context.Background())
+		assert.ErrorIs(t, err, model.ErrInvalidCursor)
+	})
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Sweeper periodically and permanently removes the expired messages, which are already hidden
+// by the database until then, and the expired idempotency keys.
+type Sweeper struct {
+	background
+	database Database
+	conf     config.Expiry
+}
+
+// NewSweeper creates a sweeper of the expired messages.
+func NewSweeper(db Database, conf config.Expiry) *Sweeper {
+	return &Sweeper{background: newBackground(), database: db, conf: conf}
+}
+
+// Start runs the sweeps in the background until Stop is called.
+// Nothing is swept when the sweep interval is 0.
+func (s *Sweeper) Start() {
+	if s.conf.SweepInterval <= 0 {
+		return
+	}
+	s.run(s.conf.SweepInterval, "sweep the expired messages", func(ctx context.Context) error {
+		_, err := s.Sweep(ctx)
+		return err
+	})
+}
+
+// Sweep permanently removes the expired messages and idempotency keys, and returns the number of messages.
+func (s *Sweeper) Sweep(ctx context.Context) (int64, error) {
+	now := time.Now()
+	swept, err := s.database.DeleteExpiredMessages(now, ctx)
+	if swept > 0 {
+		logrus.Infof("%d expired messages swept", swept)
+	}
+	if err != nil {
+		return swept, err
+	}
+	keys, err := s.database.DeleteExpiredIdempotencyKeys(now, ctx)
+	if keys > 0 {
+		logrus.Infof("%d expired idempotency keys swept", keys)
+	}
+	return swept, err
+}
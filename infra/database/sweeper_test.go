@@ -0,0 +1,74 @@
+package database_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/infra/database"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSweeper tests the sweeps of the expired messages.
+func TestSweeper(t *testing.T) {
+	t.Run("sweep expired messages", func(t *testing.T) {
+		db, err := database.Create(config.Database{Type: "in-memory"})
+		require.NoError(t, err)
+
+		// Save an expired message and a message without expiry
+		expiresAt := time.Now().Add(-time.Second)
+		expired := model.NewMessage("expired message", false)
+		expired.ExpiresAt = &expiresAt
+		kept := model.NewMessage("kept message", false)
+		for _, message := range []model.Message{expired, kept} {
+			_, err = db.SaveMessage(message, context.Background())
+			require.NoError(t, err)
+		}
+
+		// Claim an expired idempotency key
+		_, err = db.ClaimIdempotencyKey(model.IdempotencyKey{Key: "key", ExpiresAt: expiresAt}, context.Background())
+		require.NoError(t, err)
+
+		swept, err := database.NewSweeper(db, config.Expiry{}).Sweep(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), swept)
+		_, err = db.GetMessage(kept.ID, context.Background())
+		assert.NoError(t, err)
+		keys, err := db.DeleteExpiredIdempotencyKeys(time.Now(), context.Background())
+		assert.NoError(t, err)
+		assert.Zero(t, keys, "The expired key should have been swept")
+	})
+
+	t.Run("sweep in the background", func(t *testing.T) {
+		db, err := database.Create(config.Database{Type: "in-memory"})
+		require.NoError(t, err)
+		counting := &countingSweeps{Database: db}
+
+		sweeper := database.NewSweeper(counting, config.Expiry{SweepInterval: time.Millisecond})
+		sweeper.Start()
+		assert.Eventually(t, func() bool { return counting.sweeps.Load() >= 2 }, time.Second, time.Millisecond)
+		sweeper.Stop()
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		sweeper := database.NewSweeper(nil, config.Expiry{})
+		sweeper.Start()
+		sweeper.Stop()
+	})
+}
+
+// countingSweeps counts the sweeps of a database.
+type countingSweeps struct {
+	database.Database
+	sweeps atomic.Int64
+}
+
+// DeleteExpiredMessages counts the sweep before running it.
+func (c *countingSweeps) DeleteExpiredMessages(now time.Time, ctx context.Context) (int64, error) {
+	c.sweeps.Add(1)
+	return c.Database.DeleteExpiredMessages(now, ctx)
+}
@@ -0,0 +1,30 @@
+package model
+
+import "errors"
+
+// BatchResult is the outcome of one item of a batch write: the written message, or the error of the item.
+type BatchResult struct {
+	Message Message
+	Err     error
+}
+
+// Deletion identifies a message to delete in a batch.
+type Deletion struct {
+	ID string
+	// Version is the version the message is only deleted at, 0 for any version.
+	Version int64
+}
+
+// Patch identifies a message to patch in a batch and the function computing its new state from its current one.
+type Patch struct {
+	ID string
+	// Version is the version the message is only patched at, 0 for any version.
+	Version int64
+	Apply   func(Message) (Message, error)
+}
+
+// IsItemError reports whether an error fails a single item of a batch write rather than the whole batch.
+func IsItemError(err error) bool {
+	return errors.Is(err, ErrMessageNotFound) || errors.Is(err, ErrVersionMismatch) || errors.Is(err, ErrDuplicateMessage) ||
+		errors.Is(err, ErrMessageIDTaken)
+}
@@ -1,7 +1,117 @@
 package model
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind classifies the errors of the catalogue by the way clients should handle them.
+type Kind int
+
+const (
+	// KindInternal is an unexpected failure, such as a storage failure.
+	KindInternal Kind = iota
+	// KindInvalid is a malformed request, which should not be retried as is.
+	KindInvalid
+	// KindNotFound is a missing resource.
+	KindNotFound
+	// KindConflict conflicts with the current state of a resource.
+	KindConflict
+	// KindPreconditionRequired is a request missing a required precondition.
+	KindPreconditionRequired
+	// KindPreconditionFailed is a request whose precondition does not hold.
+	KindPreconditionFailed
+	// KindUnprocessable is a well-formed request which cannot be processed.
+	KindUnprocessable
+	// KindMethodNotAllowed is a request whose method is not supported by its resource.
+	KindMethodNotAllowed
+	// KindTooLarge is a request whose body exceeds the size limit.
+	KindTooLarge
+	// KindUnsupportedMediaType is a request whose body has a media type unsupported by its resource.
+	KindUnsupportedMediaType
+)
+
+// Error is an error of the catalogue. Its code identifies it for clients and never changes,
+// while its message may be more specific than the one of its sentinel.
+type Error struct {
+	Kind Kind
+	Code string
+	// Message describes the error, it is safe to return to clients.
+	Message string
+	// Err is the cause of the error, if any.
+	Err error
+	// Violations are the fields breaking the validation rules, for the validation errors.
+	Violations []Violation
+}
+
+// Violation is a field of a request breaking a validation rule.
+type Violation struct {
+	// Field is the name of the field in the request.
+	Field string
+	// Code identifies the rule for clients, it never changes.
+	Code string
+	// Message describes the violation, it names the field.
+	Message string
+}
+
+// Error returns the message of the error.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an error of the catalogue with the same code,
+// so that the specific errors match their sentinel.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Errorf returns an error with the code of e and a formatted message.
+func (e *Error) Errorf(format string, args ...any) *Error {
+	return &Error{Kind: e.Kind, Code: e.Code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns an error with the code of e whose message is the one of its cause.
+func (e *Error) Wrap(err error) *Error {
+	return &Error{Kind: e.Kind, Code: e.Code, Message: err.Error(), Err: err}
+}
+
+// NewValidationError returns a validation error holding the violations, whose message joins theirs.
+func NewValidationError(violations []Violation) *Error {
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+	}
+	err := ErrValidation.Errorf("%s", strings.Join(messages, "; "))
+	err.Violations = violations
+	return err
+}
 
 var (
-	ErrMessageNotFound = fmt.Errorf("message not found")
+	ErrInternal              = &Error{Kind: KindInternal, Code: "internal", Message: "internal error"}
+	ErrInvalidRequest        = &Error{Kind: KindInvalid, Code: "invalid_request", Message: "invalid request"}
+	ErrMalformedBody         = &Error{Kind: KindInvalid, Code: "malformed_body", Message: "request body should be valid JSON"}
+	ErrInvalidCursor         = &Error{Kind: KindInvalid, Code: "invalid_cursor", Message: "invalid cursor"}
+	ErrRouteNotFound         = &Error{Kind: KindNotFound, Code: "route_not_found", Message: "route not found"}
+	ErrMethodNotAllowed      = &Error{Kind: KindMethodNotAllowed, Code: "method_not_allowed", Message: "method not allowed"}
+	ErrMessageNotFound       = &Error{Kind: KindNotFound, Code: "message_not_found", Message: "message not found"}
+	ErrRevisionNotFound      = &Error{Kind: KindNotFound, Code: "revision_not_found", Message: "revision not found"}
+	ErrDuplicateMessage      = &Error{Kind: KindConflict, Code: "duplicate_message", Message: "message already exists"}
+	ErrMessageIDTaken        = &Error{Kind: KindConflict, Code: "message_id_taken", Message: "message id already taken"}
+	ErrIdempotencyKeyClaimed = &Error{Kind: KindConflict, Code: "idempotency_key_claimed", Message: "idempotency key already claimed"}
+	ErrIdempotencyKeyReused  = &Error{Kind: KindUnprocessable, Code: "idempotency_key_reused", Message: "idempotency key already used with another request"}
+	ErrIfMatchRequired       = &Error{Kind: KindPreconditionRequired, Code: "if_match_required", Message: "If-Match header is required"}
+	ErrVersionMismatch       = &Error{Kind: KindPreconditionFailed, Code: "version_mismatch", Message: "message version mismatch"}
+	ErrContentTooLong        = &Error{Kind: KindUnprocessable, Code: "content_too_long", Message: "content is too long"}
+	ErrValidation            = &Error{Kind: KindUnprocessable, Code: "validation_failed", Message: "request validation failed"}
+	ErrBodyTooLarge          = &Error{Kind: KindTooLarge, Code: "body_too_large", Message: "request body is too large"}
+	ErrUnsupportedMediaType  = &Error{Kind: KindUnsupportedMediaType, Code: "unsupported_media_type", Message: "unsupported media type"}
+	ErrInvalidPatch          = &Error{Kind: KindInvalid, Code: "invalid_patch", Message: "invalid patch"}
+	ErrPatchNotApplicable    = &Error{Kind: KindUnprocessable, Code: "patch_not_applicable", Message: "patch cannot be applied to the message"}
+	ErrPatchTestFailed       = &Error{Kind: KindConflict, Code: "patch_test_failed", Message: "patch test failed"}
 )
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// IdempotencyKey records a request made with an idempotency key and, once it succeeded, its response,
+// replayed to the retries of the request until the key expires.
+type IdempotencyKey struct {
+	Key string
+	// RequestHash identifies the request, the retries must have the same.
+	RequestHash string
+	// Response is the body of the response, nil while the request is processed.
+	Response []byte
+	// ETag is the entity tag of the response.
+	ETag      string
+	ExpiresAt time.Time
+	// ClaimedUntil is when the claim of a request still processed lapses, e.g. after the server processing it
+	// crashed, a retry can then claim the key again.
+	ClaimedUntil time.Time
+}
+
+// Claimable reports whether the key can be claimed again at the given time, because it expired or because
+// the claim of its request lapsed before the response was recorded.
+func (k IdempotencyKey) Claimable(now time.Time) bool {
+	return !now.Before(k.ExpiresAt) || !k.Completed() && !now.Before(k.ClaimedUntil)
+}
+
+// Completed reports whether the response of the request has been recorded.
+func (k IdempotencyKey) Completed() bool {
+	return k.Response != nil
+}
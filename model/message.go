@@ -1,7 +1,11 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
 	"time"
 )
 
@@ -10,8 +14,18 @@ type Message struct {
 	ID           string
 	Content      string
 	IsPalindrome bool
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	Mode         palindrome.Mode
+	Analysis     palindrome.Analysis
+	// Version is incremented by every update, starting from 1 at creation.
+	Version   int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// DeletedAt is set while the message is in the trash.
+	DeletedAt *time.Time
+	// ExpiresAt is set for the messages removed once expired.
+	ExpiresAt *time.Time
+	// ContentHash identifies the messages with the same content and mode, it is set by the database.
+	ContentHash string
 }
 
 // NewMessage creates message model.
@@ -21,8 +35,21 @@ func NewMessage(content string, isPalindrome bool) Message {
 		ID:           uuid.NewString(),
 		Content:      content,
 		IsPalindrome: isPalindrome,
+		Version:      1,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
 	return message
 }
+
+// Expired reports whether the message has expired at the given time.
+func (m Message) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+// ContentHash returns the hash of the content and mode of a message, the content being normalized
+// to the Unicode NFC form so that the canonically equivalent contents have the same hash.
+func ContentHash(content string, mode palindrome.Mode) string {
+	sum := sha256.Sum256([]byte(string(mode) + "\x00" + norm.NFC.String(content)))
+	return hex.EncodeToString(sum[:])
+}
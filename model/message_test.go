@@ -2,8 +2,10 @@ package model_test
 
 import (
 	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestNewMessage(t *testing.T) {
@@ -18,4 +20,22 @@ func TestNewMessage(t *testing.T) {
 	assert.NotEmpty(t, message.ID)
 	assert.Equal(t, content, message.Content, "content should match")
 	assert.Equal(t, isPalindrome, message.IsPalindrome, "IsPalindrome should match")
+	assert.Equal(t, int64(1), message.Version, "Version should start at 1")
+	assert.False(t, message.Expired(time.Now()), "message should not expire by default")
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Second), now.Add(time.Second)
+	assert.False(t, model.Message{}.Expired(now))
+	assert.False(t, model.Message{ExpiresAt: &future}.Expired(now))
+	assert.True(t, model.Message{ExpiresAt: &now}.Expired(now))
+	assert.True(t, model.Message{ExpiresAt: &past}.Expired(now))
+}
+
+func TestContentHash(t *testing.T) {
+	// The canonically equivalent contents have the same hash
+	assert.Equal(t, model.ContentHash("E\u0301sope", palindrome.Alphanumeric), model.ContentHash("\u00c9sope", palindrome.Alphanumeric))
+	assert.NotEqual(t, model.ContentHash("Ésope", palindrome.Alphanumeric), model.ContentHash("ésope", palindrome.Alphanumeric))
+	assert.NotEqual(t, model.ContentHash("kayak", palindrome.Alphanumeric), model.ContentHash("kayak", palindrome.Strict))
 }
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// SortField is the message timestamp a listing is sorted by.
+type SortField string
+
+const (
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+	SortByDeletedAt SortField = "deleted_at"
+)
+
+// SortOrder is the direction of a listing.
+type SortOrder string
+
+const (
+	Ascending  SortOrder = "asc"
+	Descending SortOrder = "desc"
+)
+
+// ListQuery holds the pagination, filtering and sorting options of a message listing.
+// The zero value lists every message out of the trash sorted by creation time.
+type ListQuery struct {
+	// Deleted lists the messages in the trash instead of the others.
+	Deleted bool
+	// Limit is the maximum number of messages of a page, 0 means no limit.
+	Limit int
+	// Cursor is the opaque position returned with the previous page.
+	Cursor string
+	// IsPalindrome keeps the messages with the given palindrome result when set.
+	IsPalindrome *bool
+	// CreatedAfter and CreatedBefore bound the creation time, [after, before), when set.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// UpdatedAfter and UpdatedBefore bound the update time, [after, before), when set.
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// Contains keeps the messages whose content contains the substring, case-sensitively.
+	Contains string
+	// SortBy is the timestamp the messages are sorted by, ties are broken by ID.
+	SortBy SortField
+	// Order is the direction of the sort.
+	Order SortOrder
+}
+
+// MessagePage is a page of a message listing.
+type MessagePage struct {
+	Messages []Message
+	// NextCursor is the position of the next page, empty on the last page.
+	NextCursor string
+}
+
+// Cursor is the decoded position of a page: the sort key of the last message of the previous page.
+type Cursor struct {
+	SortBy SortField `json:"s"`
+	Order  SortOrder `json:"o"`
+	Time   time.Time `json:"t"`
+	ID     string    `json:"i"`
+}
+
+// WithDefaults returns the query with the default sort applied.
+func (q ListQuery) WithDefaults() ListQuery {
+	if q.SortBy == "" {
+		q.SortBy = SortByCreatedAt
+	}
+	if q.Order == "" {
+		q.Order = Ascending
+	}
+	return q
+}
+
+// Matches reports whether the message passes the filters of the query, the expired messages never do.
+func (q ListQuery) Matches(message Message) bool {
+	switch {
+	case (message.DeletedAt != nil) != q.Deleted,
+		message.Expired(time.Now()),
+		q.IsPalindrome != nil && message.IsPalindrome != *q.IsPalindrome,
+		!q.CreatedAfter.IsZero() && message.CreatedAt.Before(q.CreatedAfter),
+		!q.CreatedBefore.IsZero() && !message.CreatedAt.Before(q.CreatedBefore),
+		!q.UpdatedAfter.IsZero() && message.UpdatedAt.Before(q.UpdatedAfter),
+		!q.UpdatedBefore.IsZero() && !message.UpdatedAt.Before(q.UpdatedBefore),
+		q.Contains != "" && !strings.Contains(message.Content, q.Contains):
+		return false
+	}
+	return true
+}
+
+// SortKey returns the timestamp the message is sorted by.
+func (q ListQuery) SortKey(message Message) time.Time {
+	switch {
+	case q.SortBy == SortByUpdatedAt:
+		return message.UpdatedAt
+	case q.SortBy == SortByDeletedAt && message.DeletedAt != nil:
+		return *message.DeletedAt
+	}
+	return message.CreatedAt
+}
+
+// Less reports whether a comes before b in the order of the query.
+func (q ListQuery) Less(a, b Message) bool {
+	return q.before(q.SortKey(a), a.ID, q.SortKey(b), b.ID)
+}
+
+// IsAfter reports whether the message comes after the cursor in the order of the query.
+func (q ListQuery) IsAfter(message Message, cursor Cursor) bool {
+	return q.before(cursor.Time, cursor.ID, q.SortKey(message), message.ID)
+}
+
+// before compares two sort keys in the order of the query.
+func (q ListQuery) before(aTime time.Time, aID string, bTime time.Time, bID string) bool {
+	if q.Order == Descending {
+		aTime, aID, bTime, bID = bTime, bID, aTime, aID
+	}
+	if !aTime.Equal(bTime) {
+		return aTime.Before(bTime)
+	}
+	return aID < bID
+}
+
+// NewCursor returns the cursor of the page following the message.
+func (q ListQuery) NewCursor(message Message) string {
+	cursor, _ := json.Marshal(Cursor{SortBy: q.SortBy, Order: q.Order, Time: q.SortKey(message), ID: message.ID})
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+// DecodeCursor decodes the cursor of the query, it returns nil for the first page
+// and ErrInvalidCursor when the cursor was not issued for the same sort.
+func (q ListQuery) DecodeCursor() (*Cursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.SortBy != q.SortBy || cursor.Order != q.Order {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"time"
+)
+
+// Revision is an immutable state of a message, recorded at its creation and by every update.
+type Revision struct {
+	MessageID string
+	// Number is the version of the message the revision records.
+	Number       int64
+	Content      string
+	IsPalindrome bool
+	Mode         palindrome.Mode
+	CreatedAt    time.Time
+}
+
+// NewRevision creates the revision recording the current state of a message.
+func NewRevision(message Message) Revision {
+	return Revision{
+		MessageID:    message.ID,
+		Number:       message.Version,
+		Content:      message.Content,
+		IsPalindrome: message.IsPalindrome,
+		Mode:         message.Mode,
+		CreatedAt:    message.UpdatedAt,
+	}
+}
@@ -0,0 +1,169 @@
+package model
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	// snippetTokens is the number of words of a snippet.
+	snippetTokens = 16
+	// snippetLead is the number of words of a snippet before the first match.
+	snippetLead = 4
+)
+
+// SearchQuery holds a full-text search over the contents of the live messages.
+type SearchQuery struct {
+	// Terms are the words, prefixes and phrases all matched by the messages.
+	Terms []SearchTerm
+	// Limit is the maximum number of hits, 0 means no limit.
+	Limit int
+	// Offset is the number of most relevant hits skipped.
+	Offset int
+}
+
+// SearchTerm is a word, or a phrase of consecutive words, matched case-insensitively.
+type SearchTerm struct {
+	Words []string
+	// Prefix matches the words starting with the last word instead of equal to it.
+	Prefix bool
+}
+
+// SearchHit is a message matching a search.
+type SearchHit struct {
+	Message Message
+	// Score is the relevance of the message, higher is better. It only compares the hits of a search.
+	Score float64
+	// Snippet is an HTML excerpt of the content with the matches wrapped in <mark> elements.
+	Snippet string
+}
+
+// Token is a word of a content, lower-cased, with its [Start, End) byte offsets in the content.
+type Token struct {
+	Text       string
+	Start, End int
+}
+
+// Tokenize splits a content into its words: the runs of letters, marks and numbers.
+func Tokenize(content string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range content {
+		inWord := unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsNumber(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			tokens = append(tokens, Token{Text: strings.ToLower(content[start:i]), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Text: strings.ToLower(content[start:]), Start: start, End: len(content)})
+	}
+	return tokens
+}
+
+// ParseSearchQuery parses the terms of a search: the words, the prefixes ending with "*"
+// and the phrases between double quotes. A word made of several tokens, like "don't", is a phrase.
+// The query has no terms when the text holds no word.
+func ParseSearchQuery(text string) SearchQuery {
+	var query SearchQuery
+	for text != "" {
+		var chunk string
+		if strings.HasPrefix(text, `"`) {
+			// an unterminated phrase runs to the end of the text
+			phrase, rest, _ := strings.Cut(text[1:], `"`)
+			chunk, text = phrase, rest
+			if strings.HasPrefix(text, "*") {
+				chunk, text = chunk+"*", text[1:]
+			}
+		} else {
+			end := strings.IndexFunc(text, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(text)
+			}
+			chunk, text = text[:end], strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+		}
+		tokens := Tokenize(chunk)
+		if len(tokens) == 0 {
+			continue
+		}
+		term := SearchTerm{Words: make([]string, len(tokens)), Prefix: strings.HasSuffix(strings.TrimSpace(chunk), "*")}
+		for i, token := range tokens {
+			term.Words[i] = token.Text
+		}
+		query.Terms = append(query.Terms, term)
+	}
+	return query
+}
+
+// MatchesWord reports whether a word matches the i-th word of the term.
+func (t SearchTerm) MatchesWord(i int, word string) bool {
+	if t.Prefix && i == len(t.Words)-1 {
+		return strings.HasPrefix(word, t.Words[i])
+	}
+	return word == t.Words[i]
+}
+
+// Occurrences returns the indexes of the tokens starting an occurrence of the term.
+func (t SearchTerm) Occurrences(tokens []Token) []int {
+	var occurrences []int
+	for start := 0; start+len(t.Words) <= len(tokens); start++ {
+		matches := true
+		for i := range t.Words {
+			if !t.MatchesWord(i, tokens[start+i].Text) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			occurrences = append(occurrences, start)
+		}
+	}
+	return occurrences
+}
+
+// Snippet returns an excerpt of the content around its first match, HTML-escaped,
+// with the matches of the terms wrapped in <mark> elements.
+func (q SearchQuery) Snippet(content string) string {
+	tokens := Tokenize(content)
+	marked := make([]bool, len(tokens))
+	first := len(tokens)
+	for _, term := range q.Terms {
+		for _, start := range term.Occurrences(tokens) {
+			first = min(first, start)
+			for i := range term.Words {
+				marked[start+i] = true
+			}
+		}
+	}
+	if first == len(tokens) {
+		first = 0
+	}
+
+	from := max(0, first-snippetLead)
+	to := min(len(tokens), from+snippetTokens)
+	var b strings.Builder
+	offset := 0
+	if from > 0 {
+		b.WriteString("…")
+		offset = tokens[from].Start
+	}
+	for i := from; i < to; i++ {
+		b.WriteString(html.EscapeString(content[offset:tokens[i].Start]))
+		if marked[i] {
+			b.WriteString("<mark>" + html.EscapeString(content[tokens[i].Start:tokens[i].End]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(content[tokens[i].Start:tokens[i].End]))
+		}
+		offset = tokens[i].End
+	}
+	if to < len(tokens) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(html.EscapeString(content[offset:]))
+	}
+	return b.String()
+}
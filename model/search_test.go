@@ -0,0 +1,69 @@
+package model_test
+
+import (
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Text     string
+		Expected []model.SearchTerm
+	}{
+		{
+			Name:     "Words",
+			Text:     "Kayak  level",
+			Expected: []model.SearchTerm{{Words: []string{"kayak"}}, {Words: []string{"level"}}},
+		},
+		{
+			Name:     "Prefix",
+			Text:     "pal*",
+			Expected: []model.SearchTerm{{Words: []string{"pal"}, Prefix: true}},
+		},
+		{
+			Name:     "Phrases",
+			Text:     `"a man a" "canal pan"* don't`,
+			Expected: []model.SearchTerm{{Words: []string{"a", "man", "a"}}, {Words: []string{"canal", "pan"}, Prefix: true}, {Words: []string{"don", "t"}}},
+		},
+		{
+			Name:     "Unterminated phrase",
+			Text:     `plan "a canal`,
+			Expected: []model.SearchTerm{{Words: []string{"plan"}}, {Words: []string{"a", "canal"}}},
+		},
+		{
+			Name: "No words",
+			Text: ` * "" ?`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, model.ParseSearchQuery(tc.Text).Terms)
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	tokens := model.Tokenize("A man, a plan, a canal: Panama! A man")
+	assert.Len(t, tokens, 9)
+	assert.Equal(t, model.Token{Text: "panama", Start: 24, End: 30}, tokens[6])
+
+	assert.Equal(t, []int{0, 7}, model.SearchTerm{Words: []string{"a", "man"}}.Occurrences(tokens))
+	assert.Equal(t, []int{6}, model.SearchTerm{Words: []string{"pan"}, Prefix: true}.Occurrences(tokens))
+	assert.Empty(t, model.SearchTerm{Words: []string{"man", "plan"}}.Occurrences(tokens))
+}
+
+func TestSnippet(t *testing.T) {
+	query := model.ParseSearchQuery(`"a canal" pan*`)
+	assert.Equal(t, "A man, a plan, <mark>a</mark> <mark>canal</mark>: <mark>Panama</mark> &amp; co",
+		query.Snippet("A man, a plan, a canal: Panama & co"))
+
+	// A long content is cut around the first match
+	content := "one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty"
+	assert.Equal(t, "…two three four five <mark>six</mark> seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen…",
+		model.ParseSearchQuery("six").Snippet(content))
+	assert.Equal(t, "one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen…",
+		model.ParseSearchQuery("missing").Snippet(content))
+}
@@ -0,0 +1,100 @@
+package model
+
+import "time"
+
+// BucketSize is the period the messages created are counted by.
+type BucketSize string
+
+const (
+	BucketHour BucketSize = "hour"
+	BucketDay  BucketSize = "day"
+)
+
+// MaxBuckets is the maximum number of buckets of a StatsQuery range, and filled by FillBuckets.
+const MaxBuckets = 10000
+
+// Duration returns the length of a bucket.
+func (b BucketSize) Duration() time.Duration {
+	if b == BucketHour {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Truncate returns the start of the bucket holding t, in UTC.
+func (b BucketSize) Truncate(t time.Time) time.Time {
+	// the zero time is a UTC midnight, so are the day boundaries
+	return t.UTC().Truncate(b.Duration())
+}
+
+// Count returns the number of buckets from the one holding from to the one holding to, both included.
+func (b BucketSize) Count(from, to time.Time) int64 {
+	// the difference saturates rather than overflowing for the distant times
+	return int64(b.Truncate(to).Sub(b.Truncate(from))/b.Duration()) + 1
+}
+
+// StatsQuery holds the options of the statistics of the live messages.
+type StatsQuery struct {
+	// From and To bound the creation time, [from, to), when set.
+	From time.Time
+	To   time.Time
+	// Bucket is the period the messages created are counted by.
+	Bucket BucketSize
+	// Top is the number of most frequent palindromes.
+	Top int
+}
+
+// Stats are the aggregates of the live messages created in the range of a StatsQuery.
+type Stats struct {
+	Total       int64
+	Palindromes int64
+	// AverageLength is the average number of characters of the contents, 0 without messages.
+	AverageLength float64
+	// Created counts the messages created by bucket, oldest first, the empty buckets being skipped.
+	Created []BucketCount
+	// TopPalindromes are the most frequent palindrome contents, most frequent first, ties sorted by content.
+	TopPalindromes []ContentCount
+}
+
+// BucketCount is the number of messages created in the bucket starting at Start.
+type BucketCount struct {
+	Start time.Time
+	Count int64
+}
+
+// ContentCount is the number of messages holding a content.
+type ContentCount struct {
+	Content string
+	Count   int64
+}
+
+// Matches reports whether the message is counted by the query, the deleted and expired messages never are.
+func (q StatsQuery) Matches(message Message) bool {
+	switch {
+	case message.DeletedAt != nil,
+		message.Expired(time.Now()),
+		!q.From.IsZero() && message.CreatedAt.Before(q.From),
+		!q.To.IsZero() && !message.CreatedAt.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// FillBuckets returns the counts of every bucket from the first to the last counted one, the empty ones included.
+// The counts spanning more than MaxBuckets buckets are returned as is.
+func FillBuckets(counts []BucketCount, size BucketSize) []BucketCount {
+	if len(counts) == 0 || size.Count(counts[0].Start, counts[len(counts)-1].Start) > MaxBuckets {
+		return counts
+	}
+	filled := []BucketCount{}
+	next := 0
+	for start := counts[0].Start; !start.After(counts[len(counts)-1].Start); start = start.Add(size.Duration()) {
+		count := BucketCount{Start: start}
+		if next < len(counts) && counts[next].Start.Equal(start) {
+			count.Count = counts[next].Count
+			next++
+		}
+		filled = append(filled, count)
+	}
+	return filled
+}
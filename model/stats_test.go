@@ -0,0 +1,35 @@
+package model_test
+
+import (
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBucketSize(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 45, 30, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), model.BucketHour.Truncate(created))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), model.BucketDay.Truncate(created))
+	assert.Equal(t, int64(2), model.BucketHour.Count(created, created.Add(time.Hour)))
+	assert.Equal(t, int64(1), model.BucketDay.Count(created, created.Add(time.Hour)))
+}
+
+func TestFillBuckets(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	filled := model.FillBuckets([]model.BucketCount{
+		{Start: start, Count: 2},
+		{Start: start.Add(3 * time.Hour), Count: 1},
+	}, model.BucketHour)
+	assert.Equal(t, []model.BucketCount{
+		{Start: start, Count: 2},
+		{Start: start.Add(time.Hour)},
+		{Start: start.Add(2 * time.Hour)},
+		{Start: start.Add(3 * time.Hour), Count: 1},
+	}, filled)
+	assert.Empty(t, model.FillBuckets(nil, model.BucketDay))
+
+	// The counts spanning too many buckets are not filled
+	sparse := []model.BucketCount{{Start: time.Time{}, Count: 1}, {Start: start, Count: 1}}
+	assert.Equal(t, sparse, model.FillBuckets(sparse, model.BucketHour))
+}
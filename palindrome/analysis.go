@@ -0,0 +1,128 @@
+package palindrome
+
+import "strings"
+
+// Analysis details how a text has been compared by a mode.
+type Analysis struct {
+	// Normalized is the text that has been compared.
+	Normalized string
+	// Length is the number of units (grapheme clusters or words) compared.
+	Length int
+	// FirstMismatch is the index of the left unit of the first mismatching pair, -1 for a palindrome.
+	FirstMismatch int
+	// Longest is the longest palindromic substring of the normalized units.
+	Longest Substring
+	// DistinctPalindromes is the number of distinct palindromic substrings of the normalized units.
+	DistinctPalindromes int
+}
+
+// Substring locates a run of units [Start, End) in the normalized text.
+type Substring struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// IsPalindrome reports whether the analysed text is a palindrome.
+func (a Analysis) IsPalindrome() bool {
+	return a.FirstMismatch < 0
+}
+
+// Analyze compares s with the given mode and reports the details of the comparison.
+func Analyze(s string, mode Mode) Analysis {
+	units := Normalize(s, mode)
+	separator := mode.separator()
+	tree := newEertree(units)
+	return Analysis{
+		Normalized:    strings.Join(units, separator),
+		Length:        len(units),
+		FirstMismatch: firstMismatch(units),
+		Longest: Substring{
+			Text:  strings.Join(units[tree.longestStart:tree.longestEnd], separator),
+			Start: tree.longestStart,
+			End:   tree.longestEnd,
+		},
+		DistinctPalindromes: tree.distinct(),
+	}
+}
+
+// separator returns the string used to join the units of the mode.
+func (m Mode) separator() string {
+	if m == Word {
+		return " "
+	}
+	return ""
+}
+
+// firstMismatch returns the index of the left unit of the first mismatching pair, -1 when there is none.
+func firstMismatch(units []string) int {
+	for i, j := 0, len(units)-1; i < j; i, j = i+1, j-1 {
+		if units[i] != units[j] {
+			return i
+		}
+	}
+	return -1
+}
+
+// eertree is a palindromic tree: every node but the two roots is a distinct palindromic substring.
+type eertree struct {
+	nodes        []eertreeNode
+	longestStart int
+	longestEnd   int
+}
+
+// eertreeNode is a palindrome of the tree, linked to its longest proper palindromic suffix.
+type eertreeNode struct {
+	length int
+	link   int
+	next   map[string]int
+}
+
+const (
+	imaginaryRoot = 0
+	emptyRoot     = 1
+)
+
+// newEertree builds the palindromic tree of units in linear time.
+func newEertree(units []string) *eertree {
+	t := &eertree{nodes: []eertreeNode{
+		{length: -1, link: imaginaryRoot, next: map[string]int{}},
+		{length: 0, link: imaginaryRoot, next: map[string]int{}},
+	}}
+	last := emptyRoot
+	for i, unit := range units {
+		parent := t.suffix(units, i, last)
+		if child, ok := t.nodes[parent].next[unit]; ok {
+			last = child
+			continue
+		}
+		node := eertreeNode{length: t.nodes[parent].length + 2, link: emptyRoot, next: map[string]int{}}
+		if node.length > 1 {
+			node.link = t.nodes[t.suffix(units, i, t.nodes[parent].link)].next[unit]
+		}
+		t.nodes = append(t.nodes, node)
+		last = len(t.nodes) - 1
+		t.nodes[parent].next[unit] = last
+		if node.length > t.longestEnd-t.longestStart {
+			t.longestStart, t.longestEnd = i+1-node.length, i+1
+		}
+	}
+	return t
+}
+
+// suffix walks the suffix links from node until it finds a palindrome that can be extended by units[i].
+// It always terminates on the imaginary root, whose extension is the single unit itself.
+func (t *eertree) suffix(units []string, i, node int) int {
+	for {
+		start := i - 1 - t.nodes[node].length
+		if start >= 0 && units[start] == units[i] {
+			return node
+		}
+		node = t.nodes[node].link
+	}
+}
+
+// distinct returns the number of distinct palindromic substrings.
+func (t *eertree) distinct() int {
+	return len(t.nodes) - 2
+}
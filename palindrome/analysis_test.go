@@ -0,0 +1,100 @@
+package palindrome_test
+
+import (
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAnalyze tests Analyze function.
+func TestAnalyze(t *testing.T) {
+	// test table
+	tt := []struct {
+		name     string
+		input    string
+		mode     palindrome.Mode
+		expected palindrome.Analysis
+	}{
+		{
+			name:  "empty string",
+			input: "",
+			mode:  palindrome.Alphanumeric,
+			expected: palindrome.Analysis{
+				FirstMismatch: -1,
+			},
+		},
+		{
+			name:  "palindrome",
+			input: "A man, a plan, a canal: Panama",
+			mode:  palindrome.Alphanumeric,
+			expected: palindrome.Analysis{
+				Normalized:          "amanaplanacanalpanama",
+				Length:              21,
+				FirstMismatch:       -1,
+				Longest:             palindrome.Substring{Text: "amanaplanacanalpanama", Start: 0, End: 21},
+				DistinctPalindromes: 18,
+			},
+		},
+		{
+			name:  "non palindrome",
+			input: "Babad!",
+			mode:  palindrome.Alphanumeric,
+			expected: palindrome.Analysis{
+				Normalized:          "babad",
+				Length:              5,
+				FirstMismatch:       0,
+				Longest:             palindrome.Substring{Text: "bab", Start: 0, End: 3},
+				DistinctPalindromes: 5,
+			},
+		},
+		{
+			name:  "multi-byte units",
+			input: "xÉtéy",
+			mode:  palindrome.Alphanumeric,
+			expected: palindrome.Analysis{
+				Normalized:          "xetey",
+				Length:              5,
+				FirstMismatch:       0,
+				Longest:             palindrome.Substring{Text: "ete", Start: 1, End: 4},
+				DistinctPalindromes: 5,
+			},
+		},
+		{
+			name:  "word mode",
+			input: "one fall leaves fall two",
+			mode:  palindrome.Word,
+			expected: palindrome.Analysis{
+				Normalized:          "one fall leaves fall two",
+				Length:              5,
+				FirstMismatch:       0,
+				Longest:             palindrome.Substring{Text: "fall leaves fall", Start: 1, End: 4},
+				DistinctPalindromes: 5,
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			analysis := palindrome.Analyze(tc.input, tc.mode)
+			assert.Equal(t, tc.expected, analysis)
+			assert.Equal(t, palindrome.IsPalindrome(tc.input, tc.mode), analysis.IsPalindrome())
+		})
+	}
+}
+
+// TestAnalyzeDistinctPalindromes compares the distinct palindromes count with a brute force count.
+func TestAnalyzeDistinctPalindromes(t *testing.T) {
+	for _, input := range []string{"a", "aaaa", "abacaba", "abcbaxyzzyx", "mississippi"} {
+		t.Run(input, func(t *testing.T) {
+			seen := map[string]bool{}
+			for i := 0; i < len(input); i++ {
+				for j := i + 1; j <= len(input); j++ {
+					if palindrome.IsPalindrome(input[i:j], palindrome.Strict) {
+						seen[input[i:j]] = true
+					}
+				}
+			}
+			assert.Equal(t, len(seen), palindrome.Analyze(input, palindrome.Strict).DistinctPalindromes)
+		})
+	}
+}
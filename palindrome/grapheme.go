@@ -0,0 +1,68 @@
+package palindrome
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	zeroWidthJoiner = '\u200d'
+	carriageReturn  = '\r'
+	lineFeed        = '\n'
+)
+
+// graphemes splits s into user-perceived characters following the main rules
+// of UAX #29: combining marks, variation selectors and emoji modifiers stay
+// attached to their base, zero width joiner sequences are kept together,
+// regional indicators are paired into flags and CRLF is a single cluster.
+func graphemes(s string) []string {
+	var clusters []string
+	for len(s) > 0 {
+		size := clusterSize(s)
+		clusters = append(clusters, s[:size])
+		s = s[size:]
+	}
+	return clusters
+}
+
+// clusterSize returns the length in bytes of the grapheme cluster starting s.
+func clusterSize(s string) int {
+	first, size := utf8.DecodeRuneInString(s)
+	if first == carriageReturn && len(s) > size && s[size] == lineFeed {
+		return size + 1
+	}
+	if unicode.IsControl(first) {
+		return size
+	}
+	prev := first
+	regionalIndicators := 0
+	if isRegionalIndicator(first) {
+		regionalIndicators = 1
+	}
+	for size < len(s) {
+		r, width := utf8.DecodeRuneInString(s[size:])
+		switch {
+		case isExtend(r), r == zeroWidthJoiner:
+		case prev == zeroWidthJoiner && !unicode.IsControl(r):
+		case isRegionalIndicator(r) && regionalIndicators == 1:
+			regionalIndicators++
+		default:
+			return size
+		}
+		prev = r
+		size += width
+	}
+	return size
+}
+
+// isExtend reports whether r extends the preceding grapheme cluster.
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		unicode.Is(unicode.Variation_Selector, r) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+// isRegionalIndicator reports whether r is one of the letters used to spell flags.
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
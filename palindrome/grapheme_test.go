@@ -0,0 +1,31 @@
+package palindrome
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGraphemes tests graphemes function.
+func TestGraphemes(t *testing.T) {
+	// test table
+	tt := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{name: "empty", input: "", expected: nil},
+		{name: "ascii", input: "abc", expected: []string{"a", "b", "c"}},
+		{name: "combining mark", input: "e\u0301a", expected: []string{"e\u0301", "a"}},
+		{name: "crlf", input: "a\r\nb", expected: []string{"a", "\r\n", "b"}},
+		{name: "flags", input: "🇫🇷🇹🇳", expected: []string{"🇫🇷", "🇹🇳"}},
+		{name: "emoji modifier", input: "👍🏽!", expected: []string{"👍🏽", "!"}},
+		{name: "zero width joiner sequence", input: "👩\u200d💻x", expected: []string{"👩\u200d💻", "x"}},
+		{name: "variation selector", input: "❤\ufe0fa", expected: []string{"❤\ufe0f", "a"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, graphemes(tc.input))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package palindrome
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mode selects the comparison strategy used to decide whether a text is a palindrome.
+type Mode string
+
+const (
+	// Strict compares the text character for character, punctuation and whitespace included.
+	Strict Mode = "strict"
+	// CaseInsensitive compares the text character for character, ignoring the case only.
+	CaseInsensitive Mode = "case-insensitive"
+	// Alphanumeric compares letters and digits only, ignoring case and diacritics.
+	Alphanumeric Mode = "alphanumeric"
+	// Word compares the sequence of words rather than the characters.
+	Word Mode = "word"
+)
+
+// DefaultMode is the mode used when none is specified.
+const DefaultMode = Alphanumeric
+
+// Modes lists every supported mode.
+var Modes = []Mode{Strict, CaseInsensitive, Alphanumeric, Word}
+
+// ParseMode returns the mode named by s, or DefaultMode when s is empty.
+func ParseMode(s string) (Mode, error) {
+	if s == "" {
+		return DefaultMode, nil
+	}
+	for _, mode := range Modes {
+		if strings.EqualFold(s, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("%s is an unknown palindrome mode", s)
+}
+
+// units splits s into the units compared by the mode.
+func (m Mode) units(s string) []string {
+	switch m {
+	case Strict:
+		return graphemes(norm.NFC.String(s))
+	case CaseInsensitive:
+		return graphemes(norm.NFC.String(cases.Fold().String(s)))
+	case Word:
+		return words(s)
+	default:
+		return alphanumerics(s)
+	}
+}
+
+// alphanumerics returns the folded grapheme clusters of s made of letters and digits.
+func alphanumerics(s string) []string {
+	var units []string
+	for _, cluster := range graphemes(fold(s)) {
+		if isAlphanumeric(cluster) {
+			units = append(units, cluster)
+		}
+	}
+	return units
+}
+
+// words returns the folded words of s, each word keeping its letters and digits only.
+func words(s string) []string {
+	var units []string
+	for _, field := range strings.FieldsFunc(s, unicode.IsSpace) {
+		if word := strings.Join(alphanumerics(field), ""); word != "" {
+			units = append(units, word)
+		}
+	}
+	return units
+}
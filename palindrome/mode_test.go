@@ -0,0 +1,96 @@
+package palindrome_test
+
+import (
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseMode tests ParseMode function.
+func TestParseMode(t *testing.T) {
+	// test table
+	tt := []struct {
+		name     string
+		input    string
+		expected palindrome.Mode
+		hasError bool
+	}{
+		{name: "empty falls back to default", input: "", expected: palindrome.DefaultMode},
+		{name: "strict", input: "strict", expected: palindrome.Strict},
+		{name: "case insensitive", input: "case-insensitive", expected: palindrome.CaseInsensitive},
+		{name: "alphanumeric", input: "alphanumeric", expected: palindrome.Alphanumeric},
+		{name: "word is case insensitive", input: "WORD", expected: palindrome.Word},
+		{name: "unknown mode", input: "fuzzy", hasError: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			mode, err := palindrome.ParseMode(tc.input)
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, mode)
+		})
+	}
+}
+
+// TestModes tests IsPalindrome function with every mode.
+func TestModes(t *testing.T) {
+	// test table
+	tt := []struct {
+		name     string
+		input    string
+		expected map[palindrome.Mode]bool
+	}{
+		{
+			name:  "exact mirror",
+			input: "abc cba",
+			expected: map[palindrome.Mode]bool{
+				palindrome.Strict: true, palindrome.CaseInsensitive: true, palindrome.Alphanumeric: true, palindrome.Word: false,
+			},
+		},
+		{
+			name:  "mixed case",
+			input: "Abba",
+			expected: map[palindrome.Mode]bool{
+				palindrome.Strict: false, palindrome.CaseInsensitive: true, palindrome.Alphanumeric: true, palindrome.Word: true,
+			},
+		},
+		{
+			name:  "punctuation",
+			input: "Madam, I'm Adam",
+			expected: map[palindrome.Mode]bool{
+				palindrome.Strict: false, palindrome.CaseInsensitive: false, palindrome.Alphanumeric: true, palindrome.Word: false,
+			},
+		},
+		{
+			name:  "diacritics",
+			input: "Éte",
+			expected: map[palindrome.Mode]bool{
+				palindrome.Strict: false, palindrome.CaseInsensitive: false, palindrome.Alphanumeric: true, palindrome.Word: true,
+			},
+		},
+		{
+			name:  "word level",
+			input: "Fall leaves after leaves fall.",
+			expected: map[palindrome.Mode]bool{
+				palindrome.Strict: false, palindrome.CaseInsensitive: false, palindrome.Alphanumeric: false, palindrome.Word: true,
+			},
+		},
+	}
+	for _, tc := range tt {
+		for mode, expected := range tc.expected {
+			t.Run(tc.name+" "+string(mode), func(t *testing.T) {
+				assert.Equal(t, expected, palindrome.IsPalindrome(tc.input, mode))
+			})
+		}
+	}
+}
+
+// TestNormalizeWords tests Normalize function with the Word mode.
+func TestNormalizeWords(t *testing.T) {
+	units := palindrome.Normalize("  Fall, leaves!\tÀ  --  ", palindrome.Word)
+	assert.Equal(t, []string{"fall", "leaves", "a"}, units)
+}
@@ -0,0 +1,54 @@
+// Package palindrome implements a Unicode-aware palindrome engine.
+package palindrome
+
+import (
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// IsPalindrome reports whether s reads the same forwards and backwards.
+// The comparison is made on the units returned by Normalize for the given mode.
+func IsPalindrome(s string, mode Mode) bool {
+	return isSymmetric(Normalize(s, mode))
+}
+
+// Normalize returns the units of s compared by the given mode: grapheme
+// clusters for the character based modes and words for the Word mode.
+// Apart from Strict, every mode folds the case; Alphanumeric and Word also
+// fold the diacritics and drop punctuation, symbols and Unicode whitespace.
+func Normalize(s string, mode Mode) []string {
+	return mode.units(s)
+}
+
+// fold decomposes s (NFD), strips the combining diacritical marks, folds the
+// case and recomposes the result (NFC).
+func fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), cases.Fold(), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return folded
+}
+
+// isAlphanumeric reports whether the base character of a grapheme cluster is a letter or a digit.
+func isAlphanumeric(cluster string) bool {
+	for _, r := range cluster {
+		return unicode.IsLetter(r) || unicode.IsNumber(r)
+	}
+	return false
+}
+
+// isSymmetric reports whether units reads the same in both directions.
+func isSymmetric(units []string) bool {
+	for i, j := 0, len(units)-1; i < j; i, j = i+1, j-1 {
+		if units[i] != units[j] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package palindrome_test
+
+import (
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestIsPalindrome tests IsPalindrome function.
+func TestIsPalindrome(t *testing.T) {
+	// test table
+	tt := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{name: "empty string", input: "", expected: true},
+		{name: "single character", input: "a", expected: true},
+		{name: "ascii palindrome", input: "A man a plan a canal Panama", expected: true},
+		{name: "ascii non palindrome", input: "test message", expected: false},
+		{name: "punctuation is ignored", input: "A man, a plan, a canal: Panama!", expected: true},
+		{name: "diacritics are folded", input: "Ésope reste ici et se repose", expected: true},
+		{name: "decomposed diacritics are folded", input: "E\u0301sope reste ici et se repose", expected: true},
+		{name: "unicode whitespace is ignored", input: "ab\u00a0c\u3000b\ta", expected: true},
+		{name: "multi-byte non palindrome", input: "日本語", expected: false},
+		{name: "multi-byte palindrome", input: "上海自来水来自海上", expected: true},
+		{name: "cyrillic palindrome", input: "А роза упала на лапу Азора", expected: true},
+		{name: "greek final sigma is folded", input: "Σας", expected: true},
+		{name: "digits", input: "12321", expected: true},
+		{name: "symbols only", input: "?!", expected: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, palindrome.IsPalindrome(tc.input, palindrome.Alphanumeric))
+		})
+	}
+}
+
+// TestNormalize tests Normalize function.
+func TestNormalize(t *testing.T) {
+	// test table
+	tt := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{name: "case and spaces", input: "Ab C", expected: []string{"a", "b", "c"}},
+		{name: "diacritics", input: "Éçà", expected: []string{"e", "c", "a"}},
+		{name: "sharp s is case folded", input: "ß", expected: []string{"s", "s"}},
+		{name: "spacing marks stay attached", input: "कि", expected: []string{"कि"}},
+		{name: "punctuation and symbols", input: "a-b+c.", expected: []string{"a", "b", "c"}},
+		{name: "nothing left", input: " ,;", expected: nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, palindrome.Normalize(tc.input, palindrome.Alphanumeric))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package palindrome
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxRepairLength is the maximum number of units Repair accepts, its cost being quadratic.
+const MaxRepairLength = 2048
+
+// ErrTooLong is returned when a text is too long to be repaired.
+var ErrTooLong = fmt.Errorf("text is longer than %d units", MaxRepairLength)
+
+// Repair describes how close a text is to being a palindrome.
+type Repair struct {
+	// Insertions is the minimum number of units to insert to make the text a palindrome.
+	Insertions int
+	// Deletions is the minimum number of units to delete to make the text a palindrome.
+	Deletions int
+	// Substitutions is the minimum number of units to substitute to make the text a palindrome.
+	Substitutions int
+	// Repaired is a palindrome built from the normalized text with the minimum number of insertions.
+	Repaired string
+}
+
+// NearPalindrome computes, on the units returned by Normalize, the edit distances
+// from s to a palindrome and one concrete repaired text.
+func NearPalindrome(s string, mode Mode) (Repair, error) {
+	units := Normalize(s, mode)
+	if len(units) > MaxRepairLength {
+		return Repair{}, ErrTooLong
+	}
+	insertions := newInsertionTable(units)
+	return Repair{
+		Insertions:    insertions.at(0, len(units)-1),
+		Deletions:     insertions.at(0, len(units)-1),
+		Substitutions: substitutions(units),
+		Repaired:      strings.Join(insertions.repair(), mode.separator()),
+	}, nil
+}
+
+// substitutions returns the number of mismatching pairs of units.
+func substitutions(units []string) int {
+	count := 0
+	for i, j := 0, len(units)-1; i < j; i, j = i+1, j-1 {
+		if units[i] != units[j] {
+			count++
+		}
+	}
+	return count
+}
+
+// insertionTable holds the minimum number of insertions making every units[i:j+1] a palindrome.
+// Deleting the units that would be mirrored costs the same, so it holds the deletions as well.
+type insertionTable struct {
+	units []string
+	cells []int32
+}
+
+// newInsertionTable fills the table bottom-up in quadratic time.
+func newInsertionTable(units []string) insertionTable {
+	n := len(units)
+	t := insertionTable{units: units, cells: make([]int32, n*n)}
+	for length := 2; length <= n; length++ {
+		for i := 0; i+length <= n; i++ {
+			j := i + length - 1
+			if units[i] == units[j] {
+				t.cells[i*n+j] = int32(t.at(i+1, j-1))
+				continue
+			}
+			t.cells[i*n+j] = int32(1 + min(t.at(i+1, j), t.at(i, j-1)))
+		}
+	}
+	return t
+}
+
+// at returns the minimum number of insertions for units[i:j+1], 0 for an empty range.
+func (t insertionTable) at(i, j int) int {
+	if i >= j {
+		return 0
+	}
+	return int(t.cells[i*len(t.units)+j])
+}
+
+// repair walks the table to build a palindrome with the minimum number of insertions.
+func (t insertionTable) repair() []string {
+	var left, right []string
+	for i, j := 0, len(t.units)-1; i <= j; {
+		switch {
+		case i == j:
+			left = append(left, t.units[i])
+			i++
+		case t.units[i] == t.units[j]:
+			left, right = append(left, t.units[i]), append(right, t.units[j])
+			i, j = i+1, j-1
+		case t.at(i+1, j) <= t.at(i, j-1):
+			left, right = append(left, t.units[i]), append(right, t.units[i])
+			i++
+		default:
+			left, right = append(left, t.units[j]), append(right, t.units[j])
+			j--
+		}
+	}
+	for k := len(right) - 1; k >= 0; k-- {
+		left = append(left, right[k])
+	}
+	return left
+}
@@ -0,0 +1,70 @@
+package palindrome_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNearPalindrome tests NearPalindrome function.
+func TestNearPalindrome(t *testing.T) {
+	// test table
+	tt := []struct {
+		name     string
+		input    string
+		mode     palindrome.Mode
+		expected palindrome.Repair
+	}{
+		{
+			name:     "empty string",
+			input:    "",
+			mode:     palindrome.Alphanumeric,
+			expected: palindrome.Repair{},
+		},
+		{
+			name:     "already a palindrome",
+			input:    "Kayak",
+			mode:     palindrome.Alphanumeric,
+			expected: palindrome.Repair{Repaired: "kayak"},
+		},
+		{
+			name:     "one insertion",
+			input:    "abca",
+			mode:     palindrome.Alphanumeric,
+			expected: palindrome.Repair{Insertions: 1, Deletions: 1, Substitutions: 1, Repaired: "abcba"},
+		},
+		{
+			name:     "folded palindrome",
+			input:    "Été!",
+			mode:     palindrome.Alphanumeric,
+			expected: palindrome.Repair{Repaired: "ete"},
+		},
+		{
+			name:     "substitutions differ from insertions",
+			input:    "abcd",
+			mode:     palindrome.Alphanumeric,
+			expected: palindrome.Repair{Insertions: 3, Deletions: 3, Substitutions: 2, Repaired: "abcdcba"},
+		},
+		{
+			name:     "word mode",
+			input:    "fall leaves after fall",
+			mode:     palindrome.Word,
+			expected: palindrome.Repair{Insertions: 1, Deletions: 1, Substitutions: 1, Repaired: "fall leaves after leaves fall"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repair, err := palindrome.NearPalindrome(tc.input, tc.mode)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, repair)
+			assert.True(t, palindrome.IsPalindrome(repair.Repaired, tc.mode))
+		})
+	}
+
+	t.Run("too long", func(t *testing.T) {
+		_, err := palindrome.NearPalindrome(strings.Repeat("ab", palindrome.MaxRepairLength), palindrome.Alphanumeric)
+		assert.ErrorIs(t, err, palindrome.ErrTooLong)
+	})
+}
@@ -0,0 +1,305 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) documents to decoded JSON values,
+// made of maps, slices, strings, float64, booleans and nil.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidOperation is returned for a malformed operation.
+	ErrInvalidOperation = errors.New("invalid operation")
+	// ErrPathNotFound is returned when an operation refers to a missing location.
+	ErrPathNotFound = errors.New("path not found")
+	// ErrTestFailed is returned when the value of a test operation differs.
+	ErrTestFailed = errors.New("test failed")
+)
+
+// Error is the failure of an operation of a JSON Patch.
+type Error struct {
+	// Index is the index of the operation in the patch.
+	Index int
+	// Err is ErrInvalidOperation, ErrPathNotFound or ErrTestFailed.
+	Err error
+	// Message describes the failure.
+	Message string
+}
+
+// Error returns the message of the error, prefixed by the index of the operation.
+func (e *Error) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Message)
+}
+
+// Unwrap returns the reason of the failure.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Operation is an operation of a JSON Patch.
+type Operation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// From is the source location of the move and copy operations.
+	From string `json:"from,omitempty"`
+	// Value is the value of the add, replace and test operations, nil when missing.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Merge returns the result of a merge patch on the target. The objects of the target may be modified.
+func Merge(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = Merge(targetObject[name], value)
+	}
+	return targetObject
+}
+
+// Apply returns the result of the operations on the document, applied in order. The patch is atomic:
+// the first failing operation fails the whole patch with an *Error, the document being possibly modified.
+func Apply(document any, operations []Operation) (any, error) {
+	for index, operation := range operations {
+		var err error
+		if document, err = apply(document, operation); err != nil {
+			var opErr *Error
+			if errors.As(err, &opErr) {
+				opErr.Index = index
+			}
+			return nil, err
+		}
+	}
+	return document, nil
+}
+
+// apply returns the result of an operation on the document.
+func apply(document any, operation Operation) (any, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch operation.Op {
+	case "add", "replace", "test":
+		if operation.Value == nil {
+			return nil, &Error{Err: ErrInvalidOperation, Message: fmt.Sprintf("%s requires a value", operation.Op)}
+		}
+		var value any
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return nil, &Error{Err: ErrInvalidOperation, Message: "value should be valid JSON"}
+		}
+		switch operation.Op {
+		case "add":
+			return add(document, path, value, operation.Path)
+		case "replace":
+			if document, _, err = remove(document, path, operation.Path); err != nil {
+				return nil, err
+			}
+			return add(document, path, value, operation.Path)
+		}
+		current, err := get(document, path, operation.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, &Error{Err: ErrTestFailed, Message: fmt.Sprintf("value at %s differs", operation.Path)}
+		}
+		return document, nil
+	case "remove":
+		document, _, err = remove(document, path, operation.Path)
+		return document, err
+	case "move", "copy":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if operation.Op == "move" {
+			if len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+				return nil, &Error{Err: ErrInvalidOperation, Message: fmt.Sprintf("%s cannot be moved into itself", operation.From)}
+			}
+			document, value, err = remove(document, from, operation.From)
+		} else {
+			value, err = get(document, from, operation.From)
+			value = deepCopy(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(document, path, value, operation.Path)
+	default:
+		return nil, &Error{Err: ErrInvalidOperation, Message: fmt.Sprintf("%q is an unknown operation", operation.Op)}
+	}
+}
+
+// parsePointer returns the reference tokens of a JSON pointer (RFC 6901).
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, &Error{Err: ErrInvalidOperation, Message: fmt.Sprintf("%q is not a JSON pointer", pointer)}
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// get returns the value at the location of a pointer.
+func get(document any, path []string, pointer string) (any, error) {
+	for _, token := range path {
+		switch node := document.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, notFound(pointer)
+			}
+			document = value
+		case []any:
+			i, ok := arrayIndex(token, len(node))
+			if !ok {
+				return nil, notFound(pointer)
+			}
+			document = node[i]
+		default:
+			return nil, notFound(pointer)
+		}
+	}
+	return document, nil
+}
+
+// add returns the document with the value added at the location of a pointer:
+// it is inserted in the arrays and replaces the existing members of the objects.
+func add(document any, path []string, value any, pointer string) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return edit(document, path, pointer, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i := len(node)
+			if token != "-" {
+				var ok bool
+				if i, ok = arrayIndex(token, len(node)+1); !ok {
+					return nil, notFound(pointer)
+				}
+			}
+			return append(node[:i], append([]any{value}, node[i:]...)...), nil
+		}
+		return nil, notFound(pointer)
+	})
+}
+
+// remove returns the document without the value at the location of a pointer, and this value.
+func remove(document any, path []string, pointer string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, document, nil
+	}
+	var removed any
+	document, err := edit(document, path, pointer, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, notFound(pointer)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []any:
+			i, ok := arrayIndex(token, len(node))
+			if !ok {
+				return nil, notFound(pointer)
+			}
+			removed = node[i]
+			return append(node[:i:i], node[i+1:]...), nil
+		}
+		return nil, notFound(pointer)
+	})
+	return document, removed, err
+}
+
+// edit returns the document whose parent of the location of a pointer is replaced by the result of f
+// on this parent and the last token of the pointer.
+func edit(document any, path []string, pointer string, f func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return f(document, path[0])
+	}
+	switch node := document.(type) {
+	case map[string]any:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, notFound(pointer)
+		}
+		child, err := edit(child, path[1:], pointer, f)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = child
+		return node, nil
+	case []any:
+		i, ok := arrayIndex(path[0], len(node))
+		if !ok {
+			return nil, notFound(pointer)
+		}
+		child, err := edit(node[i], path[1:], pointer, f)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+	return nil, notFound(pointer)
+}
+
+// arrayIndex parses an array index below length, without leading zeros.
+func arrayIndex(token string, length int) (int, bool) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(token)
+	return i, err == nil && i < length
+}
+
+// notFound returns the error of a missing location.
+func notFound(pointer string) error {
+	return &Error{Err: ErrPathNotFound, Message: fmt.Sprintf("path %s does not exist", pointer)}
+}
+
+// deepCopy returns a copy of a value sharing none of its maps and slices.
+func deepCopy(value any) any {
+	switch node := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(node))
+		for name, child := range node {
+			copied[name] = deepCopy(child)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(node))
+		for i, child := range node {
+			copied[i] = deepCopy(child)
+		}
+		return copied
+	}
+	return value
+}
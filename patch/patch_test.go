@@ -0,0 +1,196 @@
+package patch_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/patch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decode decodes a JSON value of the tests.
+func decode(t *testing.T, value string) any {
+	var decoded any
+	require.NoError(t, json.Unmarshal([]byte(value), &decoded))
+	return decoded
+}
+
+func TestMerge(t *testing.T) {
+	// Define test cases, from the examples of RFC 7396
+	testCases := []struct {
+		Name     string
+		Target   string
+		Patch    string
+		Expected string
+	}{
+		{Name: "Replace member", Target: `{"a":"b"}`, Patch: `{"a":"c"}`, Expected: `{"a":"c"}`},
+		{Name: "Add member", Target: `{"a":"b"}`, Patch: `{"b":"c"}`, Expected: `{"a":"b","b":"c"}`},
+		{Name: "Remove member", Target: `{"a":"b"}`, Patch: `{"a":null}`, Expected: `{}`},
+		{Name: "Remove missing member", Target: `{"a":"b"}`, Patch: `{"c":null}`, Expected: `{"a":"b"}`},
+		{Name: "Replace array", Target: `{"a":["b"]}`, Patch: `{"a":"c"}`, Expected: `{"a":"c"}`},
+		{Name: "Nested objects", Target: `{"a":{"b":"c","d":"e"}}`, Patch: `{"a":{"b":"f","d":null}}`, Expected: `{"a":{"b":"f"}}`},
+		{Name: "Object into non-object", Target: `["c"]`, Patch: `{"a":{"b":null}}`, Expected: `{"a":{}}`},
+		{Name: "Non-object patch", Target: `{"a":"b"}`, Patch: `["c"]`, Expected: `["c"]`},
+		{Name: "Null patch", Target: `{"a":"b"}`, Patch: `null`, Expected: `null`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual := patch.Merge(decode(t, tc.Target), decode(t, tc.Patch))
+			assert.Equal(t, decode(t, tc.Expected), actual)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	// Define test cases, mostly from the examples of RFC 6902
+	testCases := []struct {
+		Name          string
+		Document      string
+		Patch         string
+		Expected      string
+		ExpectedErr   error
+		ExpectedIndex int
+	}{
+		{
+			Name:     "Add object member",
+			Document: `{"foo":"bar"}`,
+			Patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			Expected: `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			Name:     "Add array element",
+			Document: `{"foo":["bar","baz"]}`,
+			Patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			Expected: `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			Name:     "Append array element",
+			Document: `{"foo":["bar"]}`,
+			Patch:    `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			Expected: `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			Name:     "Remove object member",
+			Document: `{"baz":"qux","foo":"bar"}`,
+			Patch:    `[{"op":"remove","path":"/baz"}]`,
+			Expected: `{"foo":"bar"}`,
+		},
+		{
+			Name:     "Remove array element",
+			Document: `{"foo":["bar","qux","baz"]}`,
+			Patch:    `[{"op":"remove","path":"/foo/1"}]`,
+			Expected: `{"foo":["bar","baz"]}`,
+		},
+		{
+			Name:     "Replace value",
+			Document: `{"baz":"qux","foo":"bar"}`,
+			Patch:    `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			Expected: `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			Name:     "Move value",
+			Document: `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			Patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			Expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			Name:     "Move array element",
+			Document: `{"foo":["all","grass","cows","eat"]}`,
+			Patch:    `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			Expected: `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			Name:     "Copy value",
+			Document: `{"foo":{"bar":"baz"}}`,
+			Patch:    `[{"op":"copy","from":"/foo","path":"/qux"},{"op":"add","path":"/qux/bar","value":"quux"}]`,
+			Expected: `{"foo":{"bar":"baz"},"qux":{"bar":"quux"}}`,
+		},
+		{
+			Name:     "Test then replace",
+			Document: `{"baz":"qux","foo":["a",2,"c"]}`,
+			Patch:    `[{"op":"test","path":"/foo","value":["a",2,"c"]},{"op":"replace","path":"/baz","value":null}]`,
+			Expected: `{"baz":null,"foo":["a",2,"c"]}`,
+		},
+		{
+			Name:     "Escaped pointer",
+			Document: `{"/":9,"~1":10}`,
+			Patch:    `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`,
+			Expected: `{"~1":10}`,
+		},
+		{
+			Name:          "Failed test",
+			Document:      `{"baz":"qux"}`,
+			Patch:         `[{"op":"add","path":"/foo","value":1},{"op":"test","path":"/baz","value":"bar"}]`,
+			ExpectedErr:   patch.ErrTestFailed,
+			ExpectedIndex: 1,
+		},
+		{
+			Name:        "Missing parent",
+			Document:    `{"foo":"bar"}`,
+			Patch:       `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			ExpectedErr: patch.ErrPathNotFound,
+		},
+		{
+			Name:        "Array index out of range",
+			Document:    `{"foo":["bar"]}`,
+			Patch:       `[{"op":"add","path":"/foo/2","value":"qux"}]`,
+			ExpectedErr: patch.ErrPathNotFound,
+		},
+		{
+			Name:        "Leading zero index",
+			Document:    `{"foo":["bar","baz"]}`,
+			Patch:       `[{"op":"remove","path":"/foo/01"}]`,
+			ExpectedErr: patch.ErrPathNotFound,
+		},
+		{
+			Name:        "Replace missing member",
+			Document:    `{"foo":"bar"}`,
+			Patch:       `[{"op":"replace","path":"/baz","value":"qux"}]`,
+			ExpectedErr: patch.ErrPathNotFound,
+		},
+		{
+			Name:        "Missing value",
+			Document:    `{"foo":"bar"}`,
+			Patch:       `[{"op":"add","path":"/baz"}]`,
+			ExpectedErr: patch.ErrInvalidOperation,
+		},
+		{
+			Name:        "Unknown operation",
+			Document:    `{"foo":"bar"}`,
+			Patch:       `[{"op":"merge","path":"/foo","value":"baz"}]`,
+			ExpectedErr: patch.ErrInvalidOperation,
+		},
+		{
+			Name:        "Invalid pointer",
+			Document:    `{"foo":"bar"}`,
+			Patch:       `[{"op":"remove","path":"foo"}]`,
+			ExpectedErr: patch.ErrInvalidOperation,
+		},
+		{
+			Name:        "Move into itself",
+			Document:    `{"foo":{"bar":"baz"}}`,
+			Patch:       `[{"op":"move","from":"/foo","path":"/foo/bar"}]`,
+			ExpectedErr: patch.ErrInvalidOperation,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var operations []patch.Operation
+			require.NoError(t, json.Unmarshal([]byte(tc.Patch), &operations))
+
+			actual, err := patch.Apply(decode(t, tc.Document), operations)
+			if tc.ExpectedErr != nil {
+				assert.ErrorIs(t, err, tc.ExpectedErr)
+				var opErr *patch.Error
+				require.ErrorAs(t, err, &opErr)
+				assert.Equal(t, tc.ExpectedIndex, opErr.Index, "Index of the failed operation should match")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, decode(t, tc.Expected), actual)
+		})
+	}
+}
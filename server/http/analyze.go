@@ -0,0 +1,107 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"net/http"
+	"reflect"
+)
+
+// maxAnalyzeBatchSize is the maximum number of contents accepted by a batch analysis.
+const maxAnalyzeBatchSize = 1000
+
+// The JSON names of the analysis request fields.
+var (
+	analyzeFields      = jsonNames(reflect.TypeOf(AnalyzeRequest{}))
+	analyzeBatchFields = jsonNames(reflect.TypeOf(AnalyzeBatchRequest{}))
+)
+
+type AnalyzeRequest struct {
+	Content string `json:"content"`
+	Mode    string `json:"mode,omitempty"`
+}
+
+type AnalyzeBatchRequest struct {
+	Contents []string `json:"contents"`
+	Mode     string   `json:"mode,omitempty"`
+}
+
+type AnalyzeResponse struct {
+	Content      string           `json:"content"`
+	IsPalindrome bool             `json:"is_palindrome"`
+	Mode         string           `json:"mode"`
+	Analysis     AnalysisResponse `json:"analysis"`
+}
+
+// AnalyzeHandler handles HTTP requests to analyze a content without storing it.
+func (s *MessageService) AnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	httpRequest := AnalyzeRequest{}
+	err := s.decodeRequest(w, r, analyzeFields, &httpRequest, func() []model.Violation {
+		return append(s.validateContent("content", httpRequest.Content), s.validateMode(httpRequest.Mode)...)
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	mode, err := s.resolveMode(httpRequest.Mode)
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, analyze(httpRequest.Content, mode))
+}
+
+// AnalyzeBatchHandler handles HTTP requests to analyze several contents without storing them.
+func (s *MessageService) AnalyzeBatchHandler(w http.ResponseWriter, r *http.Request) {
+	httpRequest := AnalyzeBatchRequest{}
+	err := s.decodeRequest(w, r, analyzeBatchFields, &httpRequest, func() []model.Violation {
+		return s.validateAnalyzeBatchRequest(httpRequest)
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	mode, err := s.resolveMode(httpRequest.Mode)
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	responses := make([]AnalyzeResponse, len(httpRequest.Contents))
+	for index, content := range httpRequest.Contents {
+		responses[index] = analyze(content, mode)
+	}
+	writeJSON(w, http.StatusOK, responses)
+}
+
+// validateAnalyzeBatchRequest returns the violations of the validation rules by a batch analysis request.
+func (s *MessageService) validateAnalyzeBatchRequest(req AnalyzeBatchRequest) []model.Violation {
+	var violations []model.Violation
+	switch {
+	case len(req.Contents) == 0:
+		violations = append(violations, model.Violation{Field: "contents", Code: violationRequired, Message: "contents cannot be empty"})
+	case len(req.Contents) > maxAnalyzeBatchSize:
+		violations = append(violations, model.Violation{Field: "contents", Code: violationTooLong,
+			Message: fmt.Sprintf("contents cannot hold more than %d items", maxAnalyzeBatchSize)})
+	default:
+		for index, content := range req.Contents {
+			violations = append(violations, s.validateContent(fmt.Sprintf("contents[%d]", index), content)...)
+		}
+	}
+	return append(violations, s.validateMode(req.Mode)...)
+}
+
+// analyze runs the palindrome engine on content and maps the result to a http schema.
+func analyze(content string, mode palindrome.Mode) AnalyzeResponse {
+	analysis := palindrome.Analyze(content, mode)
+	return AnalyzeResponse{
+		Content:      content,
+		IsPalindrome: analysis.IsPalindrome(),
+		Mode:         string(mode),
+		Analysis:     mapAnalysisToSchema(analysis),
+	}
+}
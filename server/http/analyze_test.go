@@ -0,0 +1,237 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAnalyzeHandler tests AnalyzeHandler function.
+func TestAnalyzeHandler(t *testing.T) {
+	// The database mock has no function set: any storage access would panic.
+	service := svc.NewMessageService(&DatabaseMock{}, config.New())
+
+	// Define test cases
+	testCases := []struct {
+		Name            string
+		RequestBody     []byte
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedBody    svc.AnalyzeResponse
+	}{
+		{
+			Name:         "Valid request, Palindrome content",
+			RequestBody:  []byte(`{"content": "Ésope reste ici et se repose"}`),
+			ExpectedCode: http.StatusOK,
+			ExpectedBody: svc.AnalyzeResponse{
+				Content:      "Ésope reste ici et se repose",
+				IsPalindrome: true,
+				Mode:         "alphanumeric",
+				Analysis: svc.AnalysisResponse{
+					Normalized:          "esoperesteicietserepose",
+					Length:              23,
+					FirstMismatch:       -1,
+					LongestPalindrome:   svc.SubstringResponse{Text: "esoperesteicietserepose", Start: 0, End: 23},
+					DistinctPalindromes: 20,
+				},
+			},
+		},
+		{
+			Name:         "Valid request with mode",
+			RequestBody:  []byte(`{"content": "Abba", "mode": "strict"}`),
+			ExpectedCode: http.StatusOK,
+			ExpectedBody: svc.AnalyzeResponse{
+				Content:      "Abba",
+				IsPalindrome: false,
+				Mode:         "strict",
+				Analysis: svc.AnalysisResponse{
+					Normalized:          "Abba",
+					Length:              4,
+					FirstMismatch:       0,
+					LongestPalindrome:   svc.SubstringResponse{Text: "bb", Start: 1, End: 3},
+					DistinctPalindromes: 4,
+				},
+			},
+		},
+		{
+			Name:            "Invalid JSON",
+			RequestBody:     []byte(`{"content": "test message",`),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "request body should be valid JSON",
+		},
+		{
+			Name:            "Empty content",
+			RequestBody:     []byte(`{"content": ""}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "content cannot be empty",
+		},
+		{
+			Name:            "Too long content",
+			RequestBody:     []byte(`{"content": "` + strings.Repeat("a", 10001) + `"}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "content cannot be longer than 10000 characters",
+		},
+		{
+			Name:            "Unknown field",
+			RequestBody:     []byte(`{"content": "kayak", "ttl_seconds": 60}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "ttl_seconds is an unknown field",
+		},
+		{
+			Name:            "Unknown mode",
+			RequestBody:     []byte(`{"content": "test message", "mode": "fuzzy"}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "fuzzy is an unknown palindrome mode",
+		},
+		{
+			Name:            "Too large body",
+			RequestBody:     []byte(`{"content": "` + strings.Repeat("a", 1<<20) + `"}`),
+			ExpectedCode:    http.StatusRequestEntityTooLarge,
+			ExpectedMessage: "request body cannot be larger than 1048576 bytes",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a request with the request body
+			req, err := http.NewRequest("POST", "/analyze", bytes.NewBuffer(tc.RequestBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the AnalyzeHandler method
+			handler := http.HandlerFunc(service.AnalyzeHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+
+			// Check the response body if an expected message is provided
+			if tc.ExpectedMessage != "" {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
+				return
+			}
+
+			var response svc.AnalyzeResponse
+			err = json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.ExpectedBody, response, "Response body should match")
+		})
+	}
+}
+
+// TestAnalyzeBatchHandler tests AnalyzeBatchHandler function.
+func TestAnalyzeBatchHandler(t *testing.T) {
+	// The database mock has no function set: any storage access would panic.
+	service := svc.NewMessageService(&DatabaseMock{}, config.New())
+
+	// Define test cases
+	testCases := []struct {
+		Name            string
+		RequestBody     []byte
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedResults []bool
+	}{
+		{
+			Name:            "Valid request",
+			RequestBody:     []byte(`{"contents": ["kayak", "test message", "A man a plan a canal Panama"]}`),
+			ExpectedCode:    http.StatusOK,
+			ExpectedResults: []bool{true, false, true},
+		},
+		{
+			Name:            "Valid request with mode",
+			RequestBody:     []byte(`{"contents": ["fall leaves after leaves fall", "kayak"], "mode": "word"}`),
+			ExpectedCode:    http.StatusOK,
+			ExpectedResults: []bool{true, true},
+		},
+		{
+			Name:            "Invalid JSON",
+			RequestBody:     []byte(`{"contents": ["kayak"],`),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "request body should be valid JSON",
+		},
+		{
+			Name:            "No contents",
+			RequestBody:     []byte(`{"contents": []}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "contents cannot be empty",
+		},
+		{
+			Name:            "Too many contents",
+			RequestBody:     []byte(`{"contents": [` + strings.Repeat(`"a", `, 1000) + `"a"]}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "contents cannot hold more than 1000 items",
+		},
+		{
+			Name:            "Invalid contents",
+			RequestBody:     []byte(`{"contents": ["kayak", "", "a\u0000b"]}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "contents[1] cannot be empty; contents[2] cannot hold control characters other than tabs and line breaks",
+		},
+		{
+			Name:            "Unknown field",
+			RequestBody:     []byte(`{"contents": ["kayak"], "ttl_seconds": 60}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "ttl_seconds is an unknown field",
+		},
+		{
+			Name:            "Unknown mode",
+			RequestBody:     []byte(`{"contents": ["kayak"], "mode": "fuzzy"}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "fuzzy is an unknown palindrome mode",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a request with the request body
+			req, err := http.NewRequest("POST", "/analyze:batch", bytes.NewBuffer(tc.RequestBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the AnalyzeBatchHandler method
+			handler := http.HandlerFunc(service.AnalyzeBatchHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+
+			// Check the response body if an expected message is provided
+			if tc.ExpectedMessage != "" {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
+				return
+			}
+
+			var response []svc.AnalyzeResponse
+			err = json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The results are returned in the order of the contents
+			var request svc.AnalyzeBatchRequest
+			_ = json.Unmarshal(tc.RequestBody, &request)
+			assert.Len(t, response, len(tc.ExpectedResults))
+			for index := range response {
+				assert.Equal(t, request.Contents[index], response[index].Content)
+				assert.Equal(t, tc.ExpectedResults[index], response[index].IsPalindrome)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/gharsallahmoez/palindrome/infra/database"
+	"github.com/gharsallahmoez/palindrome/model"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// maxMessageBatchSize is the maximum number of messages accepted by a batch write.
+const maxMessageBatchSize = 1000
+
+// batchFields are the JSON names of the BatchRequest fields.
+var batchFields = jsonNames(reflect.TypeOf(BatchRequest[json.RawMessage]{}))
+
+// BatchRequest is the body of the batch writes, holding the items to write.
+type BatchRequest[T any] struct {
+	Messages []T `json:"messages"`
+}
+
+// BatchUpdateItem is a message update of a batch, conditional like an If-Match header when IfMatch is set.
+type BatchUpdateItem struct {
+	ID      string `json:"id"`
+	IfMatch string `json:"if_match,omitempty"`
+	MessageRequest
+}
+
+// BatchDeleteItem is a message deletion of a batch, conditional like an If-Match header when IfMatch is set.
+type BatchDeleteItem struct {
+	ID      string `json:"id"`
+	IfMatch string `json:"if_match,omitempty"`
+}
+
+// BatchItemResponse is the outcome of an item of a batch write.
+type BatchItemResponse struct {
+	Status  int              `json:"status"`
+	ETag    string           `json:"etag,omitempty"`
+	Message *MessageResponse `json:"message,omitempty"`
+	Error   *Problem         `json:"error,omitempty"`
+}
+
+// CreateMessagesBatchHandler handles HTTP requests to create several messages at once.
+func (s *MessageService) CreateMessagesBatchHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	items, ok := decodeBatch(s, w, r, func(req MessageRequest) []model.Violation { return s.validateMessageRequest(req, now) })
+	if !ok {
+		return
+	}
+
+	results := make([]any, len(items))
+	var (
+		messages []model.Message
+		indexes  []int
+	)
+	for index, item := range items {
+		if item.err != nil {
+			results[index] = batchItemFailed(item.err)
+			continue
+		}
+		message, err := s.newMessage(item.value)
+		if err != nil {
+			results[index] = batchItemFailed(err)
+			continue
+		}
+		messages = append(messages, message)
+		indexes = append(indexes, index)
+	}
+
+	if len(messages) > 0 {
+		saves, err := s.database.SaveMessages(messages, r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for i, save := range saves {
+			switch {
+			case save.Err == nil:
+				results[indexes[i]] = batchItemWritten(r, http.StatusCreated, save.Message)
+			case s.config.Database.Dedupe == database.DedupeReturn:
+				results[indexes[i]] = batchItemWritten(r, http.StatusOK, save.Message)
+			default:
+				results[indexes[i]] = batchItemFailed(duplicateError(save.Message))
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// UpdateMessagesBatchHandler handles HTTP requests to update several messages at once.
+func (s *MessageService) UpdateMessagesBatchHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	decoded, ok := decodeBatch(s, w, r, func(item BatchUpdateItem) []model.Violation {
+		return s.validateMessageRequest(item.MessageRequest, now)
+	})
+	if !ok {
+		return
+	}
+	items := make([]BatchUpdateItem, len(decoded))
+	for index := range decoded {
+		items[index] = decoded[index].value
+	}
+
+	results := make([]any, len(items))
+	var (
+		patches []model.Patch
+		indexes []int
+	)
+	for index, item := range items {
+		if decoded[index].err != nil {
+			results[index] = batchItemFailed(decoded[index].err)
+			continue
+		}
+		if item.ID == "" {
+			results[index] = batchItemFailed(errEmptyID)
+			continue
+		}
+		version, err := s.parseIfMatch(item.IfMatch)
+		if err != nil {
+			results[index] = batchItemFailed(err)
+			continue
+		}
+		if violations := s.validateMessageRequest(item.MessageRequest, now); len(violations) > 0 {
+			results[index] = batchItemFailed(model.NewValidationError(violations))
+			continue
+		}
+		patch, err := s.updatePatch(item.ID, version, item.MessageRequest, now)
+		if err != nil {
+			results[index] = batchItemFailed(err)
+			continue
+		}
+		patches = append(patches, patch)
+		indexes = append(indexes, index)
+	}
+
+	if len(patches) > 0 {
+		updates, err := s.database.PatchMessages(patches, r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for i, update := range updates {
+			if update.Err != nil {
+				results[indexes[i]] = batchItemFailed(preconditionError(update.Err))
+				continue
+			}
+			results[indexes[i]] = batchItemWritten(r, http.StatusOK, update.Message)
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// DeleteMessagesBatchHandler handles HTTP requests to delete several messages at once.
+func (s *MessageService) DeleteMessagesBatchHandler(w http.ResponseWriter, r *http.Request) {
+	items, ok := decodeBatch[BatchDeleteItem](s, w, r, nil)
+	if !ok {
+		return
+	}
+
+	results := make([]any, len(items))
+	var (
+		deletions []model.Deletion
+		indexes   []int
+	)
+	for index, decoded := range items {
+		if decoded.err != nil {
+			results[index] = batchItemFailed(decoded.err)
+			continue
+		}
+		item := decoded.value
+		if item.ID == "" {
+			results[index] = batchItemFailed(errEmptyID)
+			continue
+		}
+		version, err := s.parseIfMatch(item.IfMatch)
+		if err != nil {
+			results[index] = batchItemFailed(err)
+			continue
+		}
+		deletions = append(deletions, model.Deletion{ID: item.ID, Version: version})
+		indexes = append(indexes, index)
+	}
+
+	if len(deletions) > 0 {
+		errs, err := s.database.DeleteMessages(deletions, r.Context())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for i, err := range errs {
+			if err != nil {
+				results[indexes[i]] = batchItemFailed(preconditionError(err))
+				continue
+			}
+			results[indexes[i]] = BatchItemResponse{Status: http.StatusNoContent}
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// batchItem is an item of a batch request, failed by err when it cannot be decoded or its fields are invalid.
+type batchItem[T any] struct {
+	value T
+	err   error
+}
+
+// decodeBatch reads and validates a batch request, it writes the error and returns false on failure.
+func decodeBatch[T any](s *MessageService, w http.ResponseWriter, r *http.Request, validate func(T) []model.Violation) ([]batchItem[T], bool) {
+	content, err := s.readBody(w, r)
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	httpRequest := BatchRequest[json.RawMessage]{}
+	violations, err := decodeFields(content, batchFields, &httpRequest)
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	// the values which are not valid UTF-8 fail their item only
+	violations = slices.DeleteFunc(violations, func(v model.Violation) bool {
+		return v.Field == "messages" && v.Code == violationInvalidUTF8
+	})
+	if len(violations) > 0 {
+		writeError(w, r, model.NewValidationError(violations))
+		return nil, false
+	}
+
+	// Validate the messages field
+	if len(httpRequest.Messages) == 0 {
+		writeError(w, r, model.ErrInvalidRequest.Errorf("Messages cannot be empty"))
+		return nil, false
+	}
+	if len(httpRequest.Messages) > maxMessageBatchSize {
+		writeError(w, r, model.ErrInvalidRequest.Errorf("Messages cannot hold more than %d items", maxMessageBatchSize))
+		return nil, false
+	}
+
+	fields := flatJSONNames(reflect.TypeFor[T]())
+	items := make([]batchItem[T], len(httpRequest.Messages))
+	for index, raw := range httpRequest.Messages {
+		violations, err := decodeFields(raw, fields, &items[index].value)
+		switch {
+		case err != nil:
+			items[index].err = err
+		case len(violations) > 0:
+			if validate != nil {
+				violations = mergeViolations(violations, validate(items[index].value))
+			}
+			items[index].err = model.NewValidationError(violations)
+		}
+	}
+	return items, true
+}
+
+// batchItemWritten returns the outcome of a written message in the schema of the version serving the request.
+func batchItemWritten(r *http.Request, status int, message model.Message) any {
+	return versionOf(r).schema.batchItem(status, message)
+}
+
+// batchItemFailed returns the outcome of a failed item.
+func batchItemFailed(err error) BatchItemResponse {
+	problem := newProblem(catalogued(err))
+	return BatchItemResponse{Status: problem.Status, Error: &problem}
+}
@@ -0,0 +1,326 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCreateMessagesBatchHandler tests CreateMessagesBatchHandler function.
+func TestCreateMessagesBatchHandler(t *testing.T) {
+	// Mock database save function saving every message but the duplicates of "level"
+	dbMock := &DatabaseMock{
+		SaveMessagesFunc: func(messages []model.Message, ctx context.Context) ([]model.BatchResult, error) {
+			results := make([]model.BatchResult, len(messages))
+			for i, message := range messages {
+				results[i].Message = message
+				if message.Content == "level" {
+					results[i] = model.BatchResult{Message: model.Message{ID: "1", Content: "level", Version: 2}, Err: model.ErrDuplicateMessage}
+				}
+			}
+			return results, nil
+		},
+	}
+
+	service := svc.NewMessageService(dbMock, config.New())
+
+	// Define test cases
+	testCases := []struct {
+		Name             string
+		RequestBody      []byte
+		ExpectedCode     int
+		ExpectedMessage  string
+		ExpectedStatuses []int
+	}{
+		{
+			Name:             "Valid request",
+			RequestBody:      []byte(`{"messages": [{"content": "kayak"}, {"content": "test message", "mode": "strict"}]}`),
+			ExpectedCode:     http.StatusOK,
+			ExpectedStatuses: []int{http.StatusCreated, http.StatusCreated},
+		},
+		{
+			Name:             "Invalid items",
+			RequestBody:      []byte(`{"messages": [{"content": ""}, {"content": "kayak"}, {"content": "kayak", "mode": "fuzzy"}]}`),
+			ExpectedCode:     http.StatusOK,
+			ExpectedStatuses: []int{http.StatusUnprocessableEntity, http.StatusCreated, http.StatusUnprocessableEntity},
+		},
+		{
+			Name: "Invalid item fields",
+			RequestBody: []byte("{\"messages\": [{\"content\": \"kayak\", \"author\": \"me\"}, {\"content\": 42}, " +
+				"\"kayak\", {\"content\": \"kay\xffak\"}, {\"content\": \"kayak\"}]}"),
+			ExpectedCode: http.StatusOK,
+			ExpectedStatuses: []int{http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, http.StatusBadRequest,
+				http.StatusUnprocessableEntity, http.StatusCreated},
+		},
+		{
+			Name:            "Unknown batch field",
+			RequestBody:     []byte(`{"messages": [{"content": "kayak"}], "items": []}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "items is an unknown field",
+		},
+		{
+			Name:             "Duplicate",
+			RequestBody:      []byte(`{"messages": [{"content": "kayak"}, {"content": "level"}]}`),
+			ExpectedCode:     http.StatusOK,
+			ExpectedStatuses: []int{http.StatusCreated, http.StatusConflict},
+		},
+		{
+			Name:            "Invalid JSON",
+			RequestBody:     []byte(`{"messages": [{"content": "kayak"}],`),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "request body should be valid JSON",
+		},
+		{
+			Name:            "No messages",
+			RequestBody:     []byte(`{"messages": []}`),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "Messages cannot be empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a request with the request body
+			req, err := http.NewRequest("POST", "/messages:batch", bytes.NewBuffer(tc.RequestBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the CreateMessagesBatchHandler method
+			handler := http.HandlerFunc(service.CreateMessagesBatchHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+
+			// Check the response body if an expected message is provided
+			if tc.ExpectedMessage != "" {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
+				return
+			}
+
+			var response []svc.BatchItemResponse
+			err = json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The results are returned in the order of the messages
+			assert.Len(t, response, len(tc.ExpectedStatuses))
+			for index := range response {
+				assert.Equal(t, tc.ExpectedStatuses[index], response[index].Status)
+				if response[index].Status == http.StatusCreated {
+					assert.NotEmpty(t, response[index].Message.ID, "ID should not be empty")
+					assert.Equal(t, `"1"`, response[index].ETag, "ETag should be the first version")
+				} else {
+					assert.NotEmpty(t, response[index].Error, "Error should be set")
+				}
+			}
+		})
+	}
+
+	t.Run("item violations", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/messages:batch", bytes.NewBufferString(`{"messages": [{"content": "", "author": "me"}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the CreateMessagesBatchHandler method
+		handler := http.HandlerFunc(service.CreateMessagesBatchHandler)
+		handler.ServeHTTP(rr, req)
+		var response []svc.BatchItemResponse
+		err = json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []svc.ViolationResponse{
+			{Field: "author", Code: "unknown_field", Detail: "author is an unknown field"},
+			{Field: "content", Code: "required", Detail: "content cannot be empty"},
+		}, response[0].Error.Errors, "Violations of the fields and the rules should be reported together")
+	})
+
+	t.Run("too large body", func(t *testing.T) {
+		t.Parallel()
+		conf := config.New()
+		conf.Server.MaxBodyBytes = 32
+		service := svc.NewMessageService(&DatabaseMock{}, conf)
+		req, err := http.NewRequest("POST", "/messages:batch", bytes.NewBufferString(`{"messages": [{"content": "kayak"}, {"content": "level"}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the CreateMessagesBatchHandler method
+		handler := http.HandlerFunc(service.CreateMessagesBatchHandler)
+		handler.ServeHTTP(rr, req)
+		assertProblem(t, rr, http.StatusRequestEntityTooLarge, "request body cannot be larger than 32 bytes")
+	})
+
+	t.Run("with failed db operation", func(t *testing.T) {
+		t.Parallel()
+		dbMock := &DatabaseMock{
+			SaveMessagesFunc: func(messages []model.Message, ctx context.Context) ([]model.BatchResult, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("POST", "/messages:batch", bytes.NewBufferString(`{"messages": [{"content": "kayak"}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the CreateMessagesBatchHandler method
+		handler := http.HandlerFunc(service.CreateMessagesBatchHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
+	})
+}
+
+// TestUpdateMessagesBatchHandler tests UpdateMessagesBatchHandler function.
+func TestUpdateMessagesBatchHandler(t *testing.T) {
+	// Mock database patch function applying the patches in order: messages 1 and 2 are at version 3,
+	// message 4 in word mode at version 1 and messages 3 and 5 do not exist
+	stored := map[string]model.Message{
+		"1": {ID: "1", Mode: palindrome.Alphanumeric, Version: 3},
+		"2": {ID: "2", Mode: palindrome.Alphanumeric, Version: 3},
+		"4": {ID: "4", Mode: palindrome.Word, Version: 1},
+	}
+	dbMock := &DatabaseMock{
+		PatchMessagesFunc: func(patches []model.Patch, ctx context.Context) ([]model.BatchResult, error) {
+			results := make([]model.BatchResult, len(patches))
+			for i, patch := range patches {
+				results[i].Message, results[i].Err = patchStoredMessage(stored[patch.ID])(patch.ID, patch.Version, patch.Apply, ctx)
+				if results[i].Err == nil {
+					stored[patch.ID] = results[i].Message
+				}
+			}
+			return results, nil
+		},
+	}
+
+	service := svc.NewMessageService(dbMock, config.New())
+	req, err := http.NewRequest("PUT", "/messages:batch", bytes.NewBufferString(`{"messages": [
+		{"id": "1", "content": "kayak", "mode": "alphanumeric"},
+		{"id": "2", "content": "kayak", "mode": "alphanumeric", "if_match": "\"2\""},
+		{"id": "2", "content": "kayak", "mode": "alphanumeric", "if_match": "\"3\""},
+		{"id": "3", "content": "kayak", "mode": "alphanumeric"},
+		{"id": "", "content": "kayak"},
+		{"id": "1", "content": "kayak", "if_match": "3"},
+		{"id": "4", "content": "fall leaves after leaves fall"},
+		{"id": "4", "content": "kayak", "if_match": "\"2\""},
+		{"id": "5", "content": "kayak"},
+		{"id": "4", "content": ""}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a response recorder to record the response
+	rr := newRecorder(t, req)
+
+	// Call the UpdateMessagesBatchHandler method
+	handler := http.HandlerFunc(service.UpdateMessagesBatchHandler)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+
+	var response []svc.BatchItemResponse
+	err = json.NewDecoder(rr.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make([]int, len(response))
+	for index := range response {
+		statuses[index] = response[index].Status
+	}
+	expected := []int{
+		http.StatusOK, http.StatusPreconditionFailed, http.StatusOK,
+		http.StatusNotFound, http.StatusBadRequest, http.StatusBadRequest,
+		http.StatusOK, http.StatusOK, http.StatusNotFound, http.StatusUnprocessableEntity,
+	}
+	assert.Equal(t, expected, statuses, "Statuses should match")
+	assert.Equal(t, `"4"`, response[0].ETag, "ETag should be the new version")
+	assert.Equal(t, "kayak", response[0].Message.Content)
+	assert.Equal(t, "word", response[6].Message.Mode, "Mode should be kept")
+	assert.True(t, response[6].Message.IsPalindrome, "Content should be analyzed in the stored mode")
+	assert.Equal(t, `"3"`, response[7].ETag, "Updates should be applied in order")
+}
+
+// TestDeleteMessagesBatchHandler tests DeleteMessagesBatchHandler function.
+func TestDeleteMessagesBatchHandler(t *testing.T) {
+	// Mock database delete function: only message 1 exists
+	dbMock := &DatabaseMock{
+		DeleteMessagesFunc: func(deletions []model.Deletion, ctx context.Context) ([]error, error) {
+			errs := make([]error, len(deletions))
+			for i, deletion := range deletions {
+				if deletion.ID != "1" {
+					errs[i] = model.ErrMessageNotFound
+				}
+			}
+			return errs, nil
+		},
+	}
+
+	conf := config.New()
+	conf.Server.RequireIfMatch = true
+	service := svc.NewMessageService(dbMock, conf)
+	req, err := http.NewRequest("DELETE", "/messages:batch", bytes.NewBufferString(`{"messages": [
+		{"id": "1", "if_match": "*"},
+		{"id": "2", "if_match": "*"},
+		{"id": "1"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a response recorder to record the response
+	rr := newRecorder(t, req)
+
+	// Call the DeleteMessagesBatchHandler method
+	handler := http.HandlerFunc(service.DeleteMessagesBatchHandler)
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+
+	var response []svc.BatchItemResponse
+	err = json.NewDecoder(rr.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []svc.BatchItemResponse{
+		{Status: http.StatusNoContent},
+		{Status: http.StatusNotFound, Error: &svc.Problem{
+			Type:   "urn:palindrome:problem:message_not_found",
+			Title:  "Not Found",
+			Status: http.StatusNotFound,
+			Detail: "message not found",
+			Code:   "message_not_found",
+		}},
+		{Status: http.StatusPreconditionRequired, Error: &svc.Problem{
+			Type:   "urn:palindrome:problem:if_match_required",
+			Title:  "Precondition Required",
+			Status: http.StatusPreconditionRequired,
+			Detail: "If-Match header is required",
+			Code:   "if_match_required",
+		}},
+	}, response, "Response body should match")
+}
@@ -2,73 +2,157 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"github.com/gharsallahmoez/palindrome/infra/database"
 	"github.com/gharsallahmoez/palindrome/model"
-	"strings"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"time"
 )
 
 type MessageRequest struct {
 	Content string `json:"content"`
+	Mode    string `json:"mode,omitempty"`
+	// TTLSeconds and ExpiresAt optionally set when the message expires, they are mutually exclusive.
+	TTLSeconds *int64     `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 }
 
 type MessageResponse struct {
-	ID           string `json:"id"`
-	Content      string `json:"content"`
-	IsPalindrome bool   `json:"is_palindrome"`
+	ID           string           `json:"id"`
+	Content      string           `json:"content"`
+	IsPalindrome bool             `json:"is_palindrome"`
+	Mode         string           `json:"mode"`
+	Analysis     AnalysisResponse `json:"analysis"`
+	Version      int64            `json:"version"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+	// ExpiresAt is only set for the messages with a time-to-live.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// DeletedAt is only set for the messages in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// NearPalindrome is only set when requested.
+	NearPalindrome *NearPalindromeResponse `json:"near_palindrome,omitempty"`
+}
+
+type AnalysisResponse struct {
+	Normalized          string            `json:"normalized"`
+	Length              int               `json:"length"`
+	FirstMismatch       int               `json:"first_mismatch"`
+	LongestPalindrome   SubstringResponse `json:"longest_palindrome"`
+	DistinctPalindromes int               `json:"distinct_palindromes"`
+}
+
+type SubstringResponse struct {
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type NearPalindromeResponse struct {
+	Insertions    int    `json:"insertions"`
+	Deletions     int    `json:"deletions"`
+	Substitutions int    `json:"substitutions"`
+	Repaired      string `json:"repaired"`
 }
 
 // CreateMessageHandler handles HTTP requests to create a new message.
 func (s *MessageService) CreateMessageHandler(w http.ResponseWriter, r *http.Request) {
-	httpRequest := MessageRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&httpRequest); err != nil {
-		logrus.Errorf(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, r, invalid(err))
 		return
 	}
 
-	// Validate the content field
-	if httpRequest.Content == "" {
-		http.Error(w, "Content cannot be empty", http.StatusBadRequest)
+	httpRequest, err := s.decodeMessageRequest(w, r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	message := model.NewMessage(httpRequest.Content, isPalindrome(httpRequest.Content))
+	message, err := s.newMessage(httpRequest)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	key, ok := s.claimIdempotencyKey(w, r, httpRequest)
+	if !ok {
+		return
+	}
 
 	// Save the message to the database.
 	savedMessage, err := s.database.SaveMessage(message, r.Context())
+	if errors.Is(err, model.ErrDuplicateMessage) {
+		s.releaseIdempotencyKey(key, r.Context())
+		s.writeDuplicate(w, r, savedMessage, fields)
+		return
+	}
 	if err != nil {
-		logrus.Errorf(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.releaseIdempotencyKey(key, r.Context())
+		writeError(w, r, err)
 		return
 	}
 	logrus.Infof("message with id %s created successfully", savedMessage.ID)
 
 	// Build response JSON.
-	response := MessageResponse{
-		ID:           savedMessage.ID,
-		Content:      savedMessage.Content,
-		IsPalindrome: savedMessage.IsPalindrome,
-	}
-	responseJSON, err := json.Marshal(response)
+	responseJSON, err := json.Marshal(fields.apply(versionOf(r).schema.message(savedMessage, nil)))
 	if err != nil {
-		logrus.Errorf(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.releaseIdempotencyKey(key, r.Context())
+		writeError(w, r, err)
 		return
 	}
+	s.completeIdempotencyKey(key, etag(savedMessage.Version), responseJSON, r.Context())
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(savedMessage.Version))
 	w.WriteHeader(http.StatusCreated)
 	_, _ = w.Write(responseJSON)
 }
 
-func isPalindrome(s string) bool {
-	s = strings.ToLower(s)
-	s = strings.ReplaceAll(s, " ", "")
-	for i := 0; i < len(s)/2; i++ {
-		if s[i] != s[len(s)-1-i] {
-			return false
-		}
+// writeDuplicate writes the response to the creation of a duplicate of a stored message.
+func (s *MessageService) writeDuplicate(w http.ResponseWriter, r *http.Request, message model.Message, fields fieldSet) {
+	if s.config.Database.Dedupe != database.DedupeReturn {
+		writeError(w, r, duplicateError(message))
+		return
+	}
+	w.Header().Set("ETag", etag(message.Version))
+	w.Header().Set("Content-Location", versionOf(r).pathPrefix()+"/messages/"+message.ID)
+	writeJSON(w, http.StatusOK, fields.apply(versionOf(r).schema.message(message, nil)))
+}
+
+// duplicateError returns the error of the rejected duplicates of a stored message.
+func duplicateError(message model.Message) error {
+	return model.ErrDuplicateMessage.Errorf("message already exists with id %s", message.ID)
+}
+
+// newMessage validates the request and creates the message it describes.
+func (s *MessageService) newMessage(req MessageRequest) (model.Message, error) {
+	now := time.Now()
+	if violations := s.validateMessageRequest(req, now); len(violations) > 0 {
+		return model.Message{}, model.NewValidationError(violations)
+	}
+
+	mode, err := s.resolveMode(req.Mode)
+	if err != nil {
+		return model.Message{}, err
+	}
+	expiresAt := req.expiry(now)
+
+	analysis := palindrome.Analyze(req.Content, mode)
+	message := model.NewMessage(req.Content, analysis.IsPalindrome())
+	message.Mode = mode
+	message.Analysis = analysis
+	message.ExpiresAt = expiresAt
+	return message, nil
+}
+
+// expiry returns the expiry time set by the time-to-live or the expiry date of a validated request, if any.
+func (req MessageRequest) expiry(now time.Time) *time.Time {
+	if req.TTLSeconds != nil {
+		expiresAt := now.Add(time.Duration(*req.TTLSeconds) * time.Second)
+		return &expiresAt
 	}
-	return true
+	return req.ExpiresAt
 }
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/model"
 	svc "github.com/gharsallahmoez/palindrome/server/http"
 	"github.com/stretchr/testify/assert"
@@ -24,7 +26,7 @@ func TestCreateMessageHandler(t *testing.T) {
 		},
 	}
 
-	service := svc.NewMessageService(dbMock)
+	service := svc.NewMessageService(dbMock, config.New())
 
 	// Define test cases
 	testCases := []struct {
@@ -34,6 +36,7 @@ func TestCreateMessageHandler(t *testing.T) {
 		ExpectedCode    int
 		ExpectedMessage string
 		isPalindrome    bool
+		mode            string
 	}{
 		{
 			Name: "Valid request, non-Palindrome content",
@@ -54,20 +57,78 @@ func TestCreateMessageHandler(t *testing.T) {
 			ExpectedMessage: "",
 			isPalindrome:    true,
 		},
+		{
+			Name: "Valid request, unicode Palindrome content",
+			RequestBody: []byte(`{
+				"content": "Ésope reste ici et se repose"
+			}`),
+			message:         "Ésope reste ici et se repose",
+			ExpectedCode:    http.StatusCreated,
+			ExpectedMessage: "",
+			isPalindrome:    true,
+		},
+		{
+			Name: "Valid request, strict mode",
+			RequestBody: []byte(`{
+				"content": "A man a plan a canal Panama",
+				"mode": "strict"
+			}`),
+			message:         "A man a plan a canal Panama",
+			mode:            "strict",
+			ExpectedCode:    http.StatusCreated,
+			ExpectedMessage: "",
+		},
+		{
+			Name: "Unknown mode",
+			RequestBody: []byte(`{
+				"content": "test message",
+				"mode": "fuzzy"
+			}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "fuzzy is an unknown palindrome mode",
+		},
+		{
+			Name: "Non-positive time-to-live",
+			RequestBody: []byte(`{
+				"content": "test message",
+				"ttl_seconds": 0
+			}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "ttl_seconds should be positive",
+		},
+		{
+			Name: "Past expiry",
+			RequestBody: []byte(`{
+				"content": "test message",
+				"expires_at": "2000-01-01T00:00:00Z"
+			}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "expires_at should be in the future",
+		},
+		{
+			Name: "Both time-to-live and expiry",
+			RequestBody: []byte(`{
+				"content": "test message",
+				"ttl_seconds": 60,
+				"expires_at": "2100-01-01T00:00:00Z"
+			}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "ttl_seconds and expires_at are mutually exclusive",
+		},
 		{
 			Name: "Invalid JSON",
 			RequestBody: []byte(`{
 				"content": "test message",`),
 			ExpectedCode:    http.StatusBadRequest,
-			ExpectedMessage: "unexpected EOF\n",
+			ExpectedMessage: "request body should be valid JSON",
 		},
 		{
 			Name: "Empty content",
 			RequestBody: []byte(`{
 				"content": ""
 			}`),
-			ExpectedCode:    http.StatusBadRequest,
-			ExpectedMessage: "Content cannot be empty\n",
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "content cannot be empty",
 		},
 	}
 
@@ -80,7 +141,7 @@ func TestCreateMessageHandler(t *testing.T) {
 			}
 
 			// Create a response recorder to record the response
-			rr := httptest.NewRecorder()
+			rr := newRecorder(t, req)
 
 			// Call the CreateMessageHandler method
 			handler := http.HandlerFunc(service.CreateMessageHandler)
@@ -91,7 +152,7 @@ func TestCreateMessageHandler(t *testing.T) {
 
 			// Check the response body if an expected message is provided
 			if tc.ExpectedMessage != "" {
-				assert.Equal(t, tc.ExpectedMessage, rr.Body.String(), "Response body should match")
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
 				return
 			}
 
@@ -105,12 +166,45 @@ func TestCreateMessageHandler(t *testing.T) {
 			// Assert that the response body contains a non-empty ID
 			assert.NotEmpty(t, response.ID, "ID should not be empty")
 			assert.Equal(t, tc.message, response.Content)
-			if tc.isPalindrome {
-				assert.True(t, response.IsPalindrome)
+			assert.Equal(t, tc.isPalindrome, response.IsPalindrome)
+			if tc.mode == "" {
+				tc.mode = "alphanumeric"
 			}
+			assert.Equal(t, tc.mode, response.Mode)
+			assert.Equal(t, tc.isPalindrome, response.Analysis.FirstMismatch == -1)
+			assert.NotEmpty(t, response.Analysis.Normalized)
 		})
 	}
 
+	t.Run("with time-to-live", func(t *testing.T) {
+		t.Parallel()
+		RequestBody := []byte(`{
+			"content": "test message",
+			"ttl_seconds": 60
+		}`)
+		req, err := http.NewRequest("POST", "/messages", bytes.NewBuffer(RequestBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the CreateMessageHandler method
+		before := time.Now()
+		handler := http.HandlerFunc(service.CreateMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code, "Status code should match")
+
+		var response svc.MessageResponse
+		err = json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotNil(t, response.ExpiresAt, "Expiry should be set")
+		assert.WithinDuration(t, before.Add(time.Minute), *response.ExpiresAt, time.Second)
+	})
+
 	t.Run("with failed db operation", func(t *testing.T) {
 		t.Parallel()
 		dbMock := &DatabaseMock{
@@ -119,7 +213,7 @@ func TestCreateMessageHandler(t *testing.T) {
 			},
 		}
 
-		service := svc.NewMessageService(dbMock)
+		service := svc.NewMessageService(dbMock, config.New())
 		RequestBody := []byte(`{
 			"content": "test message"
 		}`)
@@ -129,11 +223,229 @@ func TestCreateMessageHandler(t *testing.T) {
 		}
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
+
+		// Call the CreateMessageHandler method
+		handler := http.HandlerFunc(service.CreateMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
+	})
+}
+
+// TestCreateMessageHandlerIdempotencyKey tests the retries of CreateMessageHandler with an Idempotency-Key header.
+func TestCreateMessageHandlerIdempotencyKey(t *testing.T) {
+	// Mock database storing the idempotency keys in a map
+	keys := map[string]model.IdempotencyKey{}
+	dbMock := &DatabaseMock{
+		SaveMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
+			return message, nil
+		},
+		ClaimIdempotencyKeyFunc: func(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error) {
+			if claimed, exists := keys[key.Key]; exists && !claimed.Claimable(time.Now()) {
+				return claimed, model.ErrIdempotencyKeyClaimed
+			}
+			keys[key.Key] = key
+			return key, nil
+		},
+		CompleteIdempotencyKeyFunc: func(key model.IdempotencyKey, ctx context.Context) error {
+			keys[key.Key] = key
+			return nil
+		},
+	}
+
+	service := svc.NewMessageService(dbMock, config.New())
+	create := func(key string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/messages", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Idempotency-Key", key)
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the CreateMessageHandler method
+		handler := http.HandlerFunc(service.CreateMessageHandler)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The retry gets the original response, whatever the formatting of the body
+	first := create("key", `{"content": "kayak"}`)
+	assert.Equal(t, http.StatusCreated, first.Code, "Status code should match")
+	retry := create("key", `{ "content":"kayak" }`)
+	assert.Equal(t, http.StatusCreated, retry.Code, "Status code should match")
+	assert.Equal(t, first.Body.String(), retry.Body.String(), "Response body should be replayed")
+	assert.Equal(t, first.Header().Get("ETag"), retry.Header().Get("ETag"), "ETag should be replayed")
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Len(t, dbMock.SaveMessageCalls(), 1, "Message should be saved once")
+
+	// The key cannot be reused with another body
+	reused := create("key", `{"content": "level"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code, "Status code should match")
+
+	// The key of a request being processed cannot be used
+	keys["processing"] = model.IdempotencyKey{Key: "processing", RequestHash: keys["key"].RequestHash,
+		ExpiresAt: time.Now().Add(time.Hour), ClaimedUntil: time.Now().Add(time.Minute)}
+	processing := create("processing", `{"content": "kayak"}`)
+	assert.Equal(t, http.StatusConflict, processing.Code, "Status code should match")
+
+	// Unless its claim lapsed, e.g. after a crash, the retry then holds the key for the claim timeout
+	keys["lapsed"] = model.IdempotencyKey{Key: "lapsed", RequestHash: keys["key"].RequestHash,
+		ExpiresAt: time.Now().Add(time.Hour), ClaimedUntil: time.Now().Add(-time.Second)}
+	lapsed := create("lapsed", `{"content": "kayak"}`)
+	assert.Equal(t, http.StatusCreated, lapsed.Code, "Status code should match")
+	assert.WithinDuration(t, time.Now().Add(time.Minute), keys["lapsed"].ClaimedUntil, time.Second)
+	assert.True(t, keys["lapsed"].Completed(), "Response should be recorded")
+
+	t.Run("with failed db operation", func(t *testing.T) {
+		var released []string
+		dbMock := &DatabaseMock{
+			SaveMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
+				return model.Message{}, errors.New("some error")
+			},
+			ClaimIdempotencyKeyFunc: func(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error) {
+				return key, nil
+			},
+			ReleaseIdempotencyKeyFunc: func(key string, ctx context.Context) error {
+				released = append(released, key)
+				return nil
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("POST", "/messages", bytes.NewBufferString(`{"content": "kayak"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Idempotency-Key", "key")
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
 
 		// Call the CreateMessageHandler method
 		handler := http.HandlerFunc(service.CreateMessageHandler)
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
+		assert.Equal(t, []string{"key"}, released, "Key should be released for the retries")
 	})
+
+	t.Run("with disconnected client", func(t *testing.T) {
+		// Mock database failing the writes made with a cancelled context, like the SQL databases
+		var completed, released []string
+		write := func(ctx context.Context, keys *[]string, key string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			*keys = append(*keys, key)
+			return nil
+		}
+		dbMock := &DatabaseMock{
+			SaveMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
+				if message.Content == "level" {
+					return model.Message{}, errors.New("some error")
+				}
+				return message, nil
+			},
+			ClaimIdempotencyKeyFunc: func(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error) {
+				return key, nil
+			},
+			CompleteIdempotencyKeyFunc: func(key model.IdempotencyKey, ctx context.Context) error {
+				return write(ctx, &completed, key.Key)
+			},
+			ReleaseIdempotencyKeyFunc: func(key string, ctx context.Context) error {
+				return write(ctx, &released, key)
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		for key, body := range map[string]string{"saved": `{"content": "kayak"}`, "failed": `{"content": "level"}`} {
+			// Create a request whose client disconnected once the message is saved
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			req, err := http.NewRequestWithContext(ctx, "POST", "/messages", bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Idempotency-Key", key)
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the CreateMessageHandler method
+			handler := http.HandlerFunc(service.CreateMessageHandler)
+			handler.ServeHTTP(rr, req)
+		}
+		assert.Equal(t, []string{"saved"}, completed, "Key should be completed for the retries")
+		assert.Equal(t, []string{"failed"}, released, "Key should be released for the retries")
+	})
+}
+
+// TestCreateMessageHandlerDuplicate tests CreateMessageHandler with the deduplication of the messages.
+func TestCreateMessageHandlerDuplicate(t *testing.T) {
+	// Mock database save function finding a stored duplicate
+	stored := model.Message{ID: "1", Content: "kayak", IsPalindrome: true, Version: 3}
+	dbMock := &DatabaseMock{
+		SaveMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
+			return stored, model.ErrDuplicateMessage
+		},
+	}
+
+	// Define test cases
+	testCases := []struct {
+		Name            string
+		Dedupe          string
+		ExpectedCode    int
+		ExpectedMessage string
+	}{
+		{
+			Name:         "Return the stored message",
+			Dedupe:       "return",
+			ExpectedCode: http.StatusOK,
+		},
+		{
+			Name:            "Reject the duplicate",
+			Dedupe:          "reject",
+			ExpectedCode:    http.StatusConflict,
+			ExpectedMessage: "message already exists with id 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			conf := config.New()
+			conf.Database.Dedupe = tc.Dedupe
+			service := svc.NewMessageService(dbMock, conf)
+
+			// Create a request with the request body
+			req, err := http.NewRequest("POST", "/messages", bytes.NewBufferString(`{"content": "kayak"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the CreateMessageHandler method
+			handler := http.HandlerFunc(service.CreateMessageHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedMessage != "" {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
+				return
+			}
+
+			// The stored message is returned
+			var response svc.MessageResponse
+			err = json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, stored.ID, response.ID)
+			assert.Equal(t, `"3"`, rr.Header().Get("ETag"), "ETag should be the stored version")
+			assert.Equal(t, "/messages/1", rr.Header().Get("Content-Location"))
+		})
+	}
 }
@@ -8,23 +8,24 @@ import (
 	"net/http"
 )
 
-// DeleteMessageHandler handles HTTP requests to delete message.
+// DeleteMessageHandler handles HTTP requests to move a message to the trash.
 func (s *MessageService) DeleteMessageHandler(w http.ResponseWriter, r *http.Request) {
 	// Retrieve id from query.
 	id := mux.Vars(r)["id"]
 	if id == "" {
-		http.Error(w, "id should not be empty", http.StatusBadRequest)
+		writeError(w, r, errEmptyID)
 		return
 	}
 
-	err := s.database.DeleteMessage(id, r.Context())
+	version, err := s.expectedVersion(r)
 	if err != nil {
-		if errors.Is(err, model.ErrMessageNotFound) {
-			http.Error(w, "message not found", http.StatusNotFound)
-		} else {
-			logrus.Errorf(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeError(w, r, err)
+		return
+	}
+
+	err = s.database.DeleteMessage(id, version, r.Context())
+	if err != nil {
+		writeError(w, r, preconditionError(err))
 		return
 	}
 
@@ -32,3 +33,26 @@ func (s *MessageService) DeleteMessageHandler(w http.ResponseWriter, r *http.Req
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RestoreMessageHandler handles HTTP requests to move a deleted message out of the trash.
+func (s *MessageService) RestoreMessageHandler(w http.ResponseWriter, r *http.Request) {
+	// Retrieve id from query.
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		writeError(w, r, errEmptyID)
+		return
+	}
+
+	message, err := s.database.RestoreMessage(id, r.Context())
+	if err != nil {
+		if errors.Is(err, model.ErrMessageNotFound) {
+			err = model.ErrMessageNotFound.Errorf("message not found in the trash")
+		}
+		writeError(w, r, err)
+		return
+	}
+	logrus.Infof("message with id %s restored successfully", message.ID)
+
+	w.Header().Set("ETag", etag(message.Version))
+	writeJSON(w, http.StatusOK, versionOf(r).schema.message(message, nil))
+}
@@ -3,9 +3,9 @@ package http_test
 import (
 	"context"
 	"errors"
+	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/model"
 	"net/http"
-	"net/http/httptest"
 	"testing"
 
 	svc "github.com/gharsallahmoez/palindrome/server/http"
@@ -17,12 +17,12 @@ import (
 func TestDeleteMessageHandler(t *testing.T) {
 	// Mock database delete function for valid request
 	dbMock := &DatabaseMock{
-		DeleteMessageFunc: func(id string, ctx context.Context) error {
+		DeleteMessageFunc: func(id string, version int64, ctx context.Context) error {
 			return nil
 		},
 	}
 
-	service := svc.NewMessageService(dbMock)
+	service := svc.NewMessageService(dbMock, config.New())
 
 	// Define test cases
 	testCases := []struct {
@@ -54,7 +54,7 @@ func TestDeleteMessageHandler(t *testing.T) {
 			req = mux.SetURLVars(req, map[string]string{"id": tc.ID})
 
 			// Create a response recorder to record the response
-			rr := httptest.NewRecorder()
+			rr := newRecorder(t, req)
 
 			// Call the DeleteMessageHandler method
 			handler := http.HandlerFunc(service.DeleteMessageHandler)
@@ -68,12 +68,12 @@ func TestDeleteMessageHandler(t *testing.T) {
 	t.Run("non-exist message", func(t *testing.T) {
 		t.Parallel()
 		dbMock := &DatabaseMock{
-			DeleteMessageFunc: func(id string, ctx context.Context) error {
+			DeleteMessageFunc: func(id string, version int64, ctx context.Context) error {
 				return model.ErrMessageNotFound
 			},
 		}
 
-		service := svc.NewMessageService(dbMock)
+		service := svc.NewMessageService(dbMock, config.New())
 		req, err := http.NewRequest("DELETE", "/messages/1", nil)
 		if err != nil {
 			t.Fatal(err)
@@ -83,7 +83,7 @@ func TestDeleteMessageHandler(t *testing.T) {
 		req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
 
 		// Call the DeleteMessageHandler method
 		handler := http.HandlerFunc(service.DeleteMessageHandler)
@@ -94,12 +94,12 @@ func TestDeleteMessageHandler(t *testing.T) {
 	t.Run("with failed db operation", func(t *testing.T) {
 		t.Parallel()
 		dbMock := &DatabaseMock{
-			DeleteMessageFunc: func(id string, ctx context.Context) error {
+			DeleteMessageFunc: func(id string, version int64, ctx context.Context) error {
 				return errors.New("some error")
 			},
 		}
 
-		service := svc.NewMessageService(dbMock)
+		service := svc.NewMessageService(dbMock, config.New())
 		req, err := http.NewRequest("DELETE", "/messages/1", nil)
 		if err != nil {
 			t.Fatal(err)
@@ -109,11 +109,113 @@ func TestDeleteMessageHandler(t *testing.T) {
 		req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
 
 		// Call the DeleteMessageHandler method
 		handler := http.HandlerFunc(service.DeleteMessageHandler)
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
 	})
+
+	t.Run("with stale ETag", func(t *testing.T) {
+		t.Parallel()
+		dbMock := &DatabaseMock{
+			DeleteMessageFunc: func(id string, version int64, ctx context.Context) error {
+				assert.Equal(t, int64(2), version)
+				return model.ErrVersionMismatch
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("DELETE", "/messages/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-Match", `"2"`)
+
+		// Set the request variables
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the DeleteMessageHandler method
+		handler := http.HandlerFunc(service.DeleteMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code, "Status code should match")
+	})
+
+	t.Run("without required If-Match", func(t *testing.T) {
+		t.Parallel()
+		conf := config.New()
+		conf.Server.RequireIfMatch = true
+		service := svc.NewMessageService(&DatabaseMock{}, conf)
+		req, err := http.NewRequest("DELETE", "/messages/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Set the request variables
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the DeleteMessageHandler method
+		handler := http.HandlerFunc(service.DeleteMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusPreconditionRequired, rr.Code, "Status code should match")
+	})
+}
+
+// TestRestoreMessageHandler tests RestoreMessageHandler function.
+func TestRestoreMessageHandler(t *testing.T) {
+	// Define test cases
+	testCases := []struct {
+		Name         string
+		ID           string
+		Err          error
+		ExpectedCode int
+	}{
+		{Name: "Valid request", ID: "1", ExpectedCode: http.StatusOK},
+		{Name: "Empty ID", ID: "", ExpectedCode: http.StatusBadRequest},
+		{Name: "Not in the trash", ID: "1", Err: model.ErrMessageNotFound, ExpectedCode: http.StatusNotFound},
+		{Name: "With failed db operation", ID: "1", Err: errors.New("some error"), ExpectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			dbMock := &DatabaseMock{
+				RestoreMessageFunc: func(id string, ctx context.Context) (model.Message, error) {
+					if tc.Err != nil {
+						return model.Message{}, tc.Err
+					}
+					return model.Message{ID: id, Content: "kayak", IsPalindrome: true, Version: 2}, nil
+				},
+			}
+			service := svc.NewMessageService(dbMock, config.New())
+
+			// Create a request with the ID
+			req, err := http.NewRequest("POST", "/messages/"+tc.ID+"/restore", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Set the request variables
+			req = mux.SetURLVars(req, map[string]string{"id": tc.ID})
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the RestoreMessageHandler method
+			handler := http.HandlerFunc(service.RestoreMessageHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedCode == http.StatusOK {
+				assert.Equal(t, `"2"`, rr.Header().Get("ETag"), "ETag should be the version")
+			}
+		})
+	}
 }
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"errors"
+	"github.com/gharsallahmoez/palindrome/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// errInvalidIfMatch is returned when If-Match is not * or a single entity tag.
+	errInvalidIfMatch = model.ErrInvalidRequest.Errorf("If-Match should be * or a single entity tag")
+	// errPreconditionFailed is returned when If-Match cannot match the stored message.
+	errPreconditionFailed = model.ErrVersionMismatch.Errorf("message has been modified")
+)
+
+// etag returns the strong entity tag of a message version.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// expectedVersion returns the version required by the If-Match header, 0 for any version.
+func (s *MessageService) expectedVersion(r *http.Request) (int64, error) {
+	return s.parseIfMatch(r.Header.Get("If-Match"))
+}
+
+// parseIfMatch returns the version required by an If-Match value, 0 when any version matches.
+func (s *MessageService) parseIfMatch(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		if s.config.Server.RequireIfMatch {
+			return 0, model.ErrIfMatchRequired
+		}
+		return 0, nil
+	case value == "*":
+		return 0, nil
+	case strings.HasPrefix(value, "W/"):
+		// weak entity tags never match with the strong comparison of If-Match
+		return 0, errPreconditionFailed
+	}
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return 0, errInvalidIfMatch
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 1 {
+		// a well-formed entity tag we never issued
+		return 0, errPreconditionFailed
+	}
+	return version, nil
+}
+
+// preconditionError returns the error of a conditional write.
+func preconditionError(err error) error {
+	if errors.Is(err, model.ErrVersionMismatch) {
+		return errPreconditionFailed
+	}
+	return err
+}
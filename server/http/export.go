@@ -0,0 +1,188 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// formatNDJSON writes a JSON record per line.
+	formatNDJSON = "ndjson"
+	// formatCSV writes a header line followed by a CSV record per line.
+	formatCSV = "csv"
+)
+
+// exportPageSize is the number of messages read from the database at once during an export.
+const exportPageSize = 500
+
+// csvColumns are the columns of the CSV exports, in order.
+var csvColumns = []string{"id", "content", "is_palindrome", "mode", "created_at", "updated_at", "expires_at"}
+
+// ExportRecord is a message as exported, and as imported where only the content is required.
+type ExportRecord struct {
+	ID           string     `json:"id,omitempty"`
+	Content      string     `json:"content"`
+	IsPalindrome *bool      `json:"is_palindrome,omitempty"`
+	Mode         string     `json:"mode,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// recordWriter writes the records of an export in its format.
+type recordWriter interface {
+	Write(record ExportRecord) error
+	// Flush sends the records written so far to the client.
+	Flush() error
+}
+
+// ExportMessagesHandler handles HTTP requests to export the messages, oldest first.
+func (s *MessageService) ExportMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := parseFormat(r)
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	query := model.ListQuery{Limit: exportPageSize}.WithDefaults()
+	page, err := s.database.ListMessages(query, r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var writer recordWriter
+	switch format {
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		writer, err = newCSVRecordWriter(w)
+	default:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		writer = ndjsonRecordWriter{encoder: json.NewEncoder(w), controller: http.NewResponseController(w)}
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="messages.%s"`, format))
+	if err == nil {
+		err = s.writeExport(writer, query, page, r.Context())
+	}
+	if err != nil {
+		logrus.Errorf("export interrupted: %v", err)
+	}
+}
+
+// writeExport writes the messages of the page and of the following pages of the query.
+func (s *MessageService) writeExport(writer recordWriter, query model.ListQuery, page model.MessagePage, ctx context.Context) error {
+	for {
+		for _, message := range page.Messages {
+			if err := writer.Write(exportRecord(message)); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+		var err error
+		if page, err = s.database.ListMessages(query, ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// parseFormat reads the export or import format from the query string, NDJSON by default.
+func parseFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", formatNDJSON:
+		return formatNDJSON, nil
+	case formatCSV:
+		return formatCSV, nil
+	default:
+		return "", fmt.Errorf("format should be %s or %s", formatNDJSON, formatCSV)
+	}
+}
+
+// exportRecord returns the exported record of a message.
+func exportRecord(message model.Message) ExportRecord {
+	return ExportRecord{
+		ID:           message.ID,
+		Content:      message.Content,
+		IsPalindrome: &message.IsPalindrome,
+		Mode:         string(message.Mode),
+		CreatedAt:    &message.CreatedAt,
+		UpdatedAt:    &message.UpdatedAt,
+		ExpiresAt:    message.ExpiresAt,
+	}
+}
+
+// ndjsonRecordWriter writes the records as JSON lines.
+type ndjsonRecordWriter struct {
+	encoder    *json.Encoder
+	controller *http.ResponseController
+}
+
+// Write writes a record followed by a newline.
+func (n ndjsonRecordWriter) Write(record ExportRecord) error {
+	return n.encoder.Encode(record)
+}
+
+// Flush sends the written records to the client.
+func (n ndjsonRecordWriter) Flush() error {
+	return flush(n.controller)
+}
+
+// csvRecordWriter writes the records as CSV lines following the header.
+type csvRecordWriter struct {
+	writer     *csv.Writer
+	controller *http.ResponseController
+}
+
+// newCSVRecordWriter returns a CSV writer to w which wrote the header.
+func newCSVRecordWriter(w http.ResponseWriter) (csvRecordWriter, error) {
+	writer := csvRecordWriter{writer: csv.NewWriter(w), controller: http.NewResponseController(w)}
+	return writer, writer.writer.Write(csvColumns)
+}
+
+// Write writes a record in the order of csvColumns.
+func (c csvRecordWriter) Write(record ExportRecord) error {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339Nano)
+	}
+	isPalindrome := ""
+	if record.IsPalindrome != nil {
+		isPalindrome = strconv.FormatBool(*record.IsPalindrome)
+	}
+	return c.writer.Write([]string{
+		record.ID, record.Content, isPalindrome, record.Mode,
+		formatTime(record.CreatedAt), formatTime(record.UpdatedAt), formatTime(record.ExpiresAt),
+	})
+}
+
+// Flush sends the written records to the client.
+func (c csvRecordWriter) Flush() error {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return err
+	}
+	return flush(c.controller)
+}
+
+// flush sends the buffered response to the client, when the response writer supports it.
+func flush(controller *http.ResponseController) error {
+	if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
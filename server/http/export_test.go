@@ -0,0 +1,123 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExportMessagesHandler tests ExportMessagesHandler function.
+func TestExportMessagesHandler(t *testing.T) {
+	// Mock database list function returning two pages
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(time.Hour)
+	pages := map[string]model.MessagePage{
+		"": {
+			Messages:   []model.Message{{ID: "1", Content: "kayak", IsPalindrome: true, Mode: palindrome.Alphanumeric, CreatedAt: createdAt, UpdatedAt: createdAt}},
+			NextCursor: "next",
+		},
+		"next": {
+			Messages: []model.Message{{ID: "2", Content: "hello, world", Mode: palindrome.Strict, CreatedAt: createdAt, UpdatedAt: expiresAt, ExpiresAt: &expiresAt}},
+		},
+	}
+	dbMock := &DatabaseMock{
+		ListMessagesFunc: func(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+			return pages[query.Cursor], nil
+		},
+	}
+
+	service := svc.NewMessageService(dbMock, config.New())
+
+	// Define test cases
+	testCases := []struct {
+		Name                string
+		Format              string
+		ExpectedCode        int
+		ExpectedContentType string
+		ExpectedBody        string
+	}{
+		{
+			Name:                "NDJSON by default",
+			ExpectedCode:        http.StatusOK,
+			ExpectedContentType: "application/x-ndjson",
+			ExpectedBody: `{"id":"1","content":"kayak","is_palindrome":true,"mode":"alphanumeric","created_at":"2024-03-01T10:00:00Z","updated_at":"2024-03-01T10:00:00Z"}
+{"id":"2","content":"hello, world","is_palindrome":false,"mode":"strict","created_at":"2024-03-01T10:00:00Z","updated_at":"2024-03-01T11:00:00Z","expires_at":"2024-03-01T11:00:00Z"}
+`,
+		},
+		{
+			Name:                "CSV",
+			Format:              "csv",
+			ExpectedCode:        http.StatusOK,
+			ExpectedContentType: "text/csv",
+			ExpectedBody: `id,content,is_palindrome,mode,created_at,updated_at,expires_at
+1,kayak,true,alphanumeric,2024-03-01T10:00:00Z,2024-03-01T10:00:00Z,
+2,"hello, world",false,strict,2024-03-01T10:00:00Z,2024-03-01T11:00:00Z,2024-03-01T11:00:00Z
+`,
+		},
+		{
+			Name:         "Unknown format",
+			Format:       "xml",
+			ExpectedCode: http.StatusBadRequest,
+			ExpectedBody: "format should be ndjson or csv",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a request with the format
+			req, err := http.NewRequest("GET", "/messages/export?format="+tc.Format, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the ExportMessagesHandler method
+			handler := http.HandlerFunc(service.ExportMessagesHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code and the response body
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedCode != http.StatusOK {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedBody)
+				return
+			}
+			assert.Equal(t, tc.ExpectedBody, rr.Body.String(), "Response body should match")
+			if tc.ExpectedContentType != "" {
+				assert.Equal(t, tc.ExpectedContentType, rr.Header().Get("Content-Type"))
+				assert.True(t, rr.Flushed, "Response should be streamed")
+			}
+		})
+	}
+
+	t.Run("with failed db operation", func(t *testing.T) {
+		dbMock := &DatabaseMock{
+			ListMessagesFunc: func(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+				return model.MessagePage{}, errors.New("some error")
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("GET", "/messages/export", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the ExportMessagesHandler method
+		handler := http.HandlerFunc(service.ExportMessagesHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
+	})
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gharsallahmoez/palindrome/model"
+)
+
+// fieldSet holds the message fields selected by the fields query parameter, nil selecting every field.
+type fieldSet map[string]bool
+
+// parseFields reads the message fields of the fields query parameter.
+func parseFields(r *http.Request) (fieldSet, error) {
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+	messageFields := jsonNames(reflect.TypeOf(versionOf(r).schema.message(model.Message{}, nil)))
+	fields := fieldSet{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(messageFields, name) {
+			return nil, fmt.Errorf("fields should be among %s", strings.Join(messageFields, ", "))
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// apply restricts a message response to the selected fields.
+func (f fieldSet) apply(response any) any {
+	if f == nil {
+		return response
+	}
+	selected := map[string]any{}
+	value := reflect.ValueOf(response)
+	for index, name := range jsonNames(value.Type()) {
+		field := value.Field(index)
+		if (name == "id" || f[name]) && !(field.Kind() == reflect.Pointer && field.IsNil()) {
+			selected[name] = field.Interface()
+		}
+	}
+	return selected
+}
+
+// jsonNames returns the JSON names of the fields of a struct type.
+func jsonNames(t reflect.Type) []string {
+	names := make([]string, t.NumField())
+	for index := range names {
+		names[index], _, _ = strings.Cut(t.Field(index).Tag.Get("json"), ",")
+	}
+	return names
+}
+
+// flatJSONNames returns the JSON names of the fields of a struct type, with those of its embedded structs.
+func flatJSONNames(t reflect.Type) []string {
+	var names []string
+	for index := range t.NumField() {
+		field := t.Field(index)
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			names = append(names, flatJSONNames(field.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	return names
+}
@@ -2,12 +2,12 @@ package http
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
 	"net/http"
+	"strconv"
 )
 
 // GetMessageHandler handles HTTP requests to retrieve message.
@@ -15,34 +15,52 @@ func (s *MessageService) GetMessageHandler(w http.ResponseWriter, r *http.Reques
 	// Retrieve id from query.
 	id := mux.Vars(r)["id"]
 	if id == "" {
-		http.Error(w, "id should not be empty", http.StatusBadRequest)
+		writeError(w, r, errEmptyID)
 		return
 	}
 
-	message, err := s.database.GetMessage(id, r.Context())
+	fields, err := parseFields(r)
 	if err != nil {
-		if errors.Is(err, model.ErrMessageNotFound) {
-			http.Error(w, "message not found", http.StatusNotFound)
-		} else {
-			logrus.Errorf(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	// Retrieve the optional near palindrome flag from query.
+	withNearPalindrome := false
+	if value := r.URL.Query().Get("near_palindrome"); value != "" {
+		if withNearPalindrome, err = strconv.ParseBool(value); err != nil {
+			writeError(w, r, model.ErrInvalidRequest.Errorf("near_palindrome should be a boolean"))
+			return
 		}
+	}
+
+	message, err := s.database.GetMessage(id, r.Context())
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	httpMessage := mapDomainMessageToSchema(message)
+	var repair *palindrome.Repair
+	if withNearPalindrome {
+		nearPalindrome, err := palindrome.NearPalindrome(message.Content, message.Mode)
+		if err != nil {
+			writeError(w, r, model.ErrContentTooLong.Wrap(err))
+			return
+		}
+		repair = &nearPalindrome
+	}
+	httpMessage := versionOf(r).schema.message(message, repair)
 
 	// Marshal message schema into JSON
-	executionsJSON, err := json.Marshal(httpMessage)
+	executionsJSON, err := json.Marshal(fields.apply(httpMessage))
 	if err != nil {
-		errMsg := fmt.Sprintf("Error marshalling schema: %v", err)
-		logrus.Errorf(errMsg)
-		http.Error(w, errMsg, http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("Error marshalling schema: %w", err))
 		return
 	}
 
 	// Set response headers and write JSON response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(message.Version))
 	_, _ = w.Write(executionsJSON)
 }
 
@@ -52,5 +70,37 @@ func mapDomainMessageToSchema(message model.Message) MessageResponse {
 		ID:           message.ID,
 		Content:      message.Content,
 		IsPalindrome: message.IsPalindrome,
+		Mode:         string(message.Mode),
+		Analysis:     mapAnalysisToSchema(message.Analysis),
+		Version:      message.Version,
+		CreatedAt:    message.CreatedAt,
+		UpdatedAt:    message.UpdatedAt,
+		ExpiresAt:    message.ExpiresAt,
+		DeletedAt:    message.DeletedAt,
+	}
+}
+
+// mapAnalysisToSchema maps a palindrome analysis to a http schema.
+func mapAnalysisToSchema(analysis palindrome.Analysis) AnalysisResponse {
+	return AnalysisResponse{
+		Normalized:    analysis.Normalized,
+		Length:        analysis.Length,
+		FirstMismatch: analysis.FirstMismatch,
+		LongestPalindrome: SubstringResponse{
+			Text:  analysis.Longest.Text,
+			Start: analysis.Longest.Start,
+			End:   analysis.Longest.End,
+		},
+		DistinctPalindromes: analysis.DistinctPalindromes,
+	}
+}
+
+// mapRepairToSchema maps a palindrome repair to a http schema.
+func mapRepairToSchema(repair palindrome.Repair) *NearPalindromeResponse {
+	return &NearPalindromeResponse{
+		Insertions:    repair.Insertions,
+		Deletions:     repair.Deletions,
+		Substitutions: repair.Substitutions,
+		Repaired:      repair.Repaired,
 	}
 }
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 	svc "github.com/gharsallahmoez/palindrome/server/http"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
@@ -17,17 +19,21 @@ import (
 // TestGetMessageHandler tests GetMessageHandler function.
 func TestGetMessageHandler(t *testing.T) {
 	// Mock database get function for valid request
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
 	dbMock := &DatabaseMock{
 		GetMessageFunc: func(id string, ctx context.Context) (model.Message, error) {
 			return model.Message{
 				ID:           "1",
 				Content:      "test message",
 				IsPalindrome: false,
+				Version:      2,
+				CreatedAt:    createdAt,
+				UpdatedAt:    createdAt.Add(time.Hour),
 			}, nil
 		},
 	}
 
-	service := svc.NewMessageService(dbMock)
+	service := svc.NewMessageService(dbMock, config.New())
 
 	// Define test cases
 	testCases := []struct {
@@ -44,6 +50,9 @@ func TestGetMessageHandler(t *testing.T) {
 				ID:           "1",
 				Content:      "test message",
 				IsPalindrome: false,
+				Version:      2,
+				CreatedAt:    createdAt,
+				UpdatedAt:    createdAt.Add(time.Hour),
 			},
 		},
 		{
@@ -65,7 +74,7 @@ func TestGetMessageHandler(t *testing.T) {
 			req = mux.SetURLVars(req, map[string]string{"id": tc.ID})
 
 			// Create a response recorder to record the response
-			rr := httptest.NewRecorder()
+			rr := newRecorder(t, req)
 
 			// Call the GetMessageHandler method
 			handler := http.HandlerFunc(service.GetMessageHandler)
@@ -82,10 +91,102 @@ func TestGetMessageHandler(t *testing.T) {
 					t.Fatal(err)
 				}
 				assert.Equal(t, tc.ExpectedBody, response, "Response body should match")
+				assert.Equal(t, `"2"`, rr.Header().Get("ETag"), "ETag should be the version")
 			}
 		})
 	}
 
+	t.Run("with fields", func(t *testing.T) {
+		// Create a request selecting fields
+		req, err := http.NewRequest("GET", "/messages/1?fields=content,updated_at,expires_at", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the GetMessageHandler method
+		handler := http.HandlerFunc(service.GetMessageHandler)
+		handler.ServeHTTP(rr, req)
+
+		// The id is always returned, the unset optional fields are omitted
+		assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+		assert.JSONEq(t, `{"id": "1", "content": "test message", "updated_at": "2024-03-01T11:00:00Z"}`, rr.Body.String())
+	})
+
+	t.Run("with unknown fields", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/messages/1?fields=content,author", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the GetMessageHandler method
+		handler := http.HandlerFunc(service.GetMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should match")
+		assertProblem(t, rr, http.StatusBadRequest, "fields should be among id, content, is_palindrome, mode, analysis, version, created_at, updated_at, expires_at, deleted_at, near_palindrome")
+	})
+
+	t.Run("with near palindrome", func(t *testing.T) {
+		t.Parallel()
+		dbMock := &DatabaseMock{
+			GetMessageFunc: func(id string, ctx context.Context) (model.Message, error) {
+				return model.Message{ID: "1", Content: "Abca", Mode: palindrome.Alphanumeric}, nil
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("GET", "/messages/1?near_palindrome=true", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Set the request variables
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the GetMessageHandler method
+		handler := http.HandlerFunc(service.GetMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+
+		var response svc.MessageResponse
+		err = json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &svc.NearPalindromeResponse{Insertions: 1, Deletions: 1, Substitutions: 1, Repaired: "abcba"}
+		assert.Equal(t, expected, response.NearPalindrome, "Near palindrome should match")
+	})
+
+	t.Run("with invalid near palindrome flag", func(t *testing.T) {
+		t.Parallel()
+		service := svc.NewMessageService(&DatabaseMock{}, config.New())
+		req, err := http.NewRequest("GET", "/messages/1?near_palindrome=maybe", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Set the request variables
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the GetMessageHandler method
+		handler := http.HandlerFunc(service.GetMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should match")
+	})
+
 	t.Run("non-exist message", func(t *testing.T) {
 		t.Parallel()
 		dbMock := &DatabaseMock{
@@ -94,7 +195,7 @@ func TestGetMessageHandler(t *testing.T) {
 			},
 		}
 
-		service := svc.NewMessageService(dbMock)
+		service := svc.NewMessageService(dbMock, config.New())
 		req, err := http.NewRequest("GET", "/messages/1", nil)
 		if err != nil {
 			t.Fatal(err)
@@ -104,7 +205,7 @@ func TestGetMessageHandler(t *testing.T) {
 		req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
 
 		// Call the GetMessageHandler method
 		handler := http.HandlerFunc(service.GetMessageHandler)
@@ -120,7 +221,7 @@ func TestGetMessageHandler(t *testing.T) {
 			},
 		}
 
-		service := svc.NewMessageService(dbMock)
+		service := svc.NewMessageService(dbMock, config.New())
 		req, err := http.NewRequest("GET", "/messages/1", nil)
 		if err != nil {
 			t.Fatal(err)
@@ -130,7 +231,7 @@ func TestGetMessageHandler(t *testing.T) {
 		req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
 
 		// Call the GetMessageHandler method
 		handler := http.HandlerFunc(service.GetMessageHandler)
@@ -1,17 +1,47 @@
 package http
 
 import (
+	"encoding/json"
+	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/infra/database"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"net/http"
 )
 
+// errEmptyID is returned when the id of the path is empty.
+var errEmptyID = model.ErrInvalidRequest.Errorf("id should not be empty")
+
 // MessageService represents a service for managing messages.
 type MessageService struct {
 	database database.Database
+	config   *config.Config
 }
 
-// NewMessageService creates a new instance of MessageService with the provided database.
-func NewMessageService(repo database.Database) *MessageService {
+// NewMessageService creates a new instance of MessageService with the provided database and configuration.
+func NewMessageService(repo database.Database, conf *config.Config) *MessageService {
 	return &MessageService{
 		database: repo,
+		config:   conf,
+	}
+}
+
+// resolveMode returns the requested palindrome mode, falling back to the server-wide default.
+func (s *MessageService) resolveMode(requested string) (palindrome.Mode, error) {
+	if requested == "" {
+		requested = s.config.Palindrome.Mode
+	}
+	return palindrome.ParseMode(requested)
+}
+
+// writeJSON writes value as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	responseJSON, err := json.Marshal(value)
+	if err != nil {
+		writeProblem(w, newProblem(catalogued(err)))
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(responseJSON)
 }
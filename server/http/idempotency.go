@@ -0,0 +1,92 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// maxIdempotencyKeyLength is the maximum length of an Idempotency-Key header.
+const maxIdempotencyKeyLength = 255
+
+// claimIdempotencyKey claims the Idempotency-Key of a request, it returns false once it answered a retry.
+func (s *MessageService) claimIdempotencyKey(w http.ResponseWriter, r *http.Request, request MessageRequest) (*model.IdempotencyKey, bool) {
+	value := r.Header.Get("Idempotency-Key")
+	if value == "" || s.config.Server.IdempotencyKeyTTL <= 0 {
+		return nil, true
+	}
+	if len(value) > maxIdempotencyKeyLength {
+		writeError(w, r, model.ErrInvalidRequest.Errorf("Idempotency-Key cannot be longer than %d characters", maxIdempotencyKeyLength))
+		return nil, false
+	}
+
+	hash, err := requestHash(request)
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+	now := time.Now()
+	key := model.IdempotencyKey{Key: value, RequestHash: hash, ExpiresAt: now.Add(s.config.Server.IdempotencyKeyTTL)}
+	key.ClaimedUntil = key.ExpiresAt
+	if timeout := s.config.Server.IdempotencyClaimTimeout; timeout > 0 && now.Add(timeout).Before(key.ExpiresAt) {
+		key.ClaimedUntil = now.Add(timeout)
+	}
+	claimed, err := s.database.ClaimIdempotencyKey(key, r.Context())
+	switch {
+	case err == nil:
+		return &claimed, true
+	case !errors.Is(err, model.ErrIdempotencyKeyClaimed):
+		writeError(w, r, err)
+	case claimed.RequestHash != hash:
+		writeError(w, r, model.ErrIdempotencyKeyReused.Errorf("Idempotency-Key has already been used with another request"))
+	case !claimed.Completed():
+		writeError(w, r, model.ErrIdempotencyKeyClaimed.Errorf("a request with this Idempotency-Key is being processed"))
+	default:
+		// replay the original response
+		logrus.Infof("replaying the response to idempotency key %s", value)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", claimed.ETag)
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(claimed.Response)
+	}
+	return nil, false
+}
+
+// completeIdempotencyKey records the response to the request of a claimed key.
+func (s *MessageService) completeIdempotencyKey(key *model.IdempotencyKey, etag string, response []byte, ctx context.Context) {
+	if key == nil {
+		return
+	}
+	key.Response = response
+	key.ETag = etag
+	if err := s.database.CompleteIdempotencyKey(*key, context.WithoutCancel(ctx)); err != nil {
+		logrus.Errorf("failed to record the response to idempotency key %s: %v", key.Key, err)
+	}
+}
+
+// releaseIdempotencyKey releases a claimed key after its request failed.
+func (s *MessageService) releaseIdempotencyKey(key *model.IdempotencyKey, ctx context.Context) {
+	if key == nil {
+		return
+	}
+	if err := s.database.ReleaseIdempotencyKey(key.Key, context.WithoutCancel(ctx)); err != nil {
+		logrus.Errorf("failed to release idempotency key %s: %v", key.Key, err)
+	}
+}
+
+// requestHash returns the hash of a decoded request, which ignores the formatting of its body.
+func requestHash(request any) (string, error) {
+	content, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,269 @@
+package http
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// importBatchSize is the number of messages saved to the database at once during an import.
+	importBatchSize = 500
+	// maxImportLineSize bounds the size of an NDJSON line.
+	maxImportLineSize = 1 << 20
+	// maxImportErrors bounds the number of line errors reported by an import.
+	maxImportErrors = 1000
+)
+
+// ImportResponse is the outcome of an import.
+type ImportResponse struct {
+	Imported int `json:"imported"`
+	Failed   int `json:"failed"`
+	// Errors are the errors of the first failed lines, by line.
+	Errors []ImportError `json:"errors"`
+}
+
+// ImportError is the error of a line of an import.
+type ImportError struct {
+	Line int `json:"line"`
+	// Error is the message of the error in the catalogue.
+	Error string `json:"error"`
+}
+
+// recordReader reads the records of an import with their line numbers.
+type recordReader func() (ExportRecord, int, error)
+
+// recordError is the error of a single record of an import.
+type recordError struct {
+	err error
+}
+
+func (e recordError) Error() string {
+	return e.err.Error()
+}
+
+func (e recordError) Unwrap() error {
+	return e.err
+}
+
+// ImportMessagesHandler handles HTTP requests to import messages in the format of the exports.
+func (s *MessageService) ImportMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	format, err := parseFormat(r)
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+	var read recordReader
+	if format == formatCSV {
+		read, err = newCSVRecordReader(r.Body)
+	} else {
+		read = newNDJSONRecordReader(r.Body)
+	}
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	response := ImportResponse{Errors: []ImportError{}}
+	fail := func(line int, err error) {
+		response.Failed++
+		if len(response.Errors) < maxImportErrors {
+			response.Errors = append(response.Errors, ImportError{Line: line, Error: catalogued(err).Error()})
+		}
+	}
+	var (
+		messages []model.Message
+		lines    []int
+	)
+	save := func() error {
+		if len(messages) == 0 {
+			return nil
+		}
+		saves, err := s.database.SaveMessages(messages, r.Context())
+		if err != nil {
+			return err
+		}
+		for i, save := range saves {
+			switch {
+			case save.Err == nil:
+				response.Imported++
+			case errors.Is(save.Err, model.ErrDuplicateMessage):
+				fail(lines[i], duplicateError(save.Message))
+			default:
+				fail(lines[i], save.Err)
+			}
+		}
+		messages, lines = messages[:0], lines[:0]
+		return nil
+	}
+
+	for {
+		record, line, err := read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var recordErr recordError
+		if errors.As(err, &recordErr) {
+			fail(line, err)
+			continue
+		}
+		if err != nil {
+			// the body cannot be read further, the records read so far are still saved
+			fail(line, err)
+			break
+		}
+		message, err := s.importedMessage(record)
+		if err != nil {
+			fail(line, err)
+			continue
+		}
+		messages, lines = append(messages, message), append(lines, line)
+		if len(messages) == importBatchSize {
+			if err := save(); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+	}
+	if err := save(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// importedMessage returns the message of an imported record, with its id and timestamps when set.
+func (s *MessageService) importedMessage(record ExportRecord) (model.Message, error) {
+	message, err := s.newMessage(MessageRequest{Content: record.Content, Mode: record.Mode, ExpiresAt: record.ExpiresAt})
+	if err != nil {
+		return model.Message{}, err
+	}
+	if record.IsPalindrome != nil && *record.IsPalindrome != message.IsPalindrome {
+		return model.Message{}, invalid(fmt.Errorf("is_palindrome should be %t in %s mode", message.IsPalindrome, message.Mode))
+	}
+	if record.ID != "" {
+		message.ID = record.ID
+	}
+	if record.CreatedAt != nil {
+		message.CreatedAt, message.UpdatedAt = *record.CreatedAt, *record.CreatedAt
+	}
+	if record.UpdatedAt != nil {
+		if record.UpdatedAt.Before(message.CreatedAt) {
+			return model.Message{}, invalid(errors.New("updated_at should not be before created_at"))
+		}
+		message.UpdatedAt = *record.UpdatedAt
+	}
+	return message, nil
+}
+
+// newNDJSONRecordReader returns a reader of the JSON lines of body.
+func newNDJSONRecordReader(body io.Reader) recordReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, maxImportLineSize)
+	line := 0
+	return func() (ExportRecord, int, error) {
+		for scanner.Scan() {
+			line++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var record ExportRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return ExportRecord{}, line, recordError{lineDecodeError(err)}
+			}
+			return record, line, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return ExportRecord{}, line + 1, readError(err)
+		}
+		return ExportRecord{}, line, io.EOF
+	}
+}
+
+// newCSVRecordReader returns a reader of the CSV records of body.
+func newCSVRecordReader(body io.Reader) (recordReader, error) {
+	reader := csv.NewReader(body)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header should be readable: %w", err)
+	}
+	columns := map[string]int{}
+	for index, name := range header {
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("column %q is unknown", name)
+		}
+		columns[name] = index
+	}
+	if _, exists := columns["content"]; !exists {
+		return nil, errors.New("content column is required")
+	}
+
+	line := 1
+	return func() (ExportRecord, int, error) {
+		fields, err := reader.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.Line
+			return ExportRecord{}, parseErr.StartLine, recordError{invalid(parseErr.Err)}
+		}
+		if errors.Is(err, io.EOF) {
+			return ExportRecord{}, line + 1, err
+		}
+		if err != nil {
+			return ExportRecord{}, line + 1, readError(err)
+		}
+		line, _ = reader.FieldPos(0)
+		value := func(name string) string {
+			if index, exists := columns[name]; exists {
+				return fields[index]
+			}
+			return ""
+		}
+		record := ExportRecord{ID: value("id"), Content: value("content"), Mode: value("mode")}
+		if v := value("is_palindrome"); v != "" {
+			isPalindrome, err := strconv.ParseBool(v)
+			if err != nil {
+				return ExportRecord{}, line, recordError{invalid(errors.New("is_palindrome should be a boolean"))}
+			}
+			record.IsPalindrome = &isPalindrome
+		}
+		for name, t := range map[string]**time.Time{"created_at": &record.CreatedAt, "updated_at": &record.UpdatedAt, "expires_at": &record.ExpiresAt} {
+			if v := value(name); v != "" {
+				parsed, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return ExportRecord{}, line, recordError{invalid(fmt.Errorf("%s should be an RFC 3339 timestamp", name))}
+				}
+				*t = &parsed
+			}
+		}
+		return record, line, nil
+	}, nil
+}
+
+// lineDecodeError returns the error of an NDJSON line which cannot be decoded.
+func lineDecodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return decodeError(err)
+	}
+	return model.ErrMalformedBody.Errorf("line should be valid JSON")
+}
+
+// readError returns the error stopping the read of an import body, without the details of the reader.
+func readError(err error) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return model.ErrInvalidRequest.Errorf("line should not exceed %d bytes", maxImportLineSize)
+	}
+	logrus.Errorf("import interrupted: %v", err)
+	return model.ErrInvalidRequest.Errorf("body could not be read further")
+}
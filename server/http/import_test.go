@@ -0,0 +1,195 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestImportMessagesHandler tests ImportMessagesHandler function.
+func TestImportMessagesHandler(t *testing.T) {
+	// Mock database save function saving every message but the ones with the taken id
+	var saved []model.Message
+	dbMock := &DatabaseMock{
+		SaveMessagesFunc: func(messages []model.Message, ctx context.Context) ([]model.BatchResult, error) {
+			results := make([]model.BatchResult, len(messages))
+			for i, message := range messages {
+				if message.ID == "taken" {
+					results[i].Err = model.ErrMessageIDTaken
+					continue
+				}
+				results[i].Message = message
+				saved = append(saved, message)
+			}
+			return results, nil
+		},
+	}
+
+	service := svc.NewMessageService(dbMock, config.New())
+	createdAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	// Define test cases
+	testCases := []struct {
+		Name             string
+		Format           string
+		RequestBody      string
+		ExpectedCode     int
+		ExpectedMessage  string
+		ExpectedResponse svc.ImportResponse
+	}{
+		{
+			Name: "NDJSON",
+			RequestBody: `{"id": "a", "content": "kayak", "is_palindrome": true, "created_at": "2024-03-01T10:00:00Z", "updated_at": "2024-03-02T10:00:00Z"}
+{"content": "hello", "is_palindrome": true}
+
+{"content": 
+{"id": "taken", "content": "level"}
+{"content": ""}
+{"content": "Race car", "mode": "strict", "created_at": "2024-03-01T10:00:00Z", "updated_at": "2024-02-01T10:00:00Z"}
+{"id": "b", "content": "Race car", "mode": "strict", "created_at": "2024-03-01T10:00:00Z"}
+{"content": 12}
+`,
+			ExpectedCode: http.StatusOK,
+			ExpectedResponse: svc.ImportResponse{Imported: 2, Failed: 6, Errors: []svc.ImportError{
+				{Line: 2, Error: "is_palindrome should be false in alphanumeric mode"},
+				{Line: 4, Error: "line should be valid JSON"},
+				{Line: 6, Error: "content cannot be empty"},
+				{Line: 7, Error: "updated_at should not be before created_at"},
+				{Line: 9, Error: "content has an invalid type"},
+				{Line: 5, Error: "message id already taken"},
+			}},
+		},
+		{
+			Name:   "CSV",
+			Format: "csv",
+			RequestBody: `content,id,created_at,is_palindrome,mode
+kayak,a,2024-03-01T10:00:00Z,true,
+"Race
+car",b,2024-03-01T10:00:00Z,,strict
+hello,c,yesterday,false,
+level,d
+`,
+			ExpectedCode: http.StatusOK,
+			ExpectedResponse: svc.ImportResponse{Imported: 2, Failed: 2, Errors: []svc.ImportError{
+				{Line: 5, Error: "created_at should be an RFC 3339 timestamp"},
+				{Line: 6, Error: "wrong number of fields"},
+			}},
+		},
+		{
+			Name:            "Unknown CSV column",
+			Format:          "csv",
+			RequestBody:     "content,analysis\nkayak,{}\n",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "column \"analysis\" is unknown",
+		},
+		{
+			Name:            "Missing CSV content",
+			Format:          "csv",
+			RequestBody:     "id\na\n",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "content column is required",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			saved = nil
+
+			// Create a request with the request body
+			req, err := http.NewRequest("POST", "/messages/import?format="+tc.Format, bytes.NewBufferString(tc.RequestBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the ImportMessagesHandler method
+			handler := http.HandlerFunc(service.ImportMessagesHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedMessage != "" {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
+				return
+			}
+
+			var response svc.ImportResponse
+			err = json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.ExpectedResponse, response, "Response body should match")
+
+			// The ids and timestamps are kept, the palindrome statuses recomputed
+			require.Len(t, saved, 2)
+			assert.Equal(t, "a", saved[0].ID)
+			assert.Equal(t, createdAt, saved[0].CreatedAt)
+			assert.True(t, saved[0].IsPalindrome)
+			assert.Equal(t, "b", saved[1].ID)
+			assert.Equal(t, palindrome.Strict, saved[1].Mode)
+			assert.False(t, saved[1].IsPalindrome)
+			assert.Equal(t, createdAt, saved[1].UpdatedAt)
+		})
+	}
+
+	t.Run("with failed body read", func(t *testing.T) {
+		saved = nil
+		body := io.MultiReader(bytes.NewBufferString("{\"content\": \"kayak\"}\n"), iotest.ErrReader(errors.New("connection reset")))
+		req, err := http.NewRequest("POST", "/messages/import", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the ImportMessagesHandler method
+		handler := http.HandlerFunc(service.ImportMessagesHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+
+		// The records read before the failure are saved, the reader error is not returned
+		var response svc.ImportResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, svc.ImportResponse{Imported: 1, Failed: 1, Errors: []svc.ImportError{
+			{Line: 2, Error: "body could not be read further"},
+		}}, response, "Response body should match")
+	})
+
+	t.Run("with failed db operation", func(t *testing.T) {
+		dbMock := &DatabaseMock{
+			SaveMessagesFunc: func(messages []model.Message, ctx context.Context) ([]model.BatchResult, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("POST", "/messages/import", bytes.NewBufferString(`{"content": "kayak"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the ImportMessagesHandler method
+		handler := http.HandlerFunc(service.ImportMessagesHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
+	})
+}
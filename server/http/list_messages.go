@@ -2,35 +2,135 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/sirupsen/logrus"
+	"github.com/gharsallahmoez/palindrome/model"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 )
 
+const (
+	// defaultListLimit is the page size when the limit is not set.
+	defaultListLimit = 100
+	// maxListLimit bounds the page size.
+	maxListLimit = 1000
+)
+
+// nextCursorHeader holds the cursor of the next page, it is absent on the last page.
+const nextCursorHeader = "X-Next-Cursor"
+
 // ListMessageHandler handles HTTP requests to list messages.
 func (s *MessageService) ListMessageHandler(w http.ResponseWriter, r *http.Request) {
-	messages, err := s.database.ListMessages(r.Context())
+	s.listMessages(w, r, false)
+}
+
+// TrashHandler handles HTTP requests to list the deleted messages, most recently deleted first by default.
+func (s *MessageService) TrashHandler(w http.ResponseWriter, r *http.Request) {
+	s.listMessages(w, r, true)
+}
+
+// listMessages writes a page of the messages, or of the deleted messages, matching the query string.
+func (s *MessageService) listMessages(w http.ResponseWriter, r *http.Request, deleted bool) {
+	query, err := parseListQuery(r.URL.Query(), deleted)
 	if err != nil {
-		logrus.Errorf(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, invalid(err))
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, r, invalid(err))
 		return
 	}
-	httpMessages := make([]MessageResponse, len(messages))
 
-	for index := range messages {
-		httpMessages[index] = mapDomainMessageToSchema(messages[index])
+	page, err := s.database.ListMessages(query, r.Context())
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidCursor) {
+			err = model.ErrInvalidCursor.Errorf("cursor is invalid for this sort")
+		}
+		writeError(w, r, err)
+		return
+	}
+	httpMessages := make([]any, len(page.Messages))
+
+	for index := range page.Messages {
+		httpMessages[index] = fields.apply(versionOf(r).schema.message(page.Messages[index], nil))
 	}
 
 	// Marshal message schema into JSON
 	messagesJSON, err := json.Marshal(httpMessages)
 	if err != nil {
-		errMsg := fmt.Sprintf("Error marshalling schema: %v", err)
-		logrus.Errorf(errMsg)
-		http.Error(w, errMsg, http.StatusInternalServerError)
+		writeError(w, r, fmt.Errorf("Error marshalling schema: %w", err))
 		return
 	}
 
 	// Set response headers and write JSON response
+	if page.NextCursor != "" {
+		w.Header().Set(nextCursorHeader, page.NextCursor)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	_, _ = w.Write(messagesJSON)
 }
+
+// parseListQuery reads the pagination, filtering and sorting options of a listing from the query string.
+func parseListQuery(values url.Values, deleted bool) (model.ListQuery, error) {
+	query := model.ListQuery{
+		Deleted:  deleted,
+		Limit:    defaultListLimit,
+		Cursor:   values.Get("cursor"),
+		Contains: values.Get("contains"),
+		SortBy:   model.SortField(values.Get("sort")),
+		Order:    model.SortOrder(values.Get("order")),
+	}
+	if deleted && query.SortBy == "" {
+		query.SortBy = model.SortByDeletedAt
+		if query.Order == "" {
+			query.Order = model.Descending
+		}
+	}
+	query = query.WithDefaults()
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return model.ListQuery{}, fmt.Errorf("limit should be an integer between 1 and %d", maxListLimit)
+		}
+		query.Limit = limit
+	}
+	if value := values.Get("is_palindrome"); value != "" {
+		isPalindrome, err := strconv.ParseBool(value)
+		if err != nil {
+			return model.ListQuery{}, errors.New("is_palindrome should be a boolean")
+		}
+		query.IsPalindrome = &isPalindrome
+	}
+	for name, bound := range map[string]*time.Time{
+		"created_after":  &query.CreatedAfter,
+		"created_before": &query.CreatedBefore,
+		"updated_after":  &query.UpdatedAfter,
+		"updated_before": &query.UpdatedBefore,
+	} {
+		if value := values.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return model.ListQuery{}, fmt.Errorf("%s should be an RFC 3339 timestamp", name)
+			}
+			*bound = t
+		}
+	}
+	switch {
+	case query.SortBy == model.SortByCreatedAt, query.SortBy == model.SortByUpdatedAt:
+	case query.SortBy == model.SortByDeletedAt && deleted:
+	case deleted:
+		return model.ListQuery{}, fmt.Errorf("sort should be %s, %s or %s", model.SortByCreatedAt, model.SortByUpdatedAt, model.SortByDeletedAt)
+	default:
+		return model.ListQuery{}, fmt.Errorf("sort should be %s or %s", model.SortByCreatedAt, model.SortByUpdatedAt)
+	}
+	switch query.Order {
+	case model.Ascending, model.Descending:
+	default:
+		return model.ListQuery{}, fmt.Errorf("order should be %s or %s", model.Ascending, model.Descending)
+	}
+	return query, nil
+}
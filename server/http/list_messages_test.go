@@ -5,9 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/model"
 	svc "github.com/gharsallahmoez/palindrome/server/http"
 	"github.com/stretchr/testify/assert"
@@ -17,18 +18,18 @@ import (
 func TestListMessageHandler(t *testing.T) {
 	// Mock database list function for valid request
 	dbMock := &DatabaseMock{
-		ListMessagesFunc: func(ctx context.Context) ([]model.Message, error) {
-			return []model.Message{
+		ListMessagesFunc: func(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+			return model.MessagePage{Messages: []model.Message{
 				{
 					ID:           "1",
 					Content:      "test message",
 					IsPalindrome: false,
 				},
-			}, nil
+			}}, nil
 		},
 	}
 
-	service := svc.NewMessageService(dbMock)
+	service := svc.NewMessageService(dbMock, config.New())
 
 	var expectedBody = []svc.MessageResponse{{
 		ID:           "1",
@@ -45,7 +46,7 @@ func TestListMessageHandler(t *testing.T) {
 		}
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
 
 		// Call the ListMessageHandler method
 		handler := http.HandlerFunc(service.ListMessageHandler)
@@ -67,23 +68,147 @@ func TestListMessageHandler(t *testing.T) {
 	t.Run("with failed db operation", func(t *testing.T) {
 		t.Parallel()
 		dbMock := &DatabaseMock{
-			ListMessagesFunc: func(ctx context.Context) ([]model.Message, error) {
-				return nil, errors.New("some error")
+			ListMessagesFunc: func(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+				return model.MessagePage{}, errors.New("some error")
 			},
 		}
 
-		service := svc.NewMessageService(dbMock)
+		service := svc.NewMessageService(dbMock, config.New())
 		req, err := http.NewRequest("GET", "/messages", nil)
 		if err != nil {
 			t.Fatal(err)
 		}
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
 
 		// Call the ListMessageHandler method
 		handler := http.HandlerFunc(service.ListMessageHandler)
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
 	})
+
+	t.Run("with query options", func(t *testing.T) {
+		t.Parallel()
+		var received model.ListQuery
+		dbMock := &DatabaseMock{
+			ListMessagesFunc: func(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+				received = query
+				return model.MessagePage{NextCursor: "next"}, nil
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("GET", "/messages?limit=2&cursor=abc&is_palindrome=true&contains=kay&sort=updated_at&order=desc&created_after=2024-01-02T15:04:05Z", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the ListMessageHandler method
+		handler := http.HandlerFunc(service.ListMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+		assert.Equal(t, "next", rr.Header().Get("X-Next-Cursor"))
+		assert.Equal(t, 2, received.Limit)
+		assert.Equal(t, "abc", received.Cursor)
+		assert.True(t, *received.IsPalindrome)
+		assert.Equal(t, "kay", received.Contains)
+		assert.Equal(t, model.SortByUpdatedAt, received.SortBy)
+		assert.Equal(t, model.Descending, received.Order)
+		assert.Equal(t, time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), received.CreatedAfter)
+	})
+
+	t.Run("with invalid query options", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			name  string
+			query string
+		}{
+			{"limit not a number", "limit=ten"},
+			{"limit too large", "limit=1001"},
+			{"limit zero", "limit=0"},
+			{"is_palindrome not a boolean", "is_palindrome=maybe"},
+			{"invalid timestamp", "created_before=yesterday"},
+			{"unknown sort", "sort=content"},
+			{"unknown order", "order=random"},
+		}
+		service := svc.NewMessageService(&DatabaseMock{}, config.New())
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req, err := http.NewRequest("GET", "/messages?"+tt.query, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rr := newRecorder(t, req)
+				http.HandlerFunc(service.ListMessageHandler).ServeHTTP(rr, req)
+				assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should match")
+			})
+		}
+	})
+
+	t.Run("with invalid cursor", func(t *testing.T) {
+		t.Parallel()
+		dbMock := &DatabaseMock{
+			ListMessagesFunc: func(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+				return model.MessagePage{}, model.ErrInvalidCursor
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("GET", "/messages?cursor=garbage", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := newRecorder(t, req)
+		http.HandlerFunc(service.ListMessageHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should match")
+	})
+}
+
+// TestTrashHandler tests TrashHandler function.
+func TestTrashHandler(t *testing.T) {
+	deletedAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	var received model.ListQuery
+	dbMock := &DatabaseMock{
+		ListMessagesFunc: func(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
+			received = query
+			return model.MessagePage{Messages: []model.Message{{ID: "1", Content: "kayak", DeletedAt: &deletedAt}}}, nil
+		},
+	}
+	service := svc.NewMessageService(dbMock, config.New())
+
+	t.Run("valid request", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/trash", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := newRecorder(t, req)
+		http.HandlerFunc(service.TrashHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+
+		// The most recently deleted messages come first
+		assert.True(t, received.Deleted)
+		assert.Equal(t, model.SortByDeletedAt, received.SortBy)
+		assert.Equal(t, model.Descending, received.Order)
+		var response []svc.MessageResponse
+		err = json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, response, 1)
+		assert.Equal(t, &deletedAt, response[0].DeletedAt, "Deletion time should match")
+	})
+
+	t.Run("deletion time sort outside of the trash", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/messages?sort=deleted_at", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := newRecorder(t, req)
+		http.HandlerFunc(service.ListMessageHandler).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "Status code should match")
+	})
 }
@@ -0,0 +1,312 @@
+package http
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/model"
+)
+
+// openAPIVersion is the version of the OpenAPI specification followed by the document.
+const openAPIVersion = "3.0.3"
+
+// schemaRefPrefix prefixes the names of the schemas of the components to reference them.
+const schemaRefPrefix = "#/components/schemas/"
+
+// docsPage renders the OpenAPI document with Swagger UI.
+//
+//go:embed docs.html
+var docsPage []byte
+
+// OpenAPI is an OpenAPI 3 document describing the API.
+type OpenAPI struct {
+	OpenAPI string `json:"openapi"`
+	Info    Info   `json:"info"`
+	// Servers hold the path prefix of the described version.
+	Servers []Server `json:"servers"`
+	// Paths are the path items by path template.
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// Server is a base URL of the API.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem are the operations of a path by lower-case method.
+type PathItem map[string]*Operation
+
+// Operation describes a route.
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Summary     string       `json:"summary"`
+	Parameters  []Parameter  `json:"parameters,omitempty"`
+	RequestBody *RequestBody `json:"requestBody,omitempty"`
+	// Responses are the responses by status code.
+	Responses map[string]Response `json:"responses"`
+}
+
+// Parameter describes a path, query or header parameter of an operation.
+type Parameter struct {
+	Name string `json:"name"`
+	// In is path, query or header.
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of the requests of an operation.
+type RequestBody struct {
+	Required bool `json:"required"`
+	// Content are the schemas of the body by media type.
+	Content map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string            `json:"description"`
+	Headers     map[string]Header `json:"headers,omitempty"`
+	// Content are the schemas of the body by media type, it is empty without body.
+	Content map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header describes a header of a response.
+type Header struct {
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced by the operations.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema describes a JSON value, as the subset of JSON Schema supported by OpenAPI 3.0.
+type Schema struct {
+	// Ref references a schema of the components.
+	Ref         string   `json:"$ref,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Format      string   `json:"format,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Nullable    bool     `json:"nullable,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	// Properties are the schemas of the fields of an object, by name.
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	// Items is the schema of the elements of an array.
+	Items *Schema `json:"items,omitempty"`
+}
+
+// operation documents a route.
+type operation struct {
+	id         string
+	summary    string
+	parameters []Parameter
+	// request lists the media types of the request body, it is empty without body.
+	request   []content
+	responses []response
+	// problems are the statuses of the problems returned besides the internal errors.
+	problems []int
+}
+
+// response documents a response of a route.
+type response struct {
+	status      int
+	description string
+	// headers are names of responseHeaders.
+	headers []string
+	content []content
+}
+
+// content is a body of a media type.
+type content struct {
+	mediaType string
+	value     any
+	schema    *Schema
+}
+
+// jsonContent returns the JSON body holding value.
+func jsonContent(value any) []content {
+	return []content{{mediaType: "application/json", value: value}}
+}
+
+// responseHeaders describes the headers of the responses by name.
+var responseHeaders = map[string]Header{
+	"ETag":                {Description: "The strong entity tag of the message version.", Schema: &Schema{Type: "string"}},
+	"Content-Location":    {Description: "The path of the returned message.", Schema: &Schema{Type: "string"}},
+	"Idempotent-Replayed": {Description: "Set to true when the response is replayed for an Idempotency-Key.", Schema: &Schema{Type: "string"}},
+	nextCursorHeader:      {Description: "The cursor of the next page, absent on the last page.", Schema: &Schema{Type: "string"}},
+	"Content-Disposition": {Description: "Names the exported file.", Schema: &Schema{Type: "string"}},
+}
+
+// OpenAPIHandler handles HTTP requests to the OpenAPI document describing every route of the API version.
+func (s *MessageService) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	version := versionOf(r).apiVersion
+	writeJSON(w, http.StatusOK, newOpenAPI(version, version.routes(s)))
+}
+
+// DocsHandler handles HTTP requests to the interactive documentation of the API.
+func (s *MessageService) DocsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(docsPage)
+}
+
+// newOpenAPI returns the OpenAPI document describing the routes of the version.
+func newOpenAPI(version apiVersion, routes []route) OpenAPI {
+	components := schemas{}
+	document := OpenAPI{
+		OpenAPI: openAPIVersion,
+		Info: Info{
+			Title:       "Palindrome messages API",
+			Description: "Stores messages and tells whether they are palindromes. The errors are RFC 7807 problem details.",
+			Version:     "1.0.0",
+		},
+		Servers: []Server{{URL: version.prefix()}},
+		Paths:   map[string]PathItem{},
+	}
+	for _, route := range routes {
+		if document.Paths[route.path] == nil {
+			document.Paths[route.path] = PathItem{}
+		}
+		document.Paths[route.path][strings.ToLower(route.method)] = route.doc.build(components)
+	}
+	// the fields parameter may drop every field of the messages but the id
+	components[schemaName(reflect.TypeOf(version.schema.message(model.Message{}, nil)))].Required = []string{"id"}
+	document.Components.Schemas = components
+	return document
+}
+
+// build returns the OpenAPI operation of o, adding the schemas of its bodies to the components.
+func (o operation) build(components schemas) *Operation {
+	op := &Operation{OperationID: o.id, Summary: o.summary, Parameters: o.parameters, Responses: map[string]Response{}}
+	if len(o.request) > 0 {
+		op.RequestBody = &RequestBody{Required: true, Content: components.content(o.request)}
+	}
+	responses := slices.Clone(o.responses)
+	for _, status := range append(o.problems, http.StatusInternalServerError) {
+		responses = append(responses, response{
+			status:      status,
+			description: http.StatusText(status) + ", as a problem.",
+			content:     []content{{mediaType: problemContentType, value: Problem{}}},
+		})
+	}
+	for _, resp := range responses {
+		documented := Response{Description: resp.description, Content: components.content(resp.content)}
+		for _, name := range resp.headers {
+			if documented.Headers == nil {
+				documented.Headers = map[string]Header{}
+			}
+			documented.Headers[name] = responseHeaders[name]
+		}
+		op.Responses[strconv.Itoa(resp.status)] = documented
+	}
+	return op
+}
+
+// schemas are the schemas of the components by name, generated from the Go types of the bodies.
+type schemas map[string]*Schema
+
+// content returns the schemas of the bodies by media type.
+func (c schemas) content(bodies []content) map[string]MediaType {
+	if len(bodies) == 0 {
+		return nil
+	}
+	media := map[string]MediaType{}
+	for _, body := range bodies {
+		schema := body.schema
+		switch {
+		case schema != nil:
+		case body.value == nil:
+			schema = &Schema{Type: "string"}
+		default:
+			schema = c.of(reflect.TypeOf(body.value))
+		}
+		media[body.mediaType] = MediaType{Schema: schema}
+	}
+	return media
+}
+
+// of returns the schema of the JSON encoding of a Go type.
+func (c schemas) of(t reflect.Type) *Schema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return c.of(t.Elem())
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, exists := c[name]; !exists {
+			schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+			c[name] = schema
+			c.addFields(schema, t)
+		}
+		return &Schema{Ref: schemaRefPrefix + name}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: c.of(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+	// any value
+	return &Schema{}
+}
+
+// addFields adds the exported fields of a struct to the properties of its schema.
+func (c schemas) addFields(schema *Schema, t reflect.Type) {
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch {
+		case !field.IsExported() || name == "-":
+			continue
+		case field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct:
+			c.addFields(schema, field.Type)
+			continue
+		case name == "":
+			name = field.Name
+		}
+		schema.Properties[name] = c.of(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// schemaName returns the name of the schema of a struct.
+func schemaName(t reflect.Type) string {
+	name, arguments, generic := strings.Cut(t.Name(), "[")
+	if !generic {
+		return name
+	}
+	for _, argument := range strings.Split(strings.TrimSuffix(arguments, "]"), ",") {
+		name += "Of" + argument[strings.LastIndex(argument, ".")+1:]
+	}
+	return name
+}
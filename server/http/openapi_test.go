@@ -0,0 +1,349 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	in_memory "github.com/gharsallahmoez/palindrome/infra/database/in-memory"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// documentedHeaders are the response headers which should be documented whenever they are returned.
+var documentedHeaders = []string{"ETag", "Content-Location", "Idempotent-Replayed", "X-Next-Cursor", "Content-Disposition"}
+
+// openAPIDocument is the part of the OpenAPI document checked by the tests.
+type openAPIDocument struct {
+	OpenAPI    string                               `json:"openapi"`
+	Servers    []map[string]string                  `json:"servers"`
+	Paths      map[string]map[string]map[string]any `json:"paths"`
+	Components struct {
+		Schemas map[string]map[string]any `json:"schemas"`
+	} `json:"components"`
+}
+
+// specification returns the OpenAPI document of the version 1, which validates the responses of the handler tests.
+var specification = sync.OnceValues(func() (openAPIDocument, error) {
+	conf := config.New()
+	runner := &svc.Runner{MessageService: svc.NewMessageService(in_memory.NewRepo(), conf), Config: &conf.Server}
+	runner.RegisterServices()
+	rr := httptest.NewRecorder()
+	runner.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	var document openAPIDocument
+	err := json.Unmarshal(rr.Body.Bytes(), &document)
+	return document, err
+})
+
+// newRecorder returns a response recorder whose response to req is validated against the OpenAPI document
+// at the end of the test.
+func newRecorder(t *testing.T, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	t.Cleanup(func() {
+		document, err := specification()
+		require.NoError(t, err)
+		validateResponse(t, document, req, rr)
+	})
+	return rr
+}
+
+// documentedRoute returns the path of the document routing req, the literal segments taking precedence.
+func documentedRoute(document openAPIDocument, req *http.Request) (string, bool) {
+	segments := strings.Split(strings.TrimPrefix(req.URL.Path, "/v1"), "/")
+	var (
+		matched   string
+		variables = len(segments) + 1
+	)
+	for path := range document.Paths {
+		templates := strings.Split(path, "/")
+		if len(templates) != len(segments) {
+			continue
+		}
+		count := 0
+		for i, template := range templates {
+			if strings.HasPrefix(template, "{") {
+				count++
+			} else if template != segments[i] {
+				count = -1
+				break
+			}
+		}
+		if count >= 0 && count < variables {
+			matched, variables = path, count
+		}
+	}
+	return matched, matched != ""
+}
+
+// validateResponse checks the response to req against its documentation and returns the documented route.
+func validateResponse(t *testing.T, document openAPIDocument, req *http.Request, rr *httptest.ResponseRecorder) string {
+	route, ok := documentedRoute(document, req)
+	if !assert.True(t, ok, "%s %s should be documented", req.Method, req.URL.Path) {
+		return ""
+	}
+	operation, ok := document.Paths[route][strings.ToLower(req.Method)]
+	if !assert.True(t, ok, "%s %s should be documented", req.Method, route) {
+		return route
+	}
+	responses, _ := operation["responses"].(map[string]any)
+	documented, ok := responses[fmt.Sprint(rr.Code)].(map[string]any)
+	if !assert.True(t, ok, "Status %d of %s %s should be documented", rr.Code, req.Method, route) {
+		return route
+	}
+	headers, _ := documented["headers"].(map[string]any)
+	for _, name := range documentedHeaders {
+		if rr.Header().Get(name) != "" {
+			assert.Contains(t, headers, name, "Header should be documented")
+		}
+	}
+	if rr.Body.Len() == 0 {
+		return route
+	}
+	mediaType, _, err := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	if !assert.NoError(t, err) {
+		return route
+	}
+	contents, _ := documented["content"].(map[string]any)
+	media, ok := contents[mediaType].(map[string]any)
+	if !assert.True(t, ok, "Media type %s of %s %s should be documented", mediaType, req.Method, route) {
+		return route
+	}
+	schema := media["schema"].(map[string]any)
+
+	// Validate the body against the schema
+	var bodies []string
+	switch {
+	case mediaType == "application/x-ndjson":
+		bodies = strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	case strings.HasSuffix(mediaType, "json"):
+		bodies = []string{rr.Body.String()}
+	}
+	validator := schemaValidator{schemas: document.Components.Schemas}
+	for _, body := range bodies {
+		var value any
+		if assert.NoError(t, json.Unmarshal([]byte(body), &value)) {
+			assert.Empty(t, validator.validate(schema, value, "body"), "Body of %s %s should match the schema", req.Method, route)
+		}
+	}
+	return route
+}
+
+// TestOpenAPI tests that the OpenAPI document of the version 1 describes every registered route, under /v1 and
+// unversioned, and every response of the routes to a sequence of requests against an in-memory database.
+func TestOpenAPI(t *testing.T) {
+	conf := config.New()
+	runner := &svc.Runner{MessageService: svc.NewMessageService(in_memory.NewRepo(), conf), Config: &conf.Server}
+	runner.RegisterServices()
+
+	// Retrieve the document
+	rr := httptest.NewRecorder()
+	runner.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+	var document openAPIDocument
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &document))
+	assert.True(t, strings.HasPrefix(document.OpenAPI, "3."), "Document should follow OpenAPI 3")
+	assert.Equal(t, []map[string]string{{"url": "/v1"}}, document.Servers, "Paths should be relative to /v1")
+
+	t.Run("every route is documented", func(t *testing.T) {
+		registered := map[string]bool{}
+		err := runner.Router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return err
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				return err
+			}
+			for _, method := range methods {
+				registered[method+" "+path] = true
+				path := strings.TrimPrefix(path, "/v1")
+				assert.Contains(t, document.Paths[path], strings.ToLower(method), "%s %s should be documented", method, path)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		for path, item := range document.Paths {
+			for method := range item {
+				assert.True(t, registered[strings.ToUpper(method)+" /v1"+path], "%s /v1%s should be registered", method, path)
+				assert.True(t, registered[strings.ToUpper(method)+" "+path], "%s %s should be registered as an alias", method, path)
+			}
+		}
+	})
+
+	// Define the requests, in order, the first one importing the message 1
+	testCases := []struct {
+		Method       string
+		Path         string
+		ContentType  string
+		Headers      map[string]string
+		Body         string
+		ExpectedCode int
+	}{
+		{Method: "POST", Path: "/messages/import", ContentType: "application/x-ndjson",
+			Body: "{\"id\": \"1\", \"content\": \"kayak\"}\n{\"content\": \"\"}\n", ExpectedCode: http.StatusOK},
+		{Method: "POST", Path: "/messages/import?format=xml", ExpectedCode: http.StatusBadRequest},
+		{Method: "POST", Path: "/messages", Body: `{"content": "level"}`, ExpectedCode: http.StatusCreated},
+		{Method: "POST", Path: "/messages", Body: `{"content": ""}`, ExpectedCode: http.StatusUnprocessableEntity},
+		{Method: "POST", Path: "/messages", Body: `{`, ExpectedCode: http.StatusBadRequest},
+		{Method: "GET", Path: "/messages?limit=1", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/messages?limit=0", ExpectedCode: http.StatusBadRequest},
+		{Method: "GET", Path: "/messages/search?q=kayak", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/messages/search", ExpectedCode: http.StatusBadRequest},
+		{Method: "GET", Path: "/messages/export", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/messages/export?format=csv", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/messages/1?near_palindrome=true", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/messages/1?fields=content", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/messages/2", ExpectedCode: http.StatusNotFound},
+		{Method: "PUT", Path: "/messages/1", Body: `{"content": "racecar"}`, ExpectedCode: http.StatusOK},
+		{Method: "PUT", Path: "/messages/1", Headers: map[string]string{"If-Match": `"99"`},
+			Body: `{"content": "racecar"}`, ExpectedCode: http.StatusPreconditionFailed},
+		{Method: "PATCH", Path: "/messages/1", ContentType: "application/merge-patch+json",
+			Body: `{"mode": "strict"}`, ExpectedCode: http.StatusOK},
+		{Method: "PATCH", Path: "/messages/1", ContentType: "application/json",
+			Body: `{"mode": "strict"}`, ExpectedCode: http.StatusUnsupportedMediaType},
+		{Method: "PATCH", Path: "/messages/1", ContentType: "application/json-patch+json",
+			Body: `[{"op": "test", "path": "/content", "value": "level"}]`, ExpectedCode: http.StatusConflict},
+		{Method: "GET", Path: "/messages/1/revisions", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/messages/1/revisions/1", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/messages/1/revisions/9", ExpectedCode: http.StatusNotFound},
+		{Method: "GET", Path: "/messages/1/revisions/2/diff", ExpectedCode: http.StatusOK},
+		{Method: "POST", Path: "/messages/1/revisions/1/restore", ExpectedCode: http.StatusOK},
+		{Method: "POST", Path: "/messages:batch",
+			Body: `{"messages": [{"content": "noon"}, {"content": ""}]}`, ExpectedCode: http.StatusOK},
+		{Method: "PUT", Path: "/messages:batch",
+			Body: `{"messages": [{"id": "1", "content": "refer"}, {"id": "2", "content": "refer"}]}`, ExpectedCode: http.StatusOK},
+		{Method: "DELETE", Path: "/messages:batch", Body: `{"messages": [{"id": "2"}]}`, ExpectedCode: http.StatusOK},
+		{Method: "DELETE", Path: "/messages/1", ExpectedCode: http.StatusNoContent},
+		{Method: "GET", Path: "/trash", ExpectedCode: http.StatusOK},
+		{Method: "POST", Path: "/messages/1/restore", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/stats?bucket=hour", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/stats?bucket=week", ExpectedCode: http.StatusBadRequest},
+		{Method: "POST", Path: "/analyze", Body: `{"content": "kayak"}`, ExpectedCode: http.StatusOK},
+		{Method: "POST", Path: "/analyze", Body: `{}`, ExpectedCode: http.StatusUnprocessableEntity},
+		{Method: "POST", Path: "/analyze:batch", Body: `{"contents": ["kayak", "level"]}`, ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/openapi.json", ExpectedCode: http.StatusOK},
+		{Method: "GET", Path: "/docs", ExpectedCode: http.StatusOK},
+	}
+
+	covered := map[string]bool{}
+	for _, tc := range testCases {
+		t.Run(tc.Method+" "+tc.Path, func(t *testing.T) {
+			// Create a request with the request body
+			req := httptest.NewRequest(tc.Method, "/v1"+tc.Path, bytes.NewBufferString(tc.Body))
+			if tc.ContentType != "" {
+				req.Header.Set("Content-Type", tc.ContentType)
+			}
+			for name, value := range tc.Headers {
+				req.Header.Set(name, value)
+			}
+
+			// Create a response recorder to record the response
+			rr := httptest.NewRecorder()
+
+			// Call the router
+			runner.Router.ServeHTTP(rr, req)
+			require.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match: %s", rr.Body.String())
+			covered[tc.Method+" "+validateResponse(t, document, req, rr)] = true
+		})
+	}
+
+	// Check that every operation has been called
+	for path, item := range document.Paths {
+		for method := range item {
+			assert.True(t, covered[strings.ToUpper(method)+" "+path], "%s %s should be tested", method, path)
+		}
+	}
+}
+
+// schemaValidator validates JSON values against the schemas of a decoded OpenAPI document.
+type schemaValidator struct {
+	schemas map[string]map[string]any
+}
+
+// validate returns the violations of a schema by a value found at the given location.
+func (v schemaValidator) validate(schema map[string]any, value any, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return v.validate(v.schemas[strings.TrimPrefix(ref, "#/components/schemas/")], value, at)
+	}
+	if value == nil {
+		if schema["type"] == nil || schema["nullable"] == true {
+			return nil
+		}
+		return []string{at + " should not be null"}
+	}
+
+	var violations []string
+	violate := func(format string, args ...any) []string {
+		return append(violations, at+" "+fmt.Sprintf(format, args...))
+	}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return violate("should be an object")
+		}
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				violations = violate("should have %s", name)
+			}
+		}
+		properties, ok := schema["properties"].(map[string]any)
+		if !ok {
+			return violations
+		}
+		for name, field := range object {
+			property, ok := properties[name].(map[string]any)
+			if !ok {
+				violations = violate("should not have %s", name)
+				continue
+			}
+			violations = append(violations, v.validate(property, field, at+"."+name)...)
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return violate("should be an array")
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range array {
+			violations = append(violations, v.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return violate("should be a string")
+		}
+		if _, err := time.Parse(time.RFC3339Nano, text); schema["format"] == "date-time" && err != nil {
+			violations = violate("should be a date-time")
+		}
+		if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, any(text)) {
+			violations = violate("should be among %v", enum)
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+			return violate("should be an integer")
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return violate("should be a number")
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return violate("should be a boolean")
+		}
+	}
+	return violations
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/gharsallahmoez/palindrome/patch"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"mime"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// The media types of the patches of the messages.
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// acceptPatch lists the media types of the patches, as advertised by the Accept-Patch header.
+const acceptPatch = mergePatchContentType + ", " + jsonPatchContentType
+
+// MessageDocument is the patchable representation of a message, the target of the patches.
+type MessageDocument struct {
+	Content string `json:"content"`
+	Mode    string `json:"mode"`
+	// ExpiresAt is missing from the document of a message which does not expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// documentFields are the JSON names of the MessageDocument fields.
+var documentFields = jsonNames(reflect.TypeOf(MessageDocument{}))
+
+// patcher applies a patch to a decoded message document.
+type patcher func(document any) (any, error)
+
+// PatchMessageHandler handles HTTP requests to patch a message.
+func (s *MessageService) PatchMessageHandler(w http.ResponseWriter, r *http.Request) {
+	// Retrieve id from query.
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		writeError(w, r, errEmptyID)
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	apply, err := s.decodePatch(w, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	version, err := s.expectedVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// patch the message in the database.
+	savedMessage, err := s.database.PatchMessage(id, version, func(message model.Message) (model.Message, error) {
+		return s.patchedMessage(message, apply)
+	}, r.Context())
+	if err != nil {
+		writeError(w, r, preconditionError(err))
+		return
+	}
+	logrus.Infof("message with id %s patched successfully", savedMessage.ID)
+
+	// Build response JSON.
+	responseJSON, err := json.Marshal(fields.apply(versionOf(r).schema.message(savedMessage, nil)))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(savedMessage.Version))
+	_, _ = w.Write(responseJSON)
+}
+
+// decodePatch reads the patch of the body and returns its patcher.
+func (s *MessageService) decodePatch(w http.ResponseWriter, r *http.Request) (patcher, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != mergePatchContentType && mediaType != jsonPatchContentType) {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		return nil, model.ErrUnsupportedMediaType.Errorf("Content-Type should be %s or %s", mergePatchContentType, jsonPatchContentType)
+	}
+	content, err := s.readBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaType == mergePatchContentType {
+		var mergePatch any
+		if err := json.Unmarshal(content, &mergePatch); err != nil {
+			return nil, decodeError(err)
+		}
+		if _, ok := mergePatch.(map[string]any); !ok {
+			return nil, model.ErrInvalidPatch.Errorf("merge patch should be a JSON object")
+		}
+		return func(document any) (any, error) {
+			return patch.Merge(document, mergePatch), nil
+		}, nil
+	}
+
+	var operations []patch.Operation
+	if err := json.Unmarshal(content, &operations); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, model.ErrInvalidPatch.Errorf("JSON patch should be an array of operations")
+		}
+		return nil, decodeError(err)
+	}
+	return func(document any) (any, error) {
+		document, err := patch.Apply(document, operations)
+		switch {
+		case errors.Is(err, patch.ErrTestFailed):
+			return nil, model.ErrPatchTestFailed.Wrap(err)
+		case errors.Is(err, patch.ErrPathNotFound):
+			return nil, model.ErrPatchNotApplicable.Wrap(err)
+		case err != nil:
+			return nil, model.ErrInvalidPatch.Wrap(err)
+		}
+		return document, nil
+	}, nil
+}
+
+// patchedMessage returns the message patched by apply.
+func (s *MessageService) patchedMessage(message model.Message, apply patcher) (model.Message, error) {
+	content, err := json.Marshal(MessageDocument{Content: message.Content, Mode: string(message.Mode), ExpiresAt: message.ExpiresAt})
+	if err != nil {
+		return model.Message{}, err
+	}
+	var document any
+	if err := json.Unmarshal(content, &document); err != nil {
+		return model.Message{}, err
+	}
+	if document, err = apply(document); err != nil {
+		return model.Message{}, err
+	}
+	if _, ok := document.(map[string]any); !ok {
+		return model.Message{}, model.ErrPatchNotApplicable.Errorf("patched message should be a JSON object")
+	}
+	if content, err = json.Marshal(document); err != nil {
+		return model.Message{}, err
+	}
+
+	patched := MessageDocument{}
+	violations, err := decodeFields(content, documentFields, &patched)
+	if err != nil {
+		return model.Message{}, err
+	}
+	request := MessageRequest{Content: patched.Content, Mode: patched.Mode, ExpiresAt: patched.ExpiresAt}
+	violations = mergeViolations(violations, s.validateMessageRequest(request, time.Now()))
+	if len(violations) > 0 {
+		return model.Message{}, model.NewValidationError(violations)
+	}
+
+	mode, err := s.resolveMode(patched.Mode)
+	if err != nil {
+		return model.Message{}, err
+	}
+	if patched.Content != message.Content || mode != message.Mode {
+		analysis := palindrome.Analyze(patched.Content, mode)
+		message.Content = patched.Content
+		message.Mode = mode
+		message.IsPalindrome = analysis.IsPalindrome()
+		message.Analysis = analysis
+	}
+	message.ExpiresAt = patched.ExpiresAt
+	return message, nil
+}
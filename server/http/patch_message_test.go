@@ -0,0 +1,239 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPatchMessageHandler tests PatchMessageHandler function.
+func TestPatchMessageHandler(t *testing.T) {
+	// The stored message, whose analysis is recognizable to check when it is computed again
+	expiresAt := time.Now().Add(time.Hour).UTC()
+	stored := model.Message{
+		ID:           "1",
+		Content:      "kayak",
+		IsPalindrome: true,
+		Mode:         palindrome.Alphanumeric,
+		Analysis:     palindrome.Analysis{DistinctPalindromes: 42},
+		Version:      3,
+		ExpiresAt:    &expiresAt,
+	}
+
+	// Mock database patch function applying the patch to the stored message
+	dbMock := &DatabaseMock{
+		PatchMessageFunc: patchStoredMessage(stored),
+	}
+
+	service := svc.NewMessageService(dbMock, config.New())
+
+	// Define test cases
+	testCases := []struct {
+		Name                 string
+		ID                   string
+		ContentType          string
+		IfMatch              string
+		RequestBody          []byte
+		ExpectedCode         int
+		ExpectedMessage      string
+		ExpectedContent      string
+		ExpectedMode         string
+		ExpectedPalindromes  int
+		ExpectedExpiry       bool
+		ExpectedAcceptHeader bool
+	}{
+		{
+			Name:                "Merge patch of the content",
+			ID:                  "1",
+			ContentType:         "application/merge-patch+json",
+			RequestBody:         []byte(`{"content": "level"}`),
+			ExpectedCode:        http.StatusOK,
+			ExpectedContent:     "level",
+			ExpectedMode:        "alphanumeric",
+			ExpectedPalindromes: 5,
+			ExpectedExpiry:      true,
+		},
+		{
+			Name:                "Merge patch clearing the expiry",
+			ID:                  "1",
+			ContentType:         "application/merge-patch+json; charset=utf-8",
+			IfMatch:             `"3"`,
+			RequestBody:         []byte(`{"expires_at": null}`),
+			ExpectedCode:        http.StatusOK,
+			ExpectedContent:     "kayak",
+			ExpectedMode:        "alphanumeric",
+			ExpectedPalindromes: 42,
+		},
+		{
+			Name:                "JSON patch of the mode",
+			ID:                  "1",
+			ContentType:         "application/json-patch+json",
+			RequestBody:         []byte(`[{"op": "test", "path": "/content", "value": "kayak"}, {"op": "replace", "path": "/mode", "value": "strict"}]`),
+			ExpectedCode:        http.StatusOK,
+			ExpectedContent:     "kayak",
+			ExpectedMode:        "strict",
+			ExpectedPalindromes: 5,
+			ExpectedExpiry:      true,
+		},
+		{
+			Name:                "JSON patch removing the expiry",
+			ID:                  "1",
+			ContentType:         "application/json-patch+json",
+			RequestBody:         []byte(`[{"op": "remove", "path": "/expires_at"}]`),
+			ExpectedCode:        http.StatusOK,
+			ExpectedContent:     "kayak",
+			ExpectedMode:        "alphanumeric",
+			ExpectedPalindromes: 42,
+		},
+		{
+			Name:                 "Unsupported media type",
+			ID:                   "1",
+			ContentType:          "application/json",
+			RequestBody:          []byte(`{"content": "level"}`),
+			ExpectedCode:         http.StatusUnsupportedMediaType,
+			ExpectedMessage:      "Content-Type should be application/merge-patch+json or application/json-patch+json",
+			ExpectedAcceptHeader: true,
+		},
+		{
+			Name:            "Merge patch not an object",
+			ID:              "1",
+			ContentType:     "application/merge-patch+json",
+			RequestBody:     []byte(`["level"]`),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "merge patch should be a JSON object",
+		},
+		{
+			Name:            "JSON patch not an array",
+			ID:              "1",
+			ContentType:     "application/json-patch+json",
+			RequestBody:     []byte(`{"op": "remove", "path": "/mode"}`),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "JSON patch should be an array of operations",
+		},
+		{
+			Name:            "Unknown operation",
+			ID:              "1",
+			ContentType:     "application/json-patch+json",
+			RequestBody:     []byte(`[{"op": "merge", "path": "/mode"}]`),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: `operation 0: "merge" is an unknown operation`,
+		},
+		{
+			Name:            "Missing path",
+			ID:              "1",
+			ContentType:     "application/json-patch+json",
+			RequestBody:     []byte(`[{"op": "replace", "path": "/author", "value": "me"}]`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "operation 0: path /author does not exist",
+		},
+		{
+			Name:            "Failed test",
+			ID:              "1",
+			ContentType:     "application/json-patch+json",
+			RequestBody:     []byte(`[{"op": "test", "path": "/content", "value": "level"}]`),
+			ExpectedCode:    http.StatusConflict,
+			ExpectedMessage: "operation 0: value at /content differs",
+		},
+		{
+			Name:            "Replaced document",
+			ID:              "1",
+			ContentType:     "application/json-patch+json",
+			RequestBody:     []byte(`[{"op": "replace", "path": "", "value": "level"}]`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "patched message should be a JSON object",
+		},
+		{
+			Name:            "Invalid patched message",
+			ID:              "1",
+			ContentType:     "application/merge-patch+json",
+			RequestBody:     []byte(`{"content": null, "author": "me"}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "author is an unknown field; content cannot be empty",
+		},
+		{
+			Name:            "Invalid type",
+			ID:              "1",
+			ContentType:     "application/merge-patch+json",
+			RequestBody:     []byte(`{"mode": 42}`),
+			ExpectedCode:    http.StatusUnprocessableEntity,
+			ExpectedMessage: "mode has an invalid type",
+		},
+		{
+			Name:            "Invalid JSON",
+			ID:              "1",
+			ContentType:     "application/merge-patch+json",
+			RequestBody:     []byte(`{"content": "level",`),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "request body should be valid JSON",
+		},
+		{
+			Name:            "Stale version",
+			ID:              "1",
+			ContentType:     "application/merge-patch+json",
+			IfMatch:         `"2"`,
+			RequestBody:     []byte(`{"content": "level"}`),
+			ExpectedCode:    http.StatusPreconditionFailed,
+			ExpectedMessage: "message has been modified",
+		},
+		{
+			Name:            "Non-exist message",
+			ID:              "2",
+			ContentType:     "application/merge-patch+json",
+			RequestBody:     []byte(`{"content": "level"}`),
+			ExpectedCode:    http.StatusNotFound,
+			ExpectedMessage: "message not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a request with the request body
+			req, err := http.NewRequest("PATCH", "/messages/"+tc.ID, bytes.NewBuffer(tc.RequestBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tc.ContentType)
+			if tc.IfMatch != "" {
+				req.Header.Set("If-Match", tc.IfMatch)
+			}
+
+			// Set the request variables
+			req = mux.SetURLVars(req, map[string]string{"id": tc.ID})
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the PatchMessageHandler method
+			handler := http.HandlerFunc(service.PatchMessageHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			assert.Equal(t, tc.ExpectedAcceptHeader, rr.Header().Get("Accept-Patch") != "", "Accept-Patch header should match")
+			if tc.ExpectedCode != http.StatusOK {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
+				return
+			}
+
+			// Check the patched message
+			assert.Equal(t, `"4"`, rr.Header().Get("ETag"), "ETag should match")
+			var response svc.MessageResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.ExpectedContent, response.Content, "Content should match")
+			assert.Equal(t, tc.ExpectedMode, response.Mode, "Mode should match")
+			assert.Equal(t, tc.ExpectedPalindromes, response.Analysis.DistinctPalindromes, "Analysis should only be computed again on change")
+			assert.Equal(t, tc.ExpectedExpiry, response.ExpiresAt != nil, "Expiry should match")
+		})
+	}
+}
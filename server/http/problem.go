@@ -0,0 +1,122 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// problemContentType is the media type of the RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the codes of the errors to build the problem types.
+const problemTypePrefix = "urn:palindrome:problem:"
+
+// kindStatuses are the HTTP statuses of the kinds of errors.
+var kindStatuses = map[model.Kind]int{
+	model.KindInternal:             http.StatusInternalServerError,
+	model.KindInvalid:              http.StatusBadRequest,
+	model.KindNotFound:             http.StatusNotFound,
+	model.KindConflict:             http.StatusConflict,
+	model.KindPreconditionRequired: http.StatusPreconditionRequired,
+	model.KindPreconditionFailed:   http.StatusPreconditionFailed,
+	model.KindUnprocessable:        http.StatusUnprocessableEntity,
+	model.KindMethodNotAllowed:     http.StatusMethodNotAllowed,
+	model.KindTooLarge:             http.StatusRequestEntityTooLarge,
+	model.KindUnsupportedMediaType: http.StatusUnsupportedMediaType,
+}
+
+// Problem is an error response, as RFC 7807 problem details.
+type Problem struct {
+	// Type identifies the problem, it is a URN ending with the code.
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Detail describes this occurrence of the problem.
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+	// Code is the stable machine-readable code of the problem.
+	Code string `json:"code"`
+	// Errors are the violations of a validation problem.
+	Errors []ViolationResponse `json:"errors,omitempty"`
+}
+
+// ViolationResponse is a field of a request breaking a validation rule.
+type ViolationResponse struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// newProblem returns the problem of an error of the catalogue.
+func newProblem(err *model.Error) Problem {
+	status := kindStatuses[err.Kind]
+	problem := Problem{
+		Type:   problemTypePrefix + err.Code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Message,
+		Code:   err.Code,
+	}
+	for _, violation := range err.Violations {
+		problem.Errors = append(problem.Errors, ViolationResponse{Field: violation.Field, Code: violation.Code, Detail: violation.Message})
+	}
+	return problem
+}
+
+// writeError writes an error as a problem.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := newProblem(catalogued(err))
+	problem.Instance = r.URL.Path
+	writeProblem(w, problem)
+}
+
+// writeProblem writes a problem with its status.
+func writeProblem(w http.ResponseWriter, problem Problem) {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		logrus.Errorf(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_, _ = w.Write(body)
+}
+
+// catalogued returns the error of the catalogue matching err, logging the unexpected failures.
+func catalogued(err error) *model.Error {
+	var modelErr *model.Error
+	if errors.As(err, &modelErr) && modelErr.Kind != model.KindInternal {
+		return modelErr
+	}
+	logrus.Errorf(err.Error())
+	return model.ErrInternal
+}
+
+// invalid returns a validation error of a request.
+func invalid(err error) error {
+	return model.ErrInvalidRequest.Wrap(err)
+}
+
+// decodeError returns the error of a request body which cannot be decoded.
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return model.ErrMalformedBody.Errorf("%s has an invalid type", typeErr.Field)
+	}
+	return model.ErrMalformedBody
+}
+
+// notFoundHandler writes the problem of the requests to unknown routes.
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, model.ErrRouteNotFound)
+}
+
+// methodNotAllowedHandler writes the problem of the requests with a method unsupported by their route.
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, model.ErrMethodNotAllowed)
+}
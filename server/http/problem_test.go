@@ -0,0 +1,107 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertProblem checks that the response is a problem with the status and detail.
+func assertProblem(t *testing.T, rr *httptest.ResponseRecorder, status int, detail string) {
+	t.Helper()
+	assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"), "Content type should match")
+	var problem svc.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, status, problem.Status, "Problem status should match")
+	assert.Equal(t, detail, problem.Detail, "Problem detail should match")
+}
+
+// TestProblem tests the problems written by the handlers for the errors of the catalogue and the others.
+func TestProblem(t *testing.T) {
+	testCases := []struct {
+		Name            string
+		Err             error
+		ExpectedProblem svc.Problem
+	}{
+		{
+			Name: "Catalogued error",
+			Err:  model.ErrMessageNotFound,
+			ExpectedProblem: svc.Problem{
+				Type:     "urn:palindrome:problem:message_not_found",
+				Title:    "Not Found",
+				Status:   http.StatusNotFound,
+				Detail:   "message not found",
+				Instance: "/messages/1",
+				Code:     "message_not_found",
+			},
+		},
+		{
+			Name: "Wrapped catalogued error",
+			Err:  errors.Join(errors.New("no rows"), model.ErrMessageNotFound),
+			ExpectedProblem: svc.Problem{
+				Type:     "urn:palindrome:problem:message_not_found",
+				Title:    "Not Found",
+				Status:   http.StatusNotFound,
+				Detail:   "message not found",
+				Instance: "/messages/1",
+				Code:     "message_not_found",
+			},
+		},
+		{
+			Name: "Storage failure",
+			Err:  errors.New("dial tcp 10.0.0.1:5432: connection refused"),
+			ExpectedProblem: svc.Problem{
+				Type:     "urn:palindrome:problem:internal",
+				Title:    "Internal Server Error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "internal error",
+				Instance: "/messages/1",
+				Code:     "internal",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Mock database get function failing with the error
+			dbMock := &DatabaseMock{
+				GetMessageFunc: func(id string, ctx context.Context) (model.Message, error) {
+					return model.Message{}, tc.Err
+				},
+			}
+			service := svc.NewMessageService(dbMock, config.New())
+
+			req, err := http.NewRequest("GET", "/messages/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the GetMessageHandler method
+			handler := http.HandlerFunc(service.GetMessageHandler)
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.ExpectedProblem.Status, rr.Code, "Status code should match")
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"), "Content type should match")
+			var problem svc.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.ExpectedProblem, problem, "Problem should match")
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"github.com/gharsallahmoez/palindrome/diff"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RevisionResponse struct {
+	Number       int64     `json:"number"`
+	Content      string    `json:"content"`
+	IsPalindrome bool      `json:"is_palindrome"`
+	Mode         string    `json:"mode"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type RevisionDiffResponse struct {
+	From  int64          `json:"from"`
+	To    int64          `json:"to"`
+	Edits []EditResponse `json:"edits"`
+}
+
+type EditResponse struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// ListRevisionsHandler handles HTTP requests to list the revisions of a message.
+func (s *MessageService) ListRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	revisions, err := s.database.ListRevisions(mux.Vars(r)["id"], r.Context())
+	if err != nil {
+		writeError(w, r, preconditionError(err))
+		return
+	}
+	response := make([]any, len(revisions))
+	for index := range revisions {
+		response[index] = versionOf(r).schema.revision(revisions[index])
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetRevisionHandler handles HTTP requests to retrieve a revision of a message.
+func (s *MessageService) GetRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	number, ok := revisionNumber(w, r, mux.Vars(r)["n"])
+	if !ok {
+		return
+	}
+	revision, err := s.database.GetRevision(mux.Vars(r)["id"], number, r.Context())
+	if err != nil {
+		writeError(w, r, preconditionError(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, versionOf(r).schema.revision(revision))
+}
+
+// DiffRevisionHandler handles HTTP requests to compare two revisions of a message.
+func (s *MessageService) DiffRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	number, ok := revisionNumber(w, r, mux.Vars(r)["n"])
+	if !ok {
+		return
+	}
+	from := number - 1
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, ok = revisionNumber(w, r, value); !ok {
+			return
+		}
+	}
+
+	revision, err := s.database.GetRevision(id, number, r.Context())
+	if err != nil {
+		writeError(w, r, preconditionError(err))
+		return
+	}
+	// the first revision is compared with an empty content
+	var previous model.Revision
+	if from > 0 {
+		if previous, err = s.database.GetRevision(id, from, r.Context()); err != nil {
+			writeError(w, r, preconditionError(err))
+			return
+		}
+	}
+
+	response := RevisionDiffResponse{From: from, To: number, Edits: []EditResponse{}}
+	for _, edit := range diff.Runes(previous.Content, revision.Content) {
+		response.Edits = append(response.Edits, EditResponse{Op: string(edit.Op), Text: edit.Text})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// RestoreRevisionHandler handles HTTP requests to restore a revision of a message.
+func (s *MessageService) RestoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	number, ok := revisionNumber(w, r, mux.Vars(r)["n"])
+	if !ok {
+		return
+	}
+	version, err := s.expectedVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	revision, err := s.database.GetRevision(id, number, r.Context())
+	if err != nil {
+		writeError(w, r, preconditionError(err))
+		return
+	}
+	analysis := palindrome.Analyze(revision.Content, revision.Mode)
+	message := model.Message{
+		ID:           id,
+		Content:      revision.Content,
+		IsPalindrome: analysis.IsPalindrome(),
+		Mode:         revision.Mode,
+		Analysis:     analysis,
+		Version:      version,
+	}
+	savedMessage, err := s.database.UpdateMessage(message, r.Context())
+	if err != nil {
+		writeError(w, r, preconditionError(err))
+		return
+	}
+	logrus.Infof("message with id %s restored to revision %d", savedMessage.ID, number)
+
+	w.Header().Set("ETag", etag(savedMessage.Version))
+	writeJSON(w, http.StatusOK, versionOf(r).schema.message(savedMessage, nil))
+}
+
+// revisionNumber parses a revision number, writing a bad request error if it is invalid.
+func revisionNumber(w http.ResponseWriter, r *http.Request, value string) (int64, bool) {
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 1 {
+		writeError(w, r, model.ErrInvalidRequest.Errorf("revision number should be a positive integer"))
+		return 0, false
+	}
+	return number, true
+}
+
+// mapRevisionToSchema maps a revision model to a http schema.
+func mapRevisionToSchema(revision model.Revision) RevisionResponse {
+	return RevisionResponse{
+		Number:       revision.Number,
+		Content:      revision.Content,
+		IsPalindrome: revision.IsPalindrome,
+		Mode:         string(revision.Mode),
+		CreatedAt:    revision.CreatedAt,
+	}
+}
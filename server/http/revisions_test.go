@@ -0,0 +1,223 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// revisionsMock mocks a message with id 1 and three revisions.
+func revisionsMock() *DatabaseMock {
+	createdAt := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	revisions := []model.Revision{
+		{MessageID: "1", Number: 1, Content: "kayak", IsPalindrome: true, Mode: palindrome.Alphanumeric, CreatedAt: createdAt},
+		{MessageID: "1", Number: 2, Content: "kayaks", IsPalindrome: false, Mode: palindrome.Alphanumeric, CreatedAt: createdAt.Add(time.Minute)},
+		{MessageID: "1", Number: 3, Content: "level", IsPalindrome: true, Mode: palindrome.Strict, CreatedAt: createdAt.Add(time.Hour)},
+	}
+	return &DatabaseMock{
+		ListRevisionsFunc: func(id string, ctx context.Context) ([]model.Revision, error) {
+			if id != "1" {
+				return nil, model.ErrMessageNotFound
+			}
+			return revisions, nil
+		},
+		GetRevisionFunc: func(id string, number int64, ctx context.Context) (model.Revision, error) {
+			switch {
+			case id != "1":
+				return model.Revision{}, model.ErrMessageNotFound
+			case number > int64(len(revisions)):
+				return model.Revision{}, model.ErrRevisionNotFound
+			}
+			return revisions[number-1], nil
+		},
+		UpdateMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
+			if message.Version != 0 && message.Version != 3 {
+				return model.Message{}, model.ErrVersionMismatch
+			}
+			message.Version = 4
+			return message, nil
+		},
+	}
+}
+
+// serveRevision serves a revision request with the given route variables.
+func serveRevision(t *testing.T, handler http.HandlerFunc, method, target string, vars map[string]string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req = mux.SetURLVars(req, vars)
+	rr := newRecorder(t, req)
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// TestListRevisionsHandler tests ListRevisionsHandler function.
+func TestListRevisionsHandler(t *testing.T) {
+	service := svc.NewMessageService(revisionsMock(), config.New())
+
+	t.Run("existing message", func(t *testing.T) {
+		rr := serveRevision(t, service.ListRevisionsHandler, "GET", "/messages/1/revisions", map[string]string{"id": "1"}, nil)
+		assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+		var response []svc.RevisionResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		require.Len(t, response, 3)
+		assert.Equal(t, svc.RevisionResponse{
+			Number:       1,
+			Content:      "kayak",
+			IsPalindrome: true,
+			Mode:         "alphanumeric",
+			CreatedAt:    time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		}, response[0])
+	})
+
+	t.Run("non-exist message", func(t *testing.T) {
+		rr := serveRevision(t, service.ListRevisionsHandler, "GET", "/messages/2/revisions", map[string]string{"id": "2"}, nil)
+		assert.Equal(t, http.StatusNotFound, rr.Code, "Status code should match")
+	})
+
+	t.Run("with failed db operation", func(t *testing.T) {
+		dbMock := &DatabaseMock{
+			ListRevisionsFunc: func(id string, ctx context.Context) ([]model.Revision, error) {
+				return nil, errors.New("some error")
+			},
+		}
+		service := svc.NewMessageService(dbMock, config.New())
+		rr := serveRevision(t, service.ListRevisionsHandler, "GET", "/messages/1/revisions", map[string]string{"id": "1"}, nil)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
+	})
+}
+
+// TestGetRevisionHandler tests GetRevisionHandler function.
+func TestGetRevisionHandler(t *testing.T) {
+	service := svc.NewMessageService(revisionsMock(), config.New())
+
+	testCases := []struct {
+		Name            string
+		ID              string
+		Number          string
+		ExpectedCode    int
+		ExpectedContent string
+	}{
+		{Name: "Existing revision", ID: "1", Number: "2", ExpectedCode: http.StatusOK, ExpectedContent: "kayaks"},
+		{Name: "Non-exist revision", ID: "1", Number: "4", ExpectedCode: http.StatusNotFound},
+		{Name: "Non-exist message", ID: "2", Number: "1", ExpectedCode: http.StatusNotFound},
+		{Name: "Invalid number", ID: "1", Number: "first", ExpectedCode: http.StatusBadRequest},
+		{Name: "Zero number", ID: "1", Number: "0", ExpectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			rr := serveRevision(t, service.GetRevisionHandler, "GET", "/messages/"+tc.ID+"/revisions/"+tc.Number,
+				map[string]string{"id": tc.ID, "n": tc.Number}, nil)
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedCode == http.StatusOK {
+				var response svc.RevisionResponse
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, tc.ExpectedContent, response.Content, "Content should match")
+			}
+		})
+	}
+}
+
+// TestDiffRevisionHandler tests DiffRevisionHandler function.
+func TestDiffRevisionHandler(t *testing.T) {
+	service := svc.NewMessageService(revisionsMock(), config.New())
+
+	testCases := []struct {
+		Name         string
+		Target       string
+		Number       string
+		ExpectedCode int
+		ExpectedBody svc.RevisionDiffResponse
+	}{
+		{
+			Name:         "With previous revision",
+			Target:       "/messages/1/revisions/2/diff",
+			Number:       "2",
+			ExpectedCode: http.StatusOK,
+			ExpectedBody: svc.RevisionDiffResponse{From: 1, To: 2, Edits: []svc.EditResponse{
+				{Op: "equal", Text: "kayak"}, {Op: "insert", Text: "s"},
+			}},
+		},
+		{
+			Name:         "First revision",
+			Target:       "/messages/1/revisions/1/diff",
+			Number:       "1",
+			ExpectedCode: http.StatusOK,
+			ExpectedBody: svc.RevisionDiffResponse{From: 0, To: 1, Edits: []svc.EditResponse{{Op: "insert", Text: "kayak"}}},
+		},
+		{
+			Name:         "From a given revision",
+			Target:       "/messages/1/revisions/1/diff?from=3",
+			Number:       "1",
+			ExpectedCode: http.StatusOK,
+			ExpectedBody: svc.RevisionDiffResponse{From: 3, To: 1, Edits: []svc.EditResponse{
+				{Op: "delete", Text: "level"}, {Op: "insert", Text: "kayak"},
+			}},
+		},
+		{Name: "Non-exist revision", Target: "/messages/1/revisions/5/diff", Number: "5", ExpectedCode: http.StatusNotFound},
+		{Name: "Invalid from", Target: "/messages/1/revisions/2/diff?from=zero", Number: "2", ExpectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			rr := serveRevision(t, service.DiffRevisionHandler, "GET", tc.Target, map[string]string{"id": "1", "n": tc.Number}, nil)
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedCode == http.StatusOK {
+				var response svc.RevisionDiffResponse
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, tc.ExpectedBody, response, "Response body should match")
+			}
+		})
+	}
+}
+
+// TestRestoreRevisionHandler tests RestoreRevisionHandler function.
+func TestRestoreRevisionHandler(t *testing.T) {
+	service := svc.NewMessageService(revisionsMock(), config.New())
+
+	testCases := []struct {
+		Name         string
+		Number       string
+		IfMatch      string
+		ExpectedCode int
+	}{
+		{Name: "Valid request", Number: "1", ExpectedCode: http.StatusOK},
+		{Name: "Matching ETag", Number: "1", IfMatch: `"3"`, ExpectedCode: http.StatusOK},
+		{Name: "Stale ETag", Number: "1", IfMatch: `"2"`, ExpectedCode: http.StatusPreconditionFailed},
+		{Name: "Non-exist revision", Number: "7", ExpectedCode: http.StatusNotFound},
+		{Name: "Invalid number", Number: "-1", ExpectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.IfMatch != "" {
+				header.Set("If-Match", tc.IfMatch)
+			}
+			rr := serveRevision(t, service.RestoreRevisionHandler, "POST", "/messages/1/revisions/"+tc.Number+"/restore",
+				map[string]string{"id": "1", "n": tc.Number}, header)
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedCode == http.StatusOK {
+				var response svc.MessageResponse
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, "kayak", response.Content, "Content should be restored")
+				assert.True(t, response.IsPalindrome, "Palindrome result should be computed again")
+				assert.Equal(t, `"4"`, rr.Header().Get("ETag"), "ETag should be the new version")
+			}
+		})
+	}
+}
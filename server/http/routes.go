@@ -0,0 +1,311 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"net/http"
+)
+
+// route is an API of the message service.
+type route struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+	doc     operation
+	// streaming tells whether the route transfers its body progressively, so that it is not bounded by the server
+	// timeout, which would buffer its response and interrupt the long transfers.
+	streaming bool
+}
+
+// The parameters shared by several routes.
+var (
+	idParameter       = Parameter{Name: "id", In: "path", Required: true, Description: "The ID of the message.", Schema: &Schema{Type: "string"}}
+	revisionParameter = Parameter{Name: "n", In: "path", Required: true, Description: "The number of the revision, the version of the message it records.",
+		Schema: &Schema{Type: "integer", Format: "int64"}}
+	fieldsParameter = Parameter{Name: "fields", In: "query", Description: "The comma-separated fields of the messages to return, the id being always returned.",
+		Schema: &Schema{Type: "string"}}
+	ifMatchParameter = Parameter{Name: "If-Match", In: "header", Description: "Only writes the message at the version of this entity tag, or at any version with *.",
+		Schema: &Schema{Type: "string"}}
+	formatParameter = Parameter{Name: "format", In: "query", Description: "The format of the messages, ndjson by default.",
+		Schema: &Schema{Type: "string", Enum: []string{formatNDJSON, formatCSV}}}
+	limitParameter = func(max int) Parameter {
+		return Parameter{Name: "limit", In: "query", Description: fmt.Sprintf("The maximum number of results, up to %d.", max), Schema: &Schema{Type: "integer"}}
+	}
+	timeParameter = func(name, description string) Parameter {
+		return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: "string", Format: "date-time"}}
+	}
+	listParameters = []Parameter{
+		limitParameter(maxListLimit),
+		{Name: "cursor", In: "query", Description: "The cursor of the page, as returned by the X-Next-Cursor header.", Schema: &Schema{Type: "string"}},
+		{Name: "contains", In: "query", Description: "Only lists the messages whose content contains this text.", Schema: &Schema{Type: "string"}},
+		{Name: "is_palindrome", In: "query", Description: "Only lists the palindromes, or the other messages.", Schema: &Schema{Type: "boolean"}},
+		timeParameter("created_after", "Only lists the messages created after this time."),
+		timeParameter("created_before", "Only lists the messages created before this time."),
+		timeParameter("updated_after", "Only lists the messages updated after this time."),
+		timeParameter("updated_before", "Only lists the messages updated before this time."),
+		{Name: "order", In: "query", Description: "The order of the sort.", Schema: &Schema{Type: "string", Enum: []string{string(model.Ascending), string(model.Descending)}}},
+		fieldsParameter,
+	}
+)
+
+// messageContent is the JSON body holding a message.
+var messageContent = jsonContent(MessageResponse{})
+
+// messageResponse returns the response holding a message with its ETag.
+func messageResponse(status int, description string) response {
+	return response{status: status, description: description, headers: []string{"ETag"}, content: messageContent}
+}
+
+// v1Routes returns the routes of the version 1 of the API.
+func (s *MessageService) v1Routes() []route {
+	modes := make([]string, len(palindrome.Modes))
+	for i, mode := range palindrome.Modes {
+		modes[i] = string(mode)
+	}
+	sortParameter := func(fields ...model.SortField) Parameter {
+		values := make([]string, len(fields))
+		for i, field := range fields {
+			values[i] = string(field)
+		}
+		return Parameter{Name: "sort", In: "query", Description: "The timestamp sorting the messages.", Schema: &Schema{Type: "string", Enum: values}}
+	}
+
+	return []route{
+		// message APIs
+		{method: http.MethodPost, path: "/messages", handler: s.CreateMessageHandler, doc: operation{
+			id: "createMessage", summary: "Creates a new message",
+			parameters: []Parameter{
+				{Name: "Idempotency-Key", In: "header", Description: "Replays the response of the first request with this key.", Schema: &Schema{Type: "string"}},
+				fieldsParameter,
+			},
+			request: jsonContent(MessageRequest{}),
+			responses: []response{
+				{status: http.StatusCreated, description: "The created message.", headers: []string{"ETag", "Idempotent-Replayed"}, content: messageContent},
+				{status: http.StatusOK, description: "The stored message with the same content, with the return deduplication.", headers: []string{"ETag", "Content-Location"}, content: messageContent},
+			},
+			problems: []int{http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+		}},
+		{method: http.MethodGet, path: "/messages", handler: s.ListMessageHandler, doc: operation{
+			id: "listMessages", summary: "Retrieves a page of the messages",
+			parameters: append([]Parameter{sortParameter(model.SortByCreatedAt, model.SortByUpdatedAt)}, listParameters...),
+			responses: []response{
+				{status: http.StatusOK, description: "The messages of the page.", headers: []string{nextCursorHeader}, content: jsonContent([]MessageResponse{})},
+			},
+			problems: []int{http.StatusBadRequest},
+		}},
+		// registered before /messages/{id}, which would match them
+		{method: http.MethodGet, path: "/messages/search", handler: s.SearchMessagesHandler, doc: operation{
+			id: "searchMessages", summary: "Searches the message contents, most relevant first",
+			parameters: []Parameter{
+				{Name: "q", In: "query", Required: true, Description: "The words to search, a quoted phrase matching as a whole.", Schema: &Schema{Type: "string"}},
+				limitParameter(maxSearchLimit),
+				{Name: "offset", In: "query", Description: "The number of results to skip.", Schema: &Schema{Type: "integer"}},
+			},
+			responses: []response{
+				{status: http.StatusOK, description: "The matching messages.", content: jsonContent([]SearchHitResponse{})},
+			},
+			problems: []int{http.StatusBadRequest},
+		}},
+		{method: http.MethodGet, path: "/messages/export", handler: s.ExportMessagesHandler, doc: operation{
+			id: "exportMessages", summary: "Exports the messages out of the trash, oldest first",
+			parameters: []Parameter{formatParameter},
+			responses: []response{
+				{status: http.StatusOK, description: "The messages, one NDJSON record or CSV row by message.", headers: []string{"Content-Disposition"}, content: []content{
+					{mediaType: "application/x-ndjson", value: ExportRecord{}},
+					{mediaType: "text/csv"},
+				}},
+			},
+			problems: []int{http.StatusBadRequest},
+		}, streaming: true},
+		{method: http.MethodPost, path: "/messages/import", handler: s.ImportMessagesHandler, doc: operation{
+			id: "importMessages", summary: "Imports messages, reporting the invalid records by line",
+			parameters: []Parameter{formatParameter},
+			request: []content{
+				{mediaType: "application/x-ndjson", value: ExportRecord{}},
+				{mediaType: "text/csv"},
+			},
+			responses: []response{
+				{status: http.StatusOK, description: "The outcome of the import.", content: jsonContent(ImportResponse{})},
+			},
+			problems: []int{http.StatusBadRequest},
+		}, streaming: true},
+		{method: http.MethodGet, path: "/messages/{id}", handler: s.GetMessageHandler, doc: operation{
+			id: "getMessage", summary: "Retrieves a specific message",
+			parameters: []Parameter{
+				idParameter,
+				{Name: "near_palindrome", In: "query", Description: "Adds the cheapest edits turning the content into a palindrome.", Schema: &Schema{Type: "boolean"}},
+				fieldsParameter,
+			},
+			responses: []response{messageResponse(http.StatusOK, "The message.")},
+			problems:  []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+		}},
+		{method: http.MethodPut, path: "/messages/{id}", handler: s.UpdateMessageHandler, doc: operation{
+			id: "updateMessage", summary: "Updates a specific message",
+			parameters: []Parameter{idParameter, ifMatchParameter, fieldsParameter},
+			request:    jsonContent(MessageRequest{}),
+			responses:  []response{messageResponse(http.StatusOK, "The updated message.")},
+			problems: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusRequestEntityTooLarge,
+				http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+		}},
+		{method: http.MethodPatch, path: "/messages/{id}", handler: s.PatchMessageHandler, doc: operation{
+			id: "patchMessage", summary: "Patches a specific message with a JSON Merge Patch or a JSON Patch",
+			parameters: []Parameter{idParameter, ifMatchParameter, fieldsParameter},
+			request: []content{
+				{mediaType: mergePatchContentType, value: MessageDocument{}},
+				{mediaType: jsonPatchContentType, schema: &Schema{Type: "array", Items: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"op":    {Type: "string", Enum: []string{"add", "remove", "replace", "move", "copy", "test"}},
+						"path":  {Type: "string"},
+						"from":  {Type: "string"},
+						"value": {},
+					},
+					Required: []string{"op", "path"},
+				}}},
+			},
+			responses: []response{messageResponse(http.StatusOK, "The patched message.")},
+			problems: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed,
+				http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+		}},
+		{method: http.MethodDelete, path: "/messages/{id}", handler: s.DeleteMessageHandler, doc: operation{
+			id: "deleteMessage", summary: "Moves a specific message to the trash",
+			parameters: []Parameter{idParameter, ifMatchParameter},
+			responses:  []response{{status: http.StatusNoContent, description: "The message is in the trash."}},
+			problems:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+		}},
+		{method: http.MethodPost, path: "/messages:batch", handler: s.CreateMessagesBatchHandler, doc: operation{
+			id: "createMessages", summary: "Creates several messages, each item failing on its own",
+			request: jsonContent(BatchRequest[MessageRequest]{}),
+			responses: []response{
+				{status: http.StatusOK, description: "The outcome of every item, in order.", content: jsonContent([]BatchItemResponse{})},
+			},
+			problems: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+		}},
+		{method: http.MethodPut, path: "/messages:batch", handler: s.UpdateMessagesBatchHandler, doc: operation{
+			id: "updateMessages", summary: "Updates several messages, each item failing on its own",
+			request: jsonContent(BatchRequest[BatchUpdateItem]{}),
+			responses: []response{
+				{status: http.StatusOK, description: "The outcome of every item, in order.", content: jsonContent([]BatchItemResponse{})},
+			},
+			problems: []int{http.StatusBadRequest, http.StatusPreconditionRequired, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+		}},
+		{method: http.MethodDelete, path: "/messages:batch", handler: s.DeleteMessagesBatchHandler, doc: operation{
+			id: "deleteMessages", summary: "Moves several messages to the trash, each item failing on its own",
+			request: jsonContent(BatchRequest[BatchDeleteItem]{}),
+			responses: []response{
+				{status: http.StatusOK, description: "The outcome of every item, in order.", content: jsonContent([]BatchItemResponse{})},
+			},
+			problems: []int{http.StatusBadRequest, http.StatusPreconditionRequired, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+		}},
+
+		// trash APIs
+		{method: http.MethodGet, path: "/trash", handler: s.TrashHandler, doc: operation{
+			id: "listTrash", summary: "Retrieves a page of the messages in the trash, most recently deleted first",
+			parameters: append([]Parameter{sortParameter(model.SortByCreatedAt, model.SortByUpdatedAt, model.SortByDeletedAt)}, listParameters...),
+			responses: []response{
+				{status: http.StatusOK, description: "The messages of the page.", headers: []string{nextCursorHeader}, content: jsonContent([]MessageResponse{})},
+			},
+			problems: []int{http.StatusBadRequest},
+		}},
+		{method: http.MethodPost, path: "/messages/{id}/restore", handler: s.RestoreMessageHandler, doc: operation{
+			id: "restoreMessage", summary: "Moves a message out of the trash",
+			parameters: []Parameter{idParameter},
+			responses:  []response{messageResponse(http.StatusOK, "The restored message.")},
+			problems:   []int{http.StatusBadRequest, http.StatusNotFound},
+		}},
+
+		// revision APIs
+		{method: http.MethodGet, path: "/messages/{id}/revisions", handler: s.ListRevisionsHandler, doc: operation{
+			id: "listRevisions", summary: "Retrieves the revisions of a message, oldest first",
+			parameters: []Parameter{idParameter},
+			responses: []response{
+				{status: http.StatusOK, description: "The revisions.", content: jsonContent([]RevisionResponse{})},
+			},
+			problems: []int{http.StatusNotFound},
+		}},
+		{method: http.MethodGet, path: "/messages/{id}/revisions/{n}", handler: s.GetRevisionHandler, doc: operation{
+			id: "getRevision", summary: "Retrieves a revision of a message",
+			parameters: []Parameter{idParameter, revisionParameter},
+			responses: []response{
+				{status: http.StatusOK, description: "The revision.", content: jsonContent(RevisionResponse{})},
+			},
+			problems: []int{http.StatusBadRequest, http.StatusNotFound},
+		}},
+		{method: http.MethodGet, path: "/messages/{id}/revisions/{n}/diff", handler: s.DiffRevisionHandler, doc: operation{
+			id: "diffRevision", summary: "Compares a revision of a message with a previous one",
+			parameters: []Parameter{
+				idParameter,
+				revisionParameter,
+				{Name: "from", In: "query", Description: "The number of the previous revision, the one right before by default.",
+					Schema: &Schema{Type: "integer", Format: "int64"}},
+			},
+			responses: []response{
+				{status: http.StatusOK, description: "The edits between the revisions.", content: jsonContent(RevisionDiffResponse{})},
+			},
+			problems: []int{http.StatusBadRequest, http.StatusNotFound},
+		}},
+		{method: http.MethodPost, path: "/messages/{id}/revisions/{n}/restore", handler: s.RestoreRevisionHandler, doc: operation{
+			id: "restoreRevision", summary: "Updates a message with the content and mode of a revision",
+			parameters: []Parameter{idParameter, revisionParameter, ifMatchParameter},
+			responses:  []response{messageResponse(http.StatusOK, "The restored message.")},
+			problems:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+		}},
+
+		// statistics API
+		{method: http.MethodGet, path: "/stats", handler: s.StatsHandler, doc: operation{
+			id: "getStats", summary: "Aggregates the messages for the dashboards",
+			parameters: []Parameter{
+				timeParameter("from", "Only aggregates the messages created from this time."),
+				timeParameter("to", "Only aggregates the messages created before this time."),
+				{Name: "bucket", In: "query", Description: "The size of the buckets of the creations, day by default.",
+					Schema: &Schema{Type: "string", Enum: []string{string(model.BucketHour), string(model.BucketDay)}}},
+				{Name: "top", In: "query", Description: fmt.Sprintf("The number of most frequent palindromes, up to %d.", maxStatsTop),
+					Schema: &Schema{Type: "integer"}},
+			},
+			responses: []response{
+				{status: http.StatusOK, description: "The aggregates.", content: jsonContent(StatsResponse{})},
+			},
+			problems: []int{http.StatusBadRequest},
+		}},
+
+		// analysis APIs
+		{method: http.MethodPost, path: "/analyze", handler: s.AnalyzeHandler, doc: operation{
+			id: "analyze", summary: "Analyzes a content without storing it",
+			request: []content{{mediaType: "application/json", schema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"content": {Type: "string"},
+					"mode":    {Type: "string", Enum: modes},
+				},
+				Required: []string{"content"},
+			}}},
+			responses: []response{
+				{status: http.StatusOK, description: "The analysis.", content: jsonContent(AnalyzeResponse{})},
+			},
+			problems: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+		}},
+		{method: http.MethodPost, path: "/analyze:batch", handler: s.AnalyzeBatchHandler, doc: operation{
+			id: "analyzeBatch", summary: "Analyzes several contents without storing them",
+			request: jsonContent(AnalyzeBatchRequest{}),
+			responses: []response{
+				{status: http.StatusOK, description: "The analyses, in the order of the contents.", content: jsonContent([]AnalyzeResponse{})},
+			},
+			problems: []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+		}},
+
+		// documentation APIs
+		{method: http.MethodGet, path: "/openapi.json", handler: s.OpenAPIHandler, doc: operation{
+			id: "getOpenAPI", summary: "Retrieves this OpenAPI document",
+			responses: []response{
+				{status: http.StatusOK, description: "The OpenAPI document.", content: []content{{mediaType: "application/json", schema: &Schema{Type: "object"}}}},
+			},
+		}},
+		{method: http.MethodGet, path: "/docs", handler: s.DocsHandler, doc: operation{
+			id: "getDocs", summary: "Renders the OpenAPI document as interactive documentation",
+			responses: []response{
+				{status: http.StatusOK, description: "The documentation page.", content: []content{{mediaType: "text/html"}}},
+			},
+		}},
+	}
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"errors"
 	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/server"
 	"github.com/gorilla/mux"
@@ -23,18 +24,35 @@ type Runner struct {
 
 // NewRunner creates a new instance of the server runner.
 func NewRunner(conf *config.Server, messageService *MessageService) server.Runner {
-	return &Runner{
+	runner := &Runner{
 		MessageService: messageService,
 		Config:         conf,
-		Server:         http.Server{},
+		Server:         http.Server{Addr: ":" + conf.Port},
 		Router:         mux.Router{},
 	}
+	runner.Server.Handler = runner.handler()
+	return runner
 }
 
-// Start starts the server
+// Start starts the server, it returns once the server is stopped.
 func (r *Runner) Start() error {
-	muxWithMiddlewares := http.TimeoutHandler(&r.Router, r.Config.Timeout*time.Second, "Timeout!")
-	return http.ListenAndServe(":"+r.Config.Port, muxWithMiddlewares)
+	if err := r.Server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handler returns the handler chain served by Start.
+func (r *Runner) handler() http.Handler {
+	return &r.Router
+}
+
+// withTimeout bounds the duration of a non-streaming route with the server timeout.
+func (r *Runner) withTimeout(route route, handler http.HandlerFunc) http.Handler {
+	if route.streaming {
+		return handler
+	}
+	return http.TimeoutHandler(handler, r.Config.Timeout*time.Second, "Timeout!")
 }
 
 // Stop gracefully shuts down the server.
@@ -48,12 +66,26 @@ func (r *Runner) Stop(stopCh chan os.Signal) {
 	}
 }
 
-// RegisterServices configures the handlers for every route.
+// RegisterServices configures the handlers of every API version.
 func (r *Runner) RegisterServices() {
-	// register message APIs
-	r.Router.HandleFunc("/messages", r.MessageService.CreateMessageHandler).Methods(http.MethodPost)
-	r.Router.HandleFunc("/messages", r.MessageService.ListMessageHandler).Methods(http.MethodGet)
-	r.Router.HandleFunc("/messages/{id}", r.MessageService.GetMessageHandler).Methods(http.MethodGet)
-	r.Router.HandleFunc("/messages/{id}", r.MessageService.UpdateMessageHandler).Methods(http.MethodPut)
-	r.Router.HandleFunc("/messages/{id}", r.MessageService.DeleteMessageHandler).Methods(http.MethodDelete)
+	// the prefixed paths are registered on the router itself, the routes of a mux subrouter answering
+	// 404 instead of 405 to an unsupported method
+	versions := apiVersions()
+	for _, version := range versions {
+		for _, route := range version.routes(r.MessageService) {
+			handler := withVersion(servedVersion{apiVersion: version}, route.handler)
+			r.Router.Handle(version.prefix()+route.path, r.withTimeout(route, handler)).Methods(route.method)
+		}
+	}
+
+	aliased := versions[0]
+	for _, route := range aliased.routes(r.MessageService) {
+		handler := withVersion(servedVersion{apiVersion: aliased, alias: true}, route.handler)
+		handler = deprecated(aliased, r.Config.UnversionedDeprecation, r.Config.UnversionedSunset, handler)
+		r.Router.Handle(route.path, r.withTimeout(route, handler)).Methods(route.method)
+	}
+
+	// answer the unknown routes and methods with problems too
+	r.Router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	r.Router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
 }
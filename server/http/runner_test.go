@@ -1,9 +1,17 @@
 package http
 
 import (
+	"context"
 	"github.com/gharsallahmoez/palindrome/config"
+	in_memory "github.com/gharsallahmoez/palindrome/infra/database/in-memory"
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -15,7 +23,7 @@ func TestStartAndStopServer(t *testing.T) {
 		Port:    "8080",
 		Timeout: 10,
 	}
-	messageService := NewMessageService(nil)
+	messageService := NewMessageService(nil, config.New())
 	// Create a new Runner instance
 	runner := NewRunner(conf, messageService)
 	// Start the server
@@ -40,7 +48,7 @@ func TestRegisterServices(t *testing.T) {
 		Port:    "8080",
 		Timeout: 10,
 	}
-	messageService := NewMessageService(nil)
+	messageService := NewMessageService(nil, config.New())
 	// Create a new Runner instance
 	runner := NewRunner(conf, messageService)
 	// Register services
@@ -49,6 +57,143 @@ func TestRegisterServices(t *testing.T) {
 	assert.NotNil(t, messageService.GetMessageHandler)
 	assert.NotNil(t, messageService.CreateMessageHandler)
 	assert.NotNil(t, messageService.ListMessageHandler)
+	assert.NotNil(t, messageService.SearchMessagesHandler)
+	assert.NotNil(t, messageService.ExportMessagesHandler)
+	assert.NotNil(t, messageService.ImportMessagesHandler)
 	assert.NotNil(t, messageService.UpdateMessageHandler)
+	assert.NotNil(t, messageService.PatchMessageHandler)
 	assert.NotNil(t, messageService.DeleteMessageHandler)
+	assert.NotNil(t, messageService.CreateMessagesBatchHandler)
+	assert.NotNil(t, messageService.UpdateMessagesBatchHandler)
+	assert.NotNil(t, messageService.DeleteMessagesBatchHandler)
+	assert.NotNil(t, messageService.TrashHandler)
+	assert.NotNil(t, messageService.RestoreMessageHandler)
+	assert.NotNil(t, messageService.ListRevisionsHandler)
+	assert.NotNil(t, messageService.GetRevisionHandler)
+	assert.NotNil(t, messageService.DiffRevisionHandler)
+	assert.NotNil(t, messageService.RestoreRevisionHandler)
+	assert.NotNil(t, messageService.StatsHandler)
+	assert.NotNil(t, messageService.AnalyzeHandler)
+	assert.NotNil(t, messageService.AnalyzeBatchHandler)
+	assert.NotNil(t, messageService.OpenAPIHandler)
+	assert.NotNil(t, messageService.DocsHandler)
+}
+
+func TestRegisterServicesProblems(t *testing.T) {
+	conf := &config.Server{
+		Port:    "8080",
+		Timeout: 10,
+	}
+	runner := &Runner{MessageService: NewMessageService(nil, config.New()), Config: conf}
+	runner.RegisterServices()
+
+	// Unknown routes and methods are answered with problems
+	for path, status := range map[string]int{"/unknown": http.StatusNotFound, "/stats": http.StatusMethodNotAllowed,
+		"/v1/unknown": http.StatusNotFound, "/v1/stats": http.StatusMethodNotAllowed} {
+		req := httptest.NewRequest(http.MethodPatch, path, nil)
+		rr := httptest.NewRecorder()
+		runner.Router.ServeHTTP(rr, req)
+		assert.Equal(t, status, rr.Code, "Status code should match")
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"), "Content type should match")
+	}
+}
+
+func TestRegisterServicesVersions(t *testing.T) {
+	conf := &config.Server{
+		Port:                   "8080",
+		Timeout:                10,
+		UnversionedDeprecation: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+		UnversionedSunset:      time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC),
+	}
+	runner := &Runner{MessageService: NewMessageService(nil, config.New()), Config: conf}
+	runner.RegisterServices()
+
+	testCases := []struct {
+		Name                string
+		Path                string
+		ExpectedDeprecation string
+		ExpectedSunset      string
+		ExpectedLink        string
+		ExpectedServer      string
+	}{
+		{
+			Name:           "versioned path",
+			Path:           "/v1/openapi.json",
+			ExpectedServer: `"servers":[{"url":"/v1"}]`,
+		},
+		{
+			Name:                "unversioned alias",
+			Path:                "/openapi.json",
+			ExpectedDeprecation: "@1792281600",
+			ExpectedSunset:      "Sun, 18 Apr 2027 00:00:00 GMT",
+			ExpectedLink:        `</v1/openapi.json>; rel="successor-version"`,
+			ExpectedServer:      `"servers":[{"url":"/v1"}]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a response recorder to record the response
+			rr := httptest.NewRecorder()
+
+			// Call the router
+			runner.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.Path, nil))
+			assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+			assert.Equal(t, tc.ExpectedDeprecation, rr.Header().Get("Deprecation"), "Deprecation should match")
+			assert.Equal(t, tc.ExpectedSunset, rr.Header().Get("Sunset"), "Sunset should match")
+			assert.Equal(t, tc.ExpectedLink, rr.Header().Get("Link"), "Link should match")
+			assert.Contains(t, rr.Body.String(), tc.ExpectedServer, "Document should describe the version")
+		})
+	}
+}
+
+func TestStartStreamingRoutes(t *testing.T) {
+	conf := &config.Server{
+		Port:    "8080",
+		Timeout: 10,
+	}
+	runner := &Runner{MessageService: NewMessageService(in_memory.NewRepo(), config.New()), Config: conf}
+	runner.RegisterServices()
+
+	for _, prefix := range []string{"/v1", ""} {
+		t.Run("prefix "+prefix, func(t *testing.T) {
+			// Import two messages through the handler chain served by Start
+			body := strings.NewReader("{\"content\":\"kayak\"}\n{\"content\":\"hello\"}\n")
+			rr := httptest.NewRecorder()
+			runner.handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, prefix+"/messages/import", body))
+			assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+
+			// The export is streamed, the server timeout not buffering it
+			rr = httptest.NewRecorder()
+			runner.handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, prefix+"/messages/export", nil))
+			assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+			assert.True(t, rr.Flushed, "Export should be flushed")
+			assert.Contains(t, rr.Body.String(), `"content":"kayak"`, "Body should hold the messages")
+		})
+	}
+}
+
+func TestVersionSchema(t *testing.T) {
+	repo := in_memory.NewRepo()
+	saved, err := repo.SaveMessage(model.NewMessage("kayak", true), context.Background())
+	assert.NoError(t, err)
+	service := NewMessageService(repo, config.New())
+
+	// A version with its own message schema
+	type messageV2 struct {
+		ID   string `json:"id"`
+		Text string `json:"text"`
+	}
+	v2 := servedVersion{apiVersion: apiVersion{name: "v2", schema: schema{
+		message: func(message model.Message, _ *palindrome.Repair) any {
+			return messageV2{ID: message.ID, Text: message.Content}
+		},
+	}}}
+
+	// The shared handler writes the schema of the version and selects its fields
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/v2/messages/"+saved.ID+"?fields=text", nil), map[string]string{"id": saved.ID})
+	rr := httptest.NewRecorder()
+	withVersion(v2, service.GetMessageHandler)(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+	assert.JSONEq(t, `{"id": "`+saved.ID+`", "text": "kayak"}`, rr.Body.String(), "Body should follow the schema of the version")
 }
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gharsallahmoez/palindrome/model"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// defaultSearchLimit is the number of hits when the limit is not set.
+	defaultSearchLimit = 20
+	// maxSearchLimit bounds the number of hits.
+	maxSearchLimit = 100
+	// maxSearchTerms bounds the number of words, prefixes and phrases of a search.
+	maxSearchTerms = 32
+)
+
+// SearchHitResponse is a message matching a search, with its relevance and a highlighted excerpt.
+type SearchHitResponse struct {
+	Message MessageResponse `json:"message"`
+	Score   float64         `json:"score"`
+	Snippet string          `json:"snippet"`
+}
+
+// SearchMessagesHandler handles HTTP requests to search the message contents by words, prefixes and phrases.
+func (s *MessageService) SearchMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	hits, err := s.database.SearchMessages(query, r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response := make([]any, len(hits))
+	for index, hit := range hits {
+		response[index] = versionOf(r).schema.searchHit(hit)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// parseSearchQuery reads the terms and the pagination of a search from the query string.
+func parseSearchQuery(r *http.Request) (model.SearchQuery, error) {
+	values := r.URL.Query()
+	query := model.ParseSearchQuery(values.Get("q"))
+	if len(query.Terms) == 0 {
+		return model.SearchQuery{}, fmt.Errorf("q should hold at least one word")
+	}
+	if len(query.Terms) > maxSearchTerms {
+		return model.SearchQuery{}, fmt.Errorf("q should hold at most %d terms", maxSearchTerms)
+	}
+	query.Limit = defaultSearchLimit
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			return model.SearchQuery{}, fmt.Errorf("limit should be an integer between 1 and %d", maxSearchLimit)
+		}
+		query.Limit = limit
+	}
+	if value := values.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return model.SearchQuery{}, fmt.Errorf("offset should be a non-negative integer")
+		}
+		query.Offset = offset
+	}
+	return query, nil
+}
@@ -0,0 +1,141 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSearchMessagesHandler tests SearchMessagesHandler function.
+func TestSearchMessagesHandler(t *testing.T) {
+	// Mock database search function returning a hit
+	var searched model.SearchQuery
+	dbMock := &DatabaseMock{
+		SearchMessagesFunc: func(query model.SearchQuery, ctx context.Context) ([]model.SearchHit, error) {
+			searched = query
+			return []model.SearchHit{{
+				Message: model.Message{ID: "1", Content: "a kayak", IsPalindrome: false},
+				Score:   1.5,
+				Snippet: "a <mark>kayak</mark>",
+			}}, nil
+		},
+	}
+
+	service := svc.NewMessageService(dbMock, config.New())
+
+	// Define test cases
+	testCases := []struct {
+		Name            string
+		Query           string
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedQuery   model.SearchQuery
+	}{
+		{
+			Name:         "Valid request",
+			Query:        `q=kay*+"a+man"&limit=5&offset=10`,
+			ExpectedCode: http.StatusOK,
+			ExpectedQuery: model.SearchQuery{
+				Terms:  []model.SearchTerm{{Words: []string{"kay"}, Prefix: true}, {Words: []string{"a", "man"}}},
+				Limit:  5,
+				Offset: 10,
+			},
+		},
+		{
+			Name:          "Default limit",
+			Query:         "q=kayak",
+			ExpectedCode:  http.StatusOK,
+			ExpectedQuery: model.SearchQuery{Terms: []model.SearchTerm{{Words: []string{"kayak"}}}, Limit: 20},
+		},
+		{
+			Name:            "No words",
+			Query:           "q=+*+",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "q should hold at least one word",
+		},
+		{
+			Name:            "Too many terms",
+			Query:           "q=" + strings.Repeat("kayak+", 33),
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "q should hold at most 32 terms",
+		},
+		{
+			Name:            "Invalid limit",
+			Query:           "q=kayak&limit=101",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "limit should be an integer between 1 and 100",
+		},
+		{
+			Name:            "Invalid offset",
+			Query:           "q=kayak&offset=-1",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "offset should be a non-negative integer",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a request with the query string
+			req, err := http.NewRequest("GET", "/messages/search?"+tc.Query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the SearchMessagesHandler method
+			handler := http.HandlerFunc(service.SearchMessagesHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedMessage != "" {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
+				return
+			}
+
+			var response []svc.SearchHitResponse
+			err = json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.ExpectedQuery, searched, "Search query should match")
+			assert.Equal(t, []svc.SearchHitResponse{{
+				Message: svc.MessageResponse{ID: "1", Content: "a kayak"},
+				Score:   1.5,
+				Snippet: "a <mark>kayak</mark>",
+			}}, response, "Response body should match")
+		})
+	}
+
+	t.Run("with failed db operation", func(t *testing.T) {
+		dbMock := &DatabaseMock{
+			SearchMessagesFunc: func(query model.SearchQuery, ctx context.Context) ([]model.SearchHit, error) {
+				return nil, errors.New("some error")
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("GET", "/messages/search?q=kayak", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the SearchMessagesHandler method
+		handler := http.HandlerFunc(service.SearchMessagesHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
+	})
+}
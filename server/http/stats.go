@@ -0,0 +1,106 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gharsallahmoez/palindrome/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultStatsTop is the number of most frequent palindromes when top is not set.
+	defaultStatsTop = 10
+	// maxStatsTop bounds the number of most frequent palindromes.
+	maxStatsTop = 100
+)
+
+// StatsResponse holds the aggregates of the messages created in the requested range.
+type StatsResponse struct {
+	Total           int64                  `json:"total"`
+	Palindromes     int64                  `json:"palindromes"`
+	PalindromeRatio float64                `json:"palindrome_ratio"`
+	AverageLength   float64                `json:"average_length"`
+	Created         []BucketCountResponse  `json:"created"`
+	TopPalindromes  []ContentCountResponse `json:"top_palindromes"`
+}
+
+// BucketCountResponse is the number of messages created in the bucket starting at Start.
+type BucketCountResponse struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
+
+// ContentCountResponse is the number of messages holding a palindrome content.
+type ContentCountResponse struct {
+	Content string `json:"content"`
+	Count   int64  `json:"count"`
+}
+
+// StatsHandler handles HTTP requests to aggregate the messages for the dashboards.
+func (s *MessageService) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseStatsQuery(r)
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	stats, err := s.database.MessageStats(query, r.Context())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response := StatsResponse{
+		Total:          stats.Total,
+		Palindromes:    stats.Palindromes,
+		AverageLength:  stats.AverageLength,
+		Created:        []BucketCountResponse{},
+		TopPalindromes: make([]ContentCountResponse, len(stats.TopPalindromes)),
+	}
+	if stats.Total > 0 {
+		response.PalindromeRatio = float64(stats.Palindromes) / float64(stats.Total)
+	}
+	for _, count := range model.FillBuckets(stats.Created, query.Bucket) {
+		response.Created = append(response.Created, BucketCountResponse{Start: count.Start, Count: count.Count})
+	}
+	for index, count := range stats.TopPalindromes {
+		response.TopPalindromes[index] = ContentCountResponse{Content: count.Content, Count: count.Count}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// parseStatsQuery reads the parameters of the stats from the query string.
+func parseStatsQuery(r *http.Request) (model.StatsQuery, error) {
+	values := r.URL.Query()
+	query := model.StatsQuery{Bucket: model.BucketSize(values.Get("bucket")), Top: defaultStatsTop}
+	switch query.Bucket {
+	case "":
+		query.Bucket = model.BucketDay
+	case model.BucketHour, model.BucketDay:
+	default:
+		return model.StatsQuery{}, fmt.Errorf("bucket should be %s or %s", model.BucketHour, model.BucketDay)
+	}
+	for name, bound := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := values.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return model.StatsQuery{}, fmt.Errorf("%s should be an RFC 3339 timestamp", name)
+			}
+			*bound = t
+		}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return model.StatsQuery{}, fmt.Errorf("from should be before to")
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && query.Bucket.Count(query.From, query.To) > model.MaxBuckets {
+		return model.StatsQuery{}, fmt.Errorf("from and to should span at most %d %s buckets", model.MaxBuckets, query.Bucket)
+	}
+	if value := values.Get("top"); value != "" {
+		top, err := strconv.Atoi(value)
+		if err != nil || top < 1 || top > maxStatsTop {
+			return model.StatsQuery{}, fmt.Errorf("top should be an integer between 1 and %d", maxStatsTop)
+		}
+		query.Top = top
+	}
+	return query, nil
+}
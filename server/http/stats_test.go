@@ -0,0 +1,163 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStatsHandler tests StatsHandler function.
+func TestStatsHandler(t *testing.T) {
+	// Mock database stats function counting messages created two hours apart
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var aggregated model.StatsQuery
+	dbMock := &DatabaseMock{
+		MessageStatsFunc: func(query model.StatsQuery, ctx context.Context) (model.Stats, error) {
+			aggregated = query
+			return model.Stats{
+				Total:          4,
+				Palindromes:    3,
+				AverageLength:  5.5,
+				Created:        []model.BucketCount{{Start: start, Count: 3}, {Start: start.Add(2 * time.Hour), Count: 1}},
+				TopPalindromes: []model.ContentCount{{Content: "kayak", Count: 2}},
+			}, nil
+		},
+	}
+
+	service := svc.NewMessageService(dbMock, config.New())
+
+	// Define test cases
+	testCases := []struct {
+		Name            string
+		Query           string
+		ExpectedCode    int
+		ExpectedMessage string
+		ExpectedQuery   model.StatsQuery
+	}{
+		{
+			Name:          "Valid request",
+			Query:         "from=2024-03-01T00:00:00Z&to=2024-03-02T00:00:00Z&bucket=hour&top=5",
+			ExpectedCode:  http.StatusOK,
+			ExpectedQuery: model.StatsQuery{From: start.Add(-10 * time.Hour), To: start.Add(14 * time.Hour), Bucket: model.BucketHour, Top: 5},
+		},
+		{
+			Name:            "Invalid bucket",
+			Query:           "bucket=week",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "bucket should be hour or day",
+		},
+		{
+			Name:            "Invalid range",
+			Query:           "from=2024-03-02T00:00:00Z&to=2024-03-01T00:00:00Z",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "from should be before to",
+		},
+		{
+			Name:            "Too many buckets",
+			Query:           "from=2024-01-01T00:00:00Z&to=2026-01-01T00:00:00Z&bucket=hour",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "from and to should span at most 10000 hour buckets",
+		},
+		{
+			Name:            "Invalid time",
+			Query:           "from=yesterday",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "from should be an RFC 3339 timestamp",
+		},
+		{
+			Name:            "Invalid top",
+			Query:           "top=0",
+			ExpectedCode:    http.StatusBadRequest,
+			ExpectedMessage: "top should be an integer between 1 and 100",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a request with the query string
+			req, err := http.NewRequest("GET", "/stats?"+tc.Query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the StatsHandler method
+			handler := http.HandlerFunc(service.StatsHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedMessage != "" {
+				assertProblem(t, rr, tc.ExpectedCode, tc.ExpectedMessage)
+				return
+			}
+
+			var response svc.StatsResponse
+			err = json.NewDecoder(rr.Body).Decode(&response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.ExpectedQuery, aggregated, "Stats query should match")
+			assert.Equal(t, svc.StatsResponse{
+				Total:           4,
+				Palindromes:     3,
+				PalindromeRatio: 0.75,
+				AverageLength:   5.5,
+				Created: []svc.BucketCountResponse{
+					{Start: start, Count: 3},
+					{Start: start.Add(time.Hour), Count: 0},
+					{Start: start.Add(2 * time.Hour), Count: 1},
+				},
+				TopPalindromes: []svc.ContentCountResponse{{Content: "kayak", Count: 2}},
+			}, response, "Response body should match")
+		})
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/stats", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the StatsHandler method
+		handler := http.HandlerFunc(service.StatsHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+		assert.Equal(t, model.StatsQuery{Bucket: model.BucketDay, Top: 10}, aggregated, "Stats query should match")
+	})
+
+	t.Run("with failed db operation", func(t *testing.T) {
+		dbMock := &DatabaseMock{
+			MessageStatsFunc: func(query model.StatsQuery, ctx context.Context) (model.Stats, error) {
+				return model.Stats{}, errors.New("some error")
+			},
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		req, err := http.NewRequest("GET", "/stats", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the StatsHandler method
+		handler := http.HandlerFunc(service.StatsHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
+	})
+}
@@ -1,55 +1,116 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"time"
 )
 
-// UpdateMessageHandler handles HTTP requests to create a new message.
+// UpdateMessageHandler handles HTTP requests to update a message.
 func (s *MessageService) UpdateMessageHandler(w http.ResponseWriter, r *http.Request) {
 	// Retrieve id from query.
 	id := mux.Vars(r)["id"]
 	if id == "" {
-		http.Error(w, "id should not be empty", http.StatusBadRequest)
+		writeError(w, r, errEmptyID)
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, r, invalid(err))
+		return
+	}
+
+	httpRequest, err := s.decodeMessageRequest(w, r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	httpRequest := MessageRequest{}
-	if err := json.NewDecoder(r.Body).Decode(&httpRequest); err != nil {
-		logrus.Errorf(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	version, err := s.expectedVersion(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	// update the message in the database.
-	savedMessage, err := s.database.UpdateMessage(id, httpRequest.Content, isPalindrome(httpRequest.Content), r.Context())
+	savedMessage, err := s.updateMessage(id, version, httpRequest, r.Context())
 	if err != nil {
-		if errors.Is(err, model.ErrMessageNotFound) {
-			http.Error(w, "message not found", http.StatusNotFound)
-		} else {
-			logrus.Errorf(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeError(w, r, preconditionError(err))
 		return
 	}
 	logrus.Infof("message with id %s updated successfully", savedMessage.ID)
 
 	// Build response JSON.
-	response := MessageResponse{
-		ID:           savedMessage.ID,
-		Content:      savedMessage.Content,
-		IsPalindrome: savedMessage.IsPalindrome,
-	}
-	responseJSON, err := json.Marshal(response)
+	responseJSON, err := json.Marshal(fields.apply(versionOf(r).schema.message(savedMessage, nil)))
 	if err != nil {
-		logrus.Errorf(err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(savedMessage.Version))
 	_, _ = w.Write(responseJSON)
 }
+
+// updateMessage validates the request and updates the message it describes.
+func (s *MessageService) updateMessage(id string, version int64, req MessageRequest, ctx context.Context) (model.Message, error) {
+	now := time.Now()
+	if violations := s.validateMessageRequest(req, now); len(violations) > 0 {
+		return model.Message{}, model.NewValidationError(violations)
+	}
+
+	if req.Mode == "" {
+		return s.database.PatchMessage(id, version, func(stored model.Message) (model.Message, error) {
+			return keepingStored(updatedMessage(id, version, req, stored.Mode, now), stored), nil
+		}, ctx)
+	}
+	mode, err := s.resolveMode(req.Mode)
+	if err != nil {
+		return model.Message{}, err
+	}
+	return s.database.UpdateMessage(updatedMessage(id, version, req, mode, now), ctx)
+}
+
+// updatePatch returns the patch applying the request on a message.
+func (s *MessageService) updatePatch(id string, version int64, req MessageRequest, now time.Time) (model.Patch, error) {
+	var mode palindrome.Mode
+	if req.Mode != "" {
+		var err error
+		if mode, err = s.resolveMode(req.Mode); err != nil {
+			return model.Patch{}, err
+		}
+	}
+	return model.Patch{ID: id, Version: version, Apply: func(stored model.Message) (model.Message, error) {
+		if req.Mode == "" {
+			return keepingStored(updatedMessage(id, version, req, stored.Mode, now), stored), nil
+		}
+		return keepingStored(updatedMessage(id, version, req, mode, now), stored), nil
+	}}, nil
+}
+
+// keepingStored returns the update keeping the stored expiry when unset.
+func keepingStored(message, stored model.Message) model.Message {
+	if message.ExpiresAt == nil {
+		message.ExpiresAt = stored.ExpiresAt
+	}
+	return message
+}
+
+// updatedMessage returns the update of the message described by the request.
+func updatedMessage(id string, version int64, req MessageRequest, mode palindrome.Mode, now time.Time) model.Message {
+	expiresAt := req.expiry(now)
+	analysis := palindrome.Analyze(req.Content, mode)
+	return model.Message{
+		ID:           id,
+		Content:      req.Content,
+		IsPalindrome: analysis.IsPalindrome(),
+		Mode:         mode,
+		Analysis:     analysis,
+		Version:      version,
+		ExpiresAt:    expiresAt,
+	}
+}
@@ -6,29 +6,45 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"net/http/httptest"
 	"testing"
 
+	"github.com/gharsallahmoez/palindrome/config"
 	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
 	svc "github.com/gharsallahmoez/palindrome/server/http"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
+// patchStoredMessage returns a mock of PatchMessage applying the patch on the stored message at its version.
+func patchStoredMessage(stored model.Message) func(string, int64, func(model.Message) (model.Message, error), context.Context) (model.Message, error) {
+	return func(id string, version int64, patch func(model.Message) (model.Message, error), ctx context.Context) (model.Message, error) {
+		if id != stored.ID {
+			return model.Message{}, model.ErrMessageNotFound
+		}
+		if version != 0 && version != stored.Version {
+			return model.Message{}, model.ErrVersionMismatch
+		}
+		message, err := patch(stored)
+		if err != nil {
+			return model.Message{}, err
+		}
+		message.Version = stored.Version + 1
+		return message, nil
+	}
+}
+
 // TestUpdateMessageHandler tests UpdateMessageHandler function.
 func TestUpdateMessageHandler(t *testing.T) {
-	// Mock database update function for valid request
+	// Mock database update functions for valid request, the requests without mode keeping the stored one
 	dbMock := &DatabaseMock{
-		UpdateMessageFunc: func(id, content string, isPalindrome bool, ctx context.Context) (model.Message, error) {
-			return model.Message{
-				ID:           id,
-				Content:      content,
-				IsPalindrome: isPalindrome,
-			}, nil
+		UpdateMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
+			return message, nil
 		},
+		PatchMessageFunc: patchStoredMessage(model.Message{ID: "1", Mode: palindrome.Alphanumeric}),
 	}
 
-	service := svc.NewMessageService(dbMock)
+	service := svc.NewMessageService(dbMock, config.New())
 
 	// Define test cases
 	testCases := []struct {
@@ -49,8 +65,65 @@ func TestUpdateMessageHandler(t *testing.T) {
 				ID:           "1",
 				Content:      "updated message",
 				IsPalindrome: false,
+				Mode:         "alphanumeric",
+				Analysis: svc.AnalysisResponse{
+					Normalized:          "updatedmessage",
+					Length:              14,
+					FirstMismatch:       0,
+					LongestPalindrome:   svc.SubstringResponse{Text: "ss", Start: 9, End: 11},
+					DistinctPalindromes: 10,
+				},
+				Version: 1,
 			},
 		},
+		{
+			Name: "Valid request with mode",
+			ID:   "1",
+			RequestBody: []byte(`{
+				"content": "fall leaves after leaves fall",
+				"mode": "word"
+			}`),
+			ExpectedCode: http.StatusOK,
+			ExpectedBody: svc.MessageResponse{
+				ID:           "1",
+				Content:      "fall leaves after leaves fall",
+				IsPalindrome: true,
+				Mode:         "word",
+				Analysis: svc.AnalysisResponse{
+					Normalized:          "fall leaves after leaves fall",
+					Length:              5,
+					FirstMismatch:       -1,
+					LongestPalindrome:   svc.SubstringResponse{Text: "fall leaves after leaves fall", Start: 0, End: 5},
+					DistinctPalindromes: 5,
+				},
+			},
+		},
+		{
+			Name: "Unknown mode",
+			ID:   "1",
+			RequestBody: []byte(`{
+				"content": "updated message",
+				"mode": "fuzzy"
+			}`),
+			ExpectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name: "Empty content",
+			ID:   "1",
+			RequestBody: []byte(`{
+				"content": ""
+			}`),
+			ExpectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name: "Unknown field",
+			ID:   "1",
+			RequestBody: []byte(`{
+				"content": "updated message",
+				"author": "me"
+			}`),
+			ExpectedCode: http.StatusUnprocessableEntity,
+		},
 		{
 			Name: "Empty ID",
 			ID:   "",
@@ -80,7 +153,7 @@ func TestUpdateMessageHandler(t *testing.T) {
 			req = mux.SetURLVars(req, map[string]string{"id": tc.ID})
 
 			// Create a response recorder to record the response
-			rr := httptest.NewRecorder()
+			rr := newRecorder(t, req)
 
 			// Call the UpdateMessageHandler method
 			handler := http.HandlerFunc(service.UpdateMessageHandler)
@@ -101,15 +174,47 @@ func TestUpdateMessageHandler(t *testing.T) {
 		})
 	}
 
+	t.Run("keeps the stored mode", func(t *testing.T) {
+		t.Parallel()
+		dbMock := &DatabaseMock{
+			PatchMessageFunc: patchStoredMessage(model.Message{ID: "1", Mode: palindrome.Word}),
+		}
+
+		service := svc.NewMessageService(dbMock, config.New())
+		RequestBody := []byte(`{
+			"content": "fall leaves after leaves fall"
+		}`)
+		req, err := http.NewRequest("PUT", "/messages/1", bytes.NewBuffer(RequestBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Set the request variables
+		req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+		// Create a response recorder to record the response
+		rr := newRecorder(t, req)
+
+		// Call the UpdateMessageHandler method
+		handler := http.HandlerFunc(service.UpdateMessageHandler)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "Status code should match")
+		var response svc.MessageResponse
+		err = json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "word", response.Mode, "Mode should be kept")
+		assert.True(t, response.IsPalindrome, "Content should be analyzed in the stored mode")
+	})
+
 	t.Run("non-exist message", func(t *testing.T) {
 		t.Parallel()
 		dbMock := &DatabaseMock{
-			UpdateMessageFunc: func(id, content string, isPalindrome bool, ctx context.Context) (model.Message, error) {
-				return model.Message{}, model.ErrMessageNotFound
-			},
+			PatchMessageFunc: patchStoredMessage(model.Message{ID: "2"}),
 		}
 
-		service := svc.NewMessageService(dbMock)
+		service := svc.NewMessageService(dbMock, config.New())
 		RequestBody := []byte(`{
 			"content": "test message"
 		}`)
@@ -122,7 +227,7 @@ func TestUpdateMessageHandler(t *testing.T) {
 		req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
 
 		// Call the UpdateMessageHandler method
 		handler := http.HandlerFunc(service.UpdateMessageHandler)
@@ -133,12 +238,12 @@ func TestUpdateMessageHandler(t *testing.T) {
 	t.Run("with failed db operation", func(t *testing.T) {
 		t.Parallel()
 		dbMock := &DatabaseMock{
-			UpdateMessageFunc: func(id, content string, isPalindrome bool, ctx context.Context) (model.Message, error) {
+			PatchMessageFunc: func(id string, version int64, patch func(model.Message) (model.Message, error), ctx context.Context) (model.Message, error) {
 				return model.Message{}, errors.New("some error")
 			},
 		}
 
-		service := svc.NewMessageService(dbMock)
+		service := svc.NewMessageService(dbMock, config.New())
 		RequestBody := []byte(`{
 			"content": "test message"
 		}`)
@@ -151,7 +256,7 @@ func TestUpdateMessageHandler(t *testing.T) {
 		req = mux.SetURLVars(req, map[string]string{"id": "1"})
 
 		// Create a response recorder to record the response
-		rr := httptest.NewRecorder()
+		rr := newRecorder(t, req)
 
 		// Call the UpdateMessageHandler method
 		handler := http.HandlerFunc(service.UpdateMessageHandler)
@@ -159,3 +264,56 @@ func TestUpdateMessageHandler(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, rr.Code, "Status code should match")
 	})
 }
+
+// TestUpdateMessageHandlerIfMatch tests the If-Match precondition of UpdateMessageHandler.
+func TestUpdateMessageHandlerIfMatch(t *testing.T) {
+	// Mock a stored message at version 3
+	dbMock := &DatabaseMock{
+		PatchMessageFunc: patchStoredMessage(model.Message{ID: "1", Mode: palindrome.Alphanumeric, Version: 3}),
+	}
+
+	testCases := []struct {
+		Name           string
+		IfMatch        string
+		RequireIfMatch bool
+		ExpectedCode   int
+	}{
+		{Name: "Matching ETag", IfMatch: `"3"`, ExpectedCode: http.StatusOK},
+		{Name: "Any ETag", IfMatch: "*", ExpectedCode: http.StatusOK},
+		{Name: "Without If-Match", ExpectedCode: http.StatusOK},
+		{Name: "Stale ETag", IfMatch: `"2"`, ExpectedCode: http.StatusPreconditionFailed},
+		{Name: "Weak ETag", IfMatch: `W/"3"`, ExpectedCode: http.StatusPreconditionFailed},
+		{Name: "Unknown ETag", IfMatch: `"abc"`, ExpectedCode: http.StatusPreconditionFailed},
+		{Name: "Malformed If-Match", IfMatch: "3", ExpectedCode: http.StatusBadRequest},
+		{Name: "Required If-Match", RequireIfMatch: true, ExpectedCode: http.StatusPreconditionRequired},
+		{Name: "Required and matching ETag", IfMatch: `"3"`, RequireIfMatch: true, ExpectedCode: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			conf := config.New()
+			conf.Server.RequireIfMatch = tc.RequireIfMatch
+			service := svc.NewMessageService(dbMock, conf)
+
+			req, err := http.NewRequest("PUT", "/messages/1", bytes.NewBufferString(`{"content": "kayak"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.IfMatch != "" {
+				req.Header.Set("If-Match", tc.IfMatch)
+			}
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the UpdateMessageHandler method
+			handler := http.HandlerFunc(service.UpdateMessageHandler)
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedCode == http.StatusOK {
+				assert.Equal(t, `"4"`, rr.Header().Get("ETag"), "ETag should be the new version")
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gharsallahmoez/palindrome/model"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The codes of the violations of the message requests.
+const (
+	violationUnknownField      = "unknown_field"
+	violationInvalidType       = "invalid_type"
+	violationInvalidUTF8       = "invalid_utf8"
+	violationRequired          = "required"
+	violationTooLong           = "too_long"
+	violationControlCharacter  = "control_character"
+	violationUnknownMode       = "unknown_mode"
+	violationOutOfRange        = "out_of_range"
+	violationMutuallyExclusive = "mutually_exclusive"
+)
+
+// maxTTLSeconds is the largest ttl_seconds, whose duration does not overflow time.Duration.
+const maxTTLSeconds = math.MaxInt64 / int64(time.Second)
+
+// requestFields are the JSON names of the MessageRequest fields.
+var requestFields = jsonNames(reflect.TypeOf(MessageRequest{}))
+
+// decodeMessageRequest reads and validates the message request of the body.
+func (s *MessageService) decodeMessageRequest(w http.ResponseWriter, r *http.Request) (MessageRequest, error) {
+	request := MessageRequest{}
+	err := s.decodeRequest(w, r, requestFields, &request, func() []model.Violation {
+		return s.validateMessageRequest(request, time.Now())
+	})
+	return request, err
+}
+
+// decodeRequest reads the body into target and validates it.
+func (s *MessageService) decodeRequest(w http.ResponseWriter, r *http.Request, fields []string, target any, validate func() []model.Violation) error {
+	content, err := s.readBody(w, r)
+	if err != nil {
+		return err
+	}
+	violations, err := decodeFields(content, fields, target)
+	if err != nil {
+		return err
+	}
+	violations = mergeViolations(violations, validate())
+	if len(violations) > 0 {
+		return model.NewValidationError(violations)
+	}
+	return nil
+}
+
+// readBody reads the body of a request up to the configured size.
+func (s *MessageService) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body := r.Body
+	if s.config.Server.MaxBodyBytes > 0 {
+		body = http.MaxBytesReader(w, body, int64(s.config.Server.MaxBodyBytes))
+	}
+	content, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, model.ErrBodyTooLarge.Errorf("request body cannot be larger than %d bytes", tooLarge.Limit)
+	}
+	return content, err
+}
+
+// decodeFields decodes a JSON object into target and returns the violations of its fields.
+func decodeFields(content []byte, fields []string, target any) ([]model.Violation, error) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(content, &values); err != nil {
+		return nil, decodeError(err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var violations []model.Violation
+	for _, name := range names {
+		switch {
+		case !slices.Contains(fields, name):
+			violations = append(violations, model.Violation{Field: name, Code: violationUnknownField, Message: fmt.Sprintf("%s is an unknown field", name)})
+		case !utf8.Valid(values[name]):
+			violations = append(violations, model.Violation{Field: name, Code: violationInvalidUTF8, Message: fmt.Sprintf("%s should be valid UTF-8", name)})
+		}
+	}
+
+	err := json.Unmarshal(content, target)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		violations = append(violations, model.Violation{Field: typeErr.Field, Code: violationInvalidType, Message: fmt.Sprintf("%s has an invalid type", typeErr.Field)})
+	} else if err != nil {
+		return nil, decodeError(err)
+	}
+	return violations, nil
+}
+
+// mergeViolations appends the violations of more to violations.
+func mergeViolations(violations, more []model.Violation) []model.Violation {
+	for _, violation := range more {
+		if !slices.ContainsFunc(violations, func(v model.Violation) bool { return v.Field == violation.Field }) {
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// validateMessageRequest returns the violations of the validation rules by a message request.
+func (s *MessageService) validateMessageRequest(req MessageRequest, now time.Time) []model.Violation {
+	violations := append(s.validateContent("content", req.Content), s.validateMode(req.Mode)...)
+	violate := func(field, code, format string, args ...any) {
+		violations = append(violations, model.Violation{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case req.TTLSeconds != nil && req.ExpiresAt != nil:
+		violate("expires_at", violationMutuallyExclusive, "ttl_seconds and expires_at are mutually exclusive")
+	case req.TTLSeconds != nil && *req.TTLSeconds <= 0:
+		violate("ttl_seconds", violationOutOfRange, "ttl_seconds should be positive")
+	case req.TTLSeconds != nil && *req.TTLSeconds > maxTTLSeconds:
+		violate("ttl_seconds", violationOutOfRange, "ttl_seconds cannot be greater than %d", maxTTLSeconds)
+	case req.ExpiresAt != nil && !req.ExpiresAt.After(now):
+		violate("expires_at", violationOutOfRange, "expires_at should be in the future")
+	}
+	return violations
+}
+
+// validateContent returns the violations of the validation rules by the content of a field.
+func (s *MessageService) validateContent(field, content string) []model.Violation {
+	violation := func(code, format string, args ...any) []model.Violation {
+		return []model.Violation{{Field: field, Code: code, Message: field + " " + fmt.Sprintf(format, args...)}}
+	}
+	maxLength := s.config.Server.MaxContentLength
+	switch {
+	case content == "":
+		return violation(violationRequired, "cannot be empty")
+	case !utf8.ValidString(content):
+		return violation(violationInvalidUTF8, "should be valid UTF-8")
+	case maxLength > 0 && utf8.RuneCountInString(content) > maxLength:
+		return violation(violationTooLong, "cannot be longer than %d characters", maxLength)
+	case hasControlCharacter(content):
+		return violation(violationControlCharacter, "cannot hold control characters other than tabs and line breaks")
+	}
+	return nil
+}
+
+// validateMode returns the violation of an unknown mode, if any.
+func (s *MessageService) validateMode(mode string) []model.Violation {
+	if _, err := s.resolveMode(mode); err != nil {
+		return []model.Violation{{Field: "mode", Code: violationUnknownMode, Message: err.Error()}}
+	}
+	return nil
+}
+
+// hasControlCharacter reports whether a content holds a control character other than a tab or a line break.
+func hasControlCharacter(content string) bool {
+	for _, r := range content {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return true
+		}
+	}
+	return false
+}
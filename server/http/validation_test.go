@@ -0,0 +1,140 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gharsallahmoez/palindrome/config"
+	"github.com/gharsallahmoez/palindrome/model"
+	svc "github.com/gharsallahmoez/palindrome/server/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMessageRequestValidation tests the validation of the message requests by CreateMessageHandler.
+func TestMessageRequestValidation(t *testing.T) {
+	// Mock database save function for valid requests
+	dbMock := &DatabaseMock{
+		SaveMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
+			return message, nil
+		},
+	}
+
+	conf := config.New()
+	conf.Server.MaxContentLength = 10
+	conf.Server.MaxBodyBytes = 256
+	service := svc.NewMessageService(dbMock, conf)
+
+	// Define test cases
+	testCases := []struct {
+		Name           string
+		RequestBody    []byte
+		ExpectedCode   int
+		ExpectedErrors []svc.ViolationResponse
+	}{
+		{
+			Name:         "Valid request",
+			RequestBody:  []byte("{\"content\": \"kayak\\tpop\"}"),
+			ExpectedCode: http.StatusCreated,
+		},
+		{
+			Name:         "Every violation at once",
+			RequestBody:  []byte(`{"content": "", "mode": "fuzzy", "ttl_seconds": -1, "author": "me", "tags": []}`),
+			ExpectedCode: http.StatusUnprocessableEntity,
+			ExpectedErrors: []svc.ViolationResponse{
+				{Field: "author", Code: "unknown_field", Detail: "author is an unknown field"},
+				{Field: "tags", Code: "unknown_field", Detail: "tags is an unknown field"},
+				{Field: "content", Code: "required", Detail: "content cannot be empty"},
+				{Field: "mode", Code: "unknown_mode", Detail: "fuzzy is an unknown palindrome mode"},
+				{Field: "ttl_seconds", Code: "out_of_range", Detail: "ttl_seconds should be positive"},
+			},
+		},
+		{
+			Name:         "Overflowing TTL",
+			RequestBody:  []byte(`{"content": "kayak", "ttl_seconds": 9300000000}`),
+			ExpectedCode: http.StatusUnprocessableEntity,
+			ExpectedErrors: []svc.ViolationResponse{
+				{Field: "ttl_seconds", Code: "out_of_range", Detail: "ttl_seconds cannot be greater than 9223372036"},
+			},
+		},
+		{
+			Name:         "Too long content",
+			RequestBody:  []byte(`{"content": "ésope reste"}`),
+			ExpectedCode: http.StatusUnprocessableEntity,
+			ExpectedErrors: []svc.ViolationResponse{
+				{Field: "content", Code: "too_long", Detail: "content cannot be longer than 10 characters"},
+			},
+		},
+		{
+			Name:         "Control character",
+			RequestBody:  []byte(`{"content": "kayak\u0000"}`),
+			ExpectedCode: http.StatusUnprocessableEntity,
+			ExpectedErrors: []svc.ViolationResponse{
+				{Field: "content", Code: "control_character", Detail: "content cannot hold control characters other than tabs and line breaks"},
+			},
+		},
+		{
+			Name:         "Invalid UTF-8",
+			RequestBody:  []byte("{\"content\": \"kay\xffak\"}"),
+			ExpectedCode: http.StatusUnprocessableEntity,
+			ExpectedErrors: []svc.ViolationResponse{
+				{Field: "content", Code: "invalid_utf8", Detail: "content should be valid UTF-8"},
+			},
+		},
+		{
+			Name:         "Invalid type",
+			RequestBody:  []byte(`{"content": 42}`),
+			ExpectedCode: http.StatusUnprocessableEntity,
+			ExpectedErrors: []svc.ViolationResponse{
+				{Field: "content", Code: "invalid_type", Detail: "content has an invalid type"},
+			},
+		},
+		{
+			Name:         "Not an object",
+			RequestBody:  []byte(`["kayak"]`),
+			ExpectedCode: http.StatusBadRequest,
+		},
+		{
+			Name:         "Too large body",
+			RequestBody:  []byte(`{"content": "` + strings.Repeat("a", 256) + `"}`),
+			ExpectedCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Create a request with the request body
+			req, err := http.NewRequest("POST", "/messages", bytes.NewBuffer(tc.RequestBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Create a response recorder to record the response
+			rr := newRecorder(t, req)
+
+			// Call the CreateMessageHandler method
+			handler := http.HandlerFunc(service.CreateMessageHandler)
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, tc.ExpectedCode, rr.Code, "Status code should match")
+			if tc.ExpectedCode == http.StatusCreated {
+				return
+			}
+
+			// Check the violations of the problem
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"), "Content type should match")
+			var problem svc.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.ExpectedErrors, problem.Errors, "Violations should match")
+			if tc.ExpectedErrors != nil {
+				assert.Equal(t, "validation_failed", problem.Code, "Problem code should match")
+			}
+		})
+	}
+}
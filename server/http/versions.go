@@ -0,0 +1,110 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gharsallahmoez/palindrome/model"
+	"github.com/gharsallahmoez/palindrome/palindrome"
+)
+
+// apiVersion is a version of the API, served under the /<name> path prefix.
+type apiVersion struct {
+	name   string
+	routes func(s *MessageService) []route
+	schema schema
+}
+
+// schema maps the model to the response schemas of a version, for the handlers shared by the versions.
+type schema struct {
+	// message maps a message, with its near palindrome repair when requested, to a struct whose fields
+	// the fields query parameter selects.
+	message   func(message model.Message, repair *palindrome.Repair) any
+	revision  func(revision model.Revision) any
+	searchHit func(hit model.SearchHit) any
+	// batchItem maps a message written by a batch with the status of its item.
+	batchItem func(status int, message model.Message) any
+}
+
+// v1Schema maps the model to the schemas of the version 1.
+var v1Schema = schema{
+	message: func(message model.Message, repair *palindrome.Repair) any {
+		response := mapDomainMessageToSchema(message)
+		if repair != nil {
+			response.NearPalindrome = mapRepairToSchema(*repair)
+		}
+		return response
+	},
+	revision: func(revision model.Revision) any {
+		return mapRevisionToSchema(revision)
+	},
+	searchHit: func(hit model.SearchHit) any {
+		return SearchHitResponse{Message: mapDomainMessageToSchema(hit.Message), Score: hit.Score, Snippet: hit.Snippet}
+	},
+	batchItem: func(status int, message model.Message) any {
+		response := mapDomainMessageToSchema(message)
+		return BatchItemResponse{Status: status, ETag: etag(message.Version), Message: &response}
+	},
+}
+
+// prefix returns the path prefix of the version.
+func (v apiVersion) prefix() string {
+	return "/" + v.name
+}
+
+// apiVersions returns the versions of the API, oldest first.
+func apiVersions() []apiVersion {
+	return []apiVersion{
+		{name: "v1", routes: (*MessageService).v1Routes, schema: v1Schema},
+	}
+}
+
+// servedVersion is the version of the API serving a request.
+type servedVersion struct {
+	apiVersion
+	// alias tells whether the request reached the version through its unversioned path.
+	alias bool
+}
+
+// pathPrefix returns the path prefix of the request, empty for the unversioned aliases.
+func (v servedVersion) pathPrefix() string {
+	if v.alias {
+		return ""
+	}
+	return v.prefix()
+}
+
+// versionKey is the context key of the servedVersion.
+type versionKey struct{}
+
+// withVersion records the version serving the requests of handler in their context.
+func withVersion(version servedVersion, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, version)))
+	}
+}
+
+// versionOf returns the version serving the request.
+func versionOf(r *http.Request) servedVersion {
+	if version, ok := r.Context().Value(versionKey{}).(servedVersion); ok {
+		return version
+	}
+	return servedVersion{apiVersion: apiVersions()[0], alias: true}
+}
+
+// deprecated marks the responses of handler as served by a deprecated path.
+func deprecated(version apiVersion, deprecation, sunset time.Time, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !deprecation.IsZero() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecation.Unix(), 10))
+		}
+		if !sunset.IsZero() {
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, version.prefix(), r.URL.Path))
+		handler(w, r)
+	}
+}
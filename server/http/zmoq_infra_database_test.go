@@ -8,6 +8,7 @@ import (
 	"github.com/gharsallahmoez/palindrome/infra/database"
 	"github.com/gharsallahmoez/palindrome/model"
 	"sync"
+	"time"
 )
 
 // Ensure, that DatabaseMock does implement database.Database.
@@ -20,19 +21,67 @@ var _ database.Database = &DatabaseMock{}
 //
 //		// make and configure a mocked database.Database
 //		mockedDatabase := &DatabaseMock{
-//			DeleteMessageFunc: func(id string, ctx context.Context) error {
+//			ClaimIdempotencyKeyFunc: func(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error) {
+//				panic("mock out the ClaimIdempotencyKey method")
+//			},
+//			CloseFunc: func() error {
+//				panic("mock out the Close method")
+//			},
+//			CompleteIdempotencyKeyFunc: func(key model.IdempotencyKey, ctx context.Context) error {
+//				panic("mock out the CompleteIdempotencyKey method")
+//			},
+//			DeleteExpiredIdempotencyKeysFunc: func(now time.Time, ctx context.Context) (int64, error) {
+//				panic("mock out the DeleteExpiredIdempotencyKeys method")
+//			},
+//			DeleteExpiredMessagesFunc: func(now time.Time, ctx context.Context) (int64, error) {
+//				panic("mock out the DeleteExpiredMessages method")
+//			},
+//			DeleteMessageFunc: func(id string, version int64, ctx context.Context) error {
 //				panic("mock out the DeleteMessage method")
 //			},
+//			DeleteMessagesFunc: func(deletions []model.Deletion, ctx context.Context) ([]error, error) {
+//				panic("mock out the DeleteMessages method")
+//			},
 //			GetMessageFunc: func(id string, ctx context.Context) (model.Message, error) {
 //				panic("mock out the GetMessage method")
 //			},
-//			ListMessagesFunc: func(ctx context.Context) ([]model.Message, error) {
+//			GetRevisionFunc: func(id string, number int64, ctx context.Context) (model.Revision, error) {
+//				panic("mock out the GetRevision method")
+//			},
+//			ListMessagesFunc: func(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
 //				panic("mock out the ListMessages method")
 //			},
+//			ListRevisionsFunc: func(id string, ctx context.Context) ([]model.Revision, error) {
+//				panic("mock out the ListRevisions method")
+//			},
+//			MessageStatsFunc: func(query model.StatsQuery, ctx context.Context) (model.Stats, error) {
+//				panic("mock out the MessageStats method")
+//			},
+//			PatchMessageFunc: func(id string, version int64, patch func(model.Message) (model.Message, error), ctx context.Context) (model.Message, error) {
+//				panic("mock out the PatchMessage method")
+//			},
+//			PatchMessagesFunc: func(patches []model.Patch, ctx context.Context) ([]model.BatchResult, error) {
+//				panic("mock out the PatchMessages method")
+//			},
+//			PurgeMessagesFunc: func(before time.Time, ctx context.Context) (int64, error) {
+//				panic("mock out the PurgeMessages method")
+//			},
+//			ReleaseIdempotencyKeyFunc: func(key string, ctx context.Context) error {
+//				panic("mock out the ReleaseIdempotencyKey method")
+//			},
+//			RestoreMessageFunc: func(id string, ctx context.Context) (model.Message, error) {
+//				panic("mock out the RestoreMessage method")
+//			},
 //			SaveMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
 //				panic("mock out the SaveMessage method")
 //			},
-//			UpdateMessageFunc: func(id string, content string, isPalindrome bool, ctx context.Context) (model.Message, error) {
+//			SaveMessagesFunc: func(messages []model.Message, ctx context.Context) ([]model.BatchResult, error) {
+//				panic("mock out the SaveMessages method")
+//			},
+//			SearchMessagesFunc: func(query model.SearchQuery, ctx context.Context) ([]model.SearchHit, error) {
+//				panic("mock out the SearchMessages method")
+//			},
+//			UpdateMessageFunc: func(message model.Message, ctx context.Context) (model.Message, error) {
 //				panic("mock out the UpdateMessage method")
 //			},
 //		}
@@ -42,27 +91,115 @@ var _ database.Database = &DatabaseMock{}
 //
 //	}
 type DatabaseMock struct {
+	// ClaimIdempotencyKeyFunc mocks the ClaimIdempotencyKey method.
+	ClaimIdempotencyKeyFunc func(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error)
+
+	// CloseFunc mocks the Close method.
+	CloseFunc func() error
+
+	// CompleteIdempotencyKeyFunc mocks the CompleteIdempotencyKey method.
+	CompleteIdempotencyKeyFunc func(key model.IdempotencyKey, ctx context.Context) error
+
+	// DeleteExpiredIdempotencyKeysFunc mocks the DeleteExpiredIdempotencyKeys method.
+	DeleteExpiredIdempotencyKeysFunc func(now time.Time, ctx context.Context) (int64, error)
+
+	// DeleteExpiredMessagesFunc mocks the DeleteExpiredMessages method.
+	DeleteExpiredMessagesFunc func(now time.Time, ctx context.Context) (int64, error)
+
 	// DeleteMessageFunc mocks the DeleteMessage method.
-	DeleteMessageFunc func(id string, ctx context.Context) error
+	DeleteMessageFunc func(id string, version int64, ctx context.Context) error
+
+	// DeleteMessagesFunc mocks the DeleteMessages method.
+	DeleteMessagesFunc func(deletions []model.Deletion, ctx context.Context) ([]error, error)
 
 	// GetMessageFunc mocks the GetMessage method.
 	GetMessageFunc func(id string, ctx context.Context) (model.Message, error)
 
+	// GetRevisionFunc mocks the GetRevision method.
+	GetRevisionFunc func(id string, number int64, ctx context.Context) (model.Revision, error)
+
 	// ListMessagesFunc mocks the ListMessages method.
-	ListMessagesFunc func(ctx context.Context) ([]model.Message, error)
+	ListMessagesFunc func(query model.ListQuery, ctx context.Context) (model.MessagePage, error)
+
+	// ListRevisionsFunc mocks the ListRevisions method.
+	ListRevisionsFunc func(id string, ctx context.Context) ([]model.Revision, error)
+
+	// MessageStatsFunc mocks the MessageStats method.
+	MessageStatsFunc func(query model.StatsQuery, ctx context.Context) (model.Stats, error)
+
+	// PatchMessageFunc mocks the PatchMessage method.
+	PatchMessageFunc func(id string, version int64, patch func(model.Message) (model.Message, error), ctx context.Context) (model.Message, error)
+
+	// PatchMessagesFunc mocks the PatchMessages method.
+	PatchMessagesFunc func(patches []model.Patch, ctx context.Context) ([]model.BatchResult, error)
+
+	// PurgeMessagesFunc mocks the PurgeMessages method.
+	PurgeMessagesFunc func(before time.Time, ctx context.Context) (int64, error)
+
+	// ReleaseIdempotencyKeyFunc mocks the ReleaseIdempotencyKey method.
+	ReleaseIdempotencyKeyFunc func(key string, ctx context.Context) error
+
+	// RestoreMessageFunc mocks the RestoreMessage method.
+	RestoreMessageFunc func(id string, ctx context.Context) (model.Message, error)
 
 	// SaveMessageFunc mocks the SaveMessage method.
 	SaveMessageFunc func(message model.Message, ctx context.Context) (model.Message, error)
 
+	// SaveMessagesFunc mocks the SaveMessages method.
+	SaveMessagesFunc func(messages []model.Message, ctx context.Context) ([]model.BatchResult, error)
+
+	// SearchMessagesFunc mocks the SearchMessages method.
+	SearchMessagesFunc func(query model.SearchQuery, ctx context.Context) ([]model.SearchHit, error)
+
 	// UpdateMessageFunc mocks the UpdateMessage method.
-	UpdateMessageFunc func(id string, content string, isPalindrome bool, ctx context.Context) (model.Message, error)
+	UpdateMessageFunc func(message model.Message, ctx context.Context) (model.Message, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// ClaimIdempotencyKey holds details about calls to the ClaimIdempotencyKey method.
+		ClaimIdempotencyKey []struct {
+			// Key is the key argument value.
+			Key model.IdempotencyKey
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// Close holds details about calls to the Close method.
+		Close []struct {
+		}
+		// CompleteIdempotencyKey holds details about calls to the CompleteIdempotencyKey method.
+		CompleteIdempotencyKey []struct {
+			// Key is the key argument value.
+			Key model.IdempotencyKey
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// DeleteExpiredIdempotencyKeys holds details about calls to the DeleteExpiredIdempotencyKeys method.
+		DeleteExpiredIdempotencyKeys []struct {
+			// Now is the now argument value.
+			Now time.Time
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// DeleteExpiredMessages holds details about calls to the DeleteExpiredMessages method.
+		DeleteExpiredMessages []struct {
+			// Now is the now argument value.
+			Now time.Time
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// DeleteMessage holds details about calls to the DeleteMessage method.
 		DeleteMessage []struct {
 			// ID is the id argument value.
 			ID string
+			// Version is the version argument value.
+			Version int64
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// DeleteMessages holds details about calls to the DeleteMessages method.
+		DeleteMessages []struct {
+			// Deletions is the deletions argument value.
+			Deletions []model.Deletion
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
@@ -73,8 +210,72 @@ type DatabaseMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// GetRevision holds details about calls to the GetRevision method.
+		GetRevision []struct {
+			// ID is the id argument value.
+			ID string
+			// Number is the number argument value.
+			Number int64
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// ListMessages holds details about calls to the ListMessages method.
 		ListMessages []struct {
+			// Query is the query argument value.
+			Query model.ListQuery
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// ListRevisions holds details about calls to the ListRevisions method.
+		ListRevisions []struct {
+			// ID is the id argument value.
+			ID string
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// MessageStats holds details about calls to the MessageStats method.
+		MessageStats []struct {
+			// Query is the query argument value.
+			Query model.StatsQuery
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// PatchMessage holds details about calls to the PatchMessage method.
+		PatchMessage []struct {
+			// ID is the id argument value.
+			ID string
+			// Version is the version argument value.
+			Version int64
+			// Patch is the patch argument value.
+			Patch func(model.Message) (model.Message, error)
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// PatchMessages holds details about calls to the PatchMessages method.
+		PatchMessages []struct {
+			// Patches is the patches argument value.
+			Patches []model.Patch
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// PurgeMessages holds details about calls to the PurgeMessages method.
+		PurgeMessages []struct {
+			// Before is the before argument value.
+			Before time.Time
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// ReleaseIdempotencyKey holds details about calls to the ReleaseIdempotencyKey method.
+		ReleaseIdempotencyKey []struct {
+			// Key is the key argument value.
+			Key string
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// RestoreMessage holds details about calls to the RestoreMessage method.
+		RestoreMessage []struct {
+			// ID is the id argument value.
+			ID string
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
@@ -85,41 +286,240 @@ type DatabaseMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// SaveMessages holds details about calls to the SaveMessages method.
+		SaveMessages []struct {
+			// Messages is the messages argument value.
+			Messages []model.Message
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// SearchMessages holds details about calls to the SearchMessages method.
+		SearchMessages []struct {
+			// Query is the query argument value.
+			Query model.SearchQuery
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// UpdateMessage holds details about calls to the UpdateMessage method.
 		UpdateMessage []struct {
-			// ID is the id argument value.
-			ID string
-			// Content is the content argument value.
-			Content string
-			// IsPalindrome is the isPalindrome argument value.
-			IsPalindrome bool
+			// Message is the message argument value.
+			Message model.Message
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
 	}
-	lockDeleteMessage sync.RWMutex
-	lockGetMessage    sync.RWMutex
-	lockListMessages  sync.RWMutex
-	lockSaveMessage   sync.RWMutex
-	lockUpdateMessage sync.RWMutex
+	lockClaimIdempotencyKey          sync.RWMutex
+	lockClose                        sync.RWMutex
+	lockCompleteIdempotencyKey       sync.RWMutex
+	lockDeleteExpiredIdempotencyKeys sync.RWMutex
+	lockDeleteExpiredMessages        sync.RWMutex
+	lockDeleteMessage                sync.RWMutex
+	lockDeleteMessages               sync.RWMutex
+	lockGetMessage                   sync.RWMutex
+	lockGetRevision                  sync.RWMutex
+	lockListMessages                 sync.RWMutex
+	lockListRevisions                sync.RWMutex
+	lockMessageStats                 sync.RWMutex
+	lockPatchMessage                 sync.RWMutex
+	lockPatchMessages                sync.RWMutex
+	lockPurgeMessages                sync.RWMutex
+	lockReleaseIdempotencyKey        sync.RWMutex
+	lockRestoreMessage               sync.RWMutex
+	lockSaveMessage                  sync.RWMutex
+	lockSaveMessages                 sync.RWMutex
+	lockSearchMessages               sync.RWMutex
+	lockUpdateMessage                sync.RWMutex
+}
+
+// ClaimIdempotencyKey calls ClaimIdempotencyKeyFunc.
+func (mock *DatabaseMock) ClaimIdempotencyKey(key model.IdempotencyKey, ctx context.Context) (model.IdempotencyKey, error) {
+	if mock.ClaimIdempotencyKeyFunc == nil {
+		panic("DatabaseMock.ClaimIdempotencyKeyFunc: method is nil but Database.ClaimIdempotencyKey was just called")
+	}
+	callInfo := struct {
+		Key model.IdempotencyKey
+		Ctx context.Context
+	}{
+		Key: key,
+		Ctx: ctx,
+	}
+	mock.lockClaimIdempotencyKey.Lock()
+	mock.calls.ClaimIdempotencyKey = append(mock.calls.ClaimIdempotencyKey, callInfo)
+	mock.lockClaimIdempotencyKey.Unlock()
+	return mock.ClaimIdempotencyKeyFunc(key, ctx)
+}
+
+// ClaimIdempotencyKeyCalls gets all the calls that were made to ClaimIdempotencyKey.
+// Check the length with:
+//
+//	len(mockedDatabase.ClaimIdempotencyKeyCalls())
+func (mock *DatabaseMock) ClaimIdempotencyKeyCalls() []struct {
+	Key model.IdempotencyKey
+	Ctx context.Context
+} {
+	var calls []struct {
+		Key model.IdempotencyKey
+		Ctx context.Context
+	}
+	mock.lockClaimIdempotencyKey.RLock()
+	calls = mock.calls.ClaimIdempotencyKey
+	mock.lockClaimIdempotencyKey.RUnlock()
+	return calls
+}
+
+// Close calls CloseFunc.
+func (mock *DatabaseMock) Close() error {
+	if mock.CloseFunc == nil {
+		panic("DatabaseMock.CloseFunc: method is nil but Database.Close was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockClose.Lock()
+	mock.calls.Close = append(mock.calls.Close, callInfo)
+	mock.lockClose.Unlock()
+	return mock.CloseFunc()
+}
+
+// CloseCalls gets all the calls that were made to Close.
+// Check the length with:
+//
+//	len(mockedDatabase.CloseCalls())
+func (mock *DatabaseMock) CloseCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockClose.RLock()
+	calls = mock.calls.Close
+	mock.lockClose.RUnlock()
+	return calls
+}
+
+// CompleteIdempotencyKey calls CompleteIdempotencyKeyFunc.
+func (mock *DatabaseMock) CompleteIdempotencyKey(key model.IdempotencyKey, ctx context.Context) error {
+	if mock.CompleteIdempotencyKeyFunc == nil {
+		panic("DatabaseMock.CompleteIdempotencyKeyFunc: method is nil but Database.CompleteIdempotencyKey was just called")
+	}
+	callInfo := struct {
+		Key model.IdempotencyKey
+		Ctx context.Context
+	}{
+		Key: key,
+		Ctx: ctx,
+	}
+	mock.lockCompleteIdempotencyKey.Lock()
+	mock.calls.CompleteIdempotencyKey = append(mock.calls.CompleteIdempotencyKey, callInfo)
+	mock.lockCompleteIdempotencyKey.Unlock()
+	return mock.CompleteIdempotencyKeyFunc(key, ctx)
+}
+
+// CompleteIdempotencyKeyCalls gets all the calls that were made to CompleteIdempotencyKey.
+// Check the length with:
+//
+//	len(mockedDatabase.CompleteIdempotencyKeyCalls())
+func (mock *DatabaseMock) CompleteIdempotencyKeyCalls() []struct {
+	Key model.IdempotencyKey
+	Ctx context.Context
+} {
+	var calls []struct {
+		Key model.IdempotencyKey
+		Ctx context.Context
+	}
+	mock.lockCompleteIdempotencyKey.RLock()
+	calls = mock.calls.CompleteIdempotencyKey
+	mock.lockCompleteIdempotencyKey.RUnlock()
+	return calls
+}
+
+// DeleteExpiredIdempotencyKeys calls DeleteExpiredIdempotencyKeysFunc.
+func (mock *DatabaseMock) DeleteExpiredIdempotencyKeys(now time.Time, ctx context.Context) (int64, error) {
+	if mock.DeleteExpiredIdempotencyKeysFunc == nil {
+		panic("DatabaseMock.DeleteExpiredIdempotencyKeysFunc: method is nil but Database.DeleteExpiredIdempotencyKeys was just called")
+	}
+	callInfo := struct {
+		Now time.Time
+		Ctx context.Context
+	}{
+		Now: now,
+		Ctx: ctx,
+	}
+	mock.lockDeleteExpiredIdempotencyKeys.Lock()
+	mock.calls.DeleteExpiredIdempotencyKeys = append(mock.calls.DeleteExpiredIdempotencyKeys, callInfo)
+	mock.lockDeleteExpiredIdempotencyKeys.Unlock()
+	return mock.DeleteExpiredIdempotencyKeysFunc(now, ctx)
+}
+
+// DeleteExpiredIdempotencyKeysCalls gets all the calls that were made to DeleteExpiredIdempotencyKeys.
+// Check the length with:
+//
+//	len(mockedDatabase.DeleteExpiredIdempotencyKeysCalls())
+func (mock *DatabaseMock) DeleteExpiredIdempotencyKeysCalls() []struct {
+	Now time.Time
+	Ctx context.Context
+} {
+	var calls []struct {
+		Now time.Time
+		Ctx context.Context
+	}
+	mock.lockDeleteExpiredIdempotencyKeys.RLock()
+	calls = mock.calls.DeleteExpiredIdempotencyKeys
+	mock.lockDeleteExpiredIdempotencyKeys.RUnlock()
+	return calls
+}
+
+// DeleteExpiredMessages calls DeleteExpiredMessagesFunc.
+func (mock *DatabaseMock) DeleteExpiredMessages(now time.Time, ctx context.Context) (int64, error) {
+	if mock.DeleteExpiredMessagesFunc == nil {
+		panic("DatabaseMock.DeleteExpiredMessagesFunc: method is nil but Database.DeleteExpiredMessages was just called")
+	}
+	callInfo := struct {
+		Now time.Time
+		Ctx context.Context
+	}{
+		Now: now,
+		Ctx: ctx,
+	}
+	mock.lockDeleteExpiredMessages.Lock()
+	mock.calls.DeleteExpiredMessages = append(mock.calls.DeleteExpiredMessages, callInfo)
+	mock.lockDeleteExpiredMessages.Unlock()
+	return mock.DeleteExpiredMessagesFunc(now, ctx)
+}
+
+// DeleteExpiredMessagesCalls gets all the calls that were made to DeleteExpiredMessages.
+// Check the length with:
+//
+//	len(mockedDatabase.DeleteExpiredMessagesCalls())
+func (mock *DatabaseMock) DeleteExpiredMessagesCalls() []struct {
+	Now time.Time
+	Ctx context.Context
+} {
+	var calls []struct {
+		Now time.Time
+		Ctx context.Context
+	}
+	mock.lockDeleteExpiredMessages.RLock()
+	calls = mock.calls.DeleteExpiredMessages
+	mock.lockDeleteExpiredMessages.RUnlock()
+	return calls
 }
 
 // DeleteMessage calls DeleteMessageFunc.
-func (mock *DatabaseMock) DeleteMessage(id string, ctx context.Context) error {
+func (mock *DatabaseMock) DeleteMessage(id string, version int64, ctx context.Context) error {
 	if mock.DeleteMessageFunc == nil {
 		panic("DatabaseMock.DeleteMessageFunc: method is nil but Database.DeleteMessage was just called")
 	}
 	callInfo := struct {
-		ID  string
-		Ctx context.Context
+		ID      string
+		Version int64
+		Ctx     context.Context
 	}{
-		ID:  id,
-		Ctx: ctx,
+		ID:      id,
+		Version: version,
+		Ctx:     ctx,
 	}
 	mock.lockDeleteMessage.Lock()
 	mock.calls.DeleteMessage = append(mock.calls.DeleteMessage, callInfo)
 	mock.lockDeleteMessage.Unlock()
-	return mock.DeleteMessageFunc(id, ctx)
+	return mock.DeleteMessageFunc(id, version, ctx)
 }
 
 // DeleteMessageCalls gets all the calls that were made to DeleteMessage.
@@ -127,12 +527,14 @@ func (mock *DatabaseMock) DeleteMessage(id string, ctx context.Context) error {
 //
 //	len(mockedDatabase.DeleteMessageCalls())
 func (mock *DatabaseMock) DeleteMessageCalls() []struct {
-	ID  string
-	Ctx context.Context
+	ID      string
+	Version int64
+	Ctx     context.Context
 } {
 	var calls []struct {
-		ID  string
-		Ctx context.Context
+		ID      string
+		Version int64
+		Ctx     context.Context
 	}
 	mock.lockDeleteMessage.RLock()
 	calls = mock.calls.DeleteMessage
@@ -140,6 +542,42 @@ func (mock *DatabaseMock) DeleteMessageCalls() []struct {
 	return calls
 }
 
+// DeleteMessages calls DeleteMessagesFunc.
+func (mock *DatabaseMock) DeleteMessages(deletions []model.Deletion, ctx context.Context) ([]error, error) {
+	if mock.DeleteMessagesFunc == nil {
+		panic("DatabaseMock.DeleteMessagesFunc: method is nil but Database.DeleteMessages was just called")
+	}
+	callInfo := struct {
+		Deletions []model.Deletion
+		Ctx       context.Context
+	}{
+		Deletions: deletions,
+		Ctx:       ctx,
+	}
+	mock.lockDeleteMessages.Lock()
+	mock.calls.DeleteMessages = append(mock.calls.DeleteMessages, callInfo)
+	mock.lockDeleteMessages.Unlock()
+	return mock.DeleteMessagesFunc(deletions, ctx)
+}
+
+// DeleteMessagesCalls gets all the calls that were made to DeleteMessages.
+// Check the length with:
+//
+//	len(mockedDatabase.DeleteMessagesCalls())
+func (mock *DatabaseMock) DeleteMessagesCalls() []struct {
+	Deletions []model.Deletion
+	Ctx       context.Context
+} {
+	var calls []struct {
+		Deletions []model.Deletion
+		Ctx       context.Context
+	}
+	mock.lockDeleteMessages.RLock()
+	calls = mock.calls.DeleteMessages
+	mock.lockDeleteMessages.RUnlock()
+	return calls
+}
+
 // GetMessage calls GetMessageFunc.
 func (mock *DatabaseMock) GetMessage(id string, ctx context.Context) (model.Message, error) {
 	if mock.GetMessageFunc == nil {
@@ -176,20 +614,62 @@ func (mock *DatabaseMock) GetMessageCalls() []struct {
 	return calls
 }
 
+// GetRevision calls GetRevisionFunc.
+func (mock *DatabaseMock) GetRevision(id string, number int64, ctx context.Context) (model.Revision, error) {
+	if mock.GetRevisionFunc == nil {
+		panic("DatabaseMock.GetRevisionFunc: method is nil but Database.GetRevision was just called")
+	}
+	callInfo := struct {
+		ID     string
+		Number int64
+		Ctx    context.Context
+	}{
+		ID:     id,
+		Number: number,
+		Ctx:    ctx,
+	}
+	mock.lockGetRevision.Lock()
+	mock.calls.GetRevision = append(mock.calls.GetRevision, callInfo)
+	mock.lockGetRevision.Unlock()
+	return mock.GetRevisionFunc(id, number, ctx)
+}
+
+// GetRevisionCalls gets all the calls that were made to GetRevision.
+// Check the length with:
+//
+//	len(mockedDatabase.GetRevisionCalls())
+func (mock *DatabaseMock) GetRevisionCalls() []struct {
+	ID     string
+	Number int64
+	Ctx    context.Context
+} {
+	var calls []struct {
+		ID     string
+		Number int64
+		Ctx    context.Context
+	}
+	mock.lockGetRevision.RLock()
+	calls = mock.calls.GetRevision
+	mock.lockGetRevision.RUnlock()
+	return calls
+}
+
 // ListMessages calls ListMessagesFunc.
-func (mock *DatabaseMock) ListMessages(ctx context.Context) ([]model.Message, error) {
+func (mock *DatabaseMock) ListMessages(query model.ListQuery, ctx context.Context) (model.MessagePage, error) {
 	if mock.ListMessagesFunc == nil {
 		panic("DatabaseMock.ListMessagesFunc: method is nil but Database.ListMessages was just called")
 	}
 	callInfo := struct {
-		Ctx context.Context
+		Query model.ListQuery
+		Ctx   context.Context
 	}{
-		Ctx: ctx,
+		Query: query,
+		Ctx:   ctx,
 	}
 	mock.lockListMessages.Lock()
 	mock.calls.ListMessages = append(mock.calls.ListMessages, callInfo)
 	mock.lockListMessages.Unlock()
-	return mock.ListMessagesFunc(ctx)
+	return mock.ListMessagesFunc(query, ctx)
 }
 
 // ListMessagesCalls gets all the calls that were made to ListMessages.
@@ -197,10 +677,12 @@ func (mock *DatabaseMock) ListMessages(ctx context.Context) ([]model.Message, er
 //
 //	len(mockedDatabase.ListMessagesCalls())
 func (mock *DatabaseMock) ListMessagesCalls() []struct {
-	Ctx context.Context
+	Query model.ListQuery
+	Ctx   context.Context
 } {
 	var calls []struct {
-		Ctx context.Context
+		Query model.ListQuery
+		Ctx   context.Context
 	}
 	mock.lockListMessages.RLock()
 	calls = mock.calls.ListMessages
@@ -208,6 +690,266 @@ func (mock *DatabaseMock) ListMessagesCalls() []struct {
 	return calls
 }
 
+// ListRevisions calls ListRevisionsFunc.
+func (mock *DatabaseMock) ListRevisions(id string, ctx context.Context) ([]model.Revision, error) {
+	if mock.ListRevisionsFunc == nil {
+		panic("DatabaseMock.ListRevisionsFunc: method is nil but Database.ListRevisions was just called")
+	}
+	callInfo := struct {
+		ID  string
+		Ctx context.Context
+	}{
+		ID:  id,
+		Ctx: ctx,
+	}
+	mock.lockListRevisions.Lock()
+	mock.calls.ListRevisions = append(mock.calls.ListRevisions, callInfo)
+	mock.lockListRevisions.Unlock()
+	return mock.ListRevisionsFunc(id, ctx)
+}
+
+// ListRevisionsCalls gets all the calls that were made to ListRevisions.
+// Check the length with:
+//
+//	len(mockedDatabase.ListRevisionsCalls())
+func (mock *DatabaseMock) ListRevisionsCalls() []struct {
+	ID  string
+	Ctx context.Context
+} {
+	var calls []struct {
+		ID  string
+		Ctx context.Context
+	}
+	mock.lockListRevisions.RLock()
+	calls = mock.calls.ListRevisions
+	mock.lockListRevisions.RUnlock()
+	return calls
+}
+
+// MessageStats calls MessageStatsFunc.
+func (mock *DatabaseMock) MessageStats(query model.StatsQuery, ctx context.Context) (model.Stats, error) {
+	if mock.MessageStatsFunc == nil {
+		panic("DatabaseMock.MessageStatsFunc: method is nil but Database.MessageStats was just called")
+	}
+	callInfo := struct {
+		Query model.StatsQuery
+		Ctx   context.Context
+	}{
+		Query: query,
+		Ctx:   ctx,
+	}
+	mock.lockMessageStats.Lock()
+	mock.calls.MessageStats = append(mock.calls.MessageStats, callInfo)
+	mock.lockMessageStats.Unlock()
+	return mock.MessageStatsFunc(query, ctx)
+}
+
+// MessageStatsCalls gets all the calls that were made to MessageStats.
+// Check the length with:
+//
+//	len(mockedDatabase.MessageStatsCalls())
+func (mock *DatabaseMock) MessageStatsCalls() []struct {
+	Query model.StatsQuery
+	Ctx   context.Context
+} {
+	var calls []struct {
+		Query model.StatsQuery
+		Ctx   context.Context
+	}
+	mock.lockMessageStats.RLock()
+	calls = mock.calls.MessageStats
+	mock.lockMessageStats.RUnlock()
+	return calls
+}
+
+// PatchMessage calls PatchMessageFunc.
+func (mock *DatabaseMock) PatchMessage(id string, version int64, patch func(model.Message) (model.Message, error), ctx context.Context) (model.Message, error) {
+	if mock.PatchMessageFunc == nil {
+		panic("DatabaseMock.PatchMessageFunc: method is nil but Database.PatchMessage was just called")
+	}
+	callInfo := struct {
+		ID      string
+		Version int64
+		Patch   func(model.Message) (model.Message, error)
+		Ctx     context.Context
+	}{
+		ID:      id,
+		Version: version,
+		Patch:   patch,
+		Ctx:     ctx,
+	}
+	mock.lockPatchMessage.Lock()
+	mock.calls.PatchMessage = append(mock.calls.PatchMessage, callInfo)
+	mock.lockPatchMessage.Unlock()
+	return mock.PatchMessageFunc(id, version, patch, ctx)
+}
+
+// PatchMessageCalls gets all the calls that were made to PatchMessage.
+// Check the length with:
+//
+//	len(mockedDatabase.PatchMessageCalls())
+func (mock *DatabaseMock) PatchMessageCalls() []struct {
+	ID      string
+	Version int64
+	Patch   func(model.Message) (model.Message, error)
+	Ctx     context.Context
+} {
+	var calls []struct {
+		ID      string
+		Version int64
+		Patch   func(model.Message) (model.Message, error)
+		Ctx     context.Context
+	}
+	mock.lockPatchMessage.RLock()
+	calls = mock.calls.PatchMessage
+	mock.lockPatchMessage.RUnlock()
+	return calls
+}
+
+// PatchMessages calls PatchMessagesFunc.
+func (mock *DatabaseMock) PatchMessages(patches []model.Patch, ctx context.Context) ([]model.BatchResult, error) {
+	if mock.PatchMessagesFunc == nil {
+		panic("DatabaseMock.PatchMessagesFunc: method is nil but Database.PatchMessages was just called")
+	}
+	callInfo := struct {
+		Patches []model.Patch
+		Ctx     context.Context
+	}{
+		Patches: patches,
+		Ctx:     ctx,
+	}
+	mock.lockPatchMessages.Lock()
+	mock.calls.PatchMessages = append(mock.calls.PatchMessages, callInfo)
+	mock.lockPatchMessages.Unlock()
+	return mock.PatchMessagesFunc(patches, ctx)
+}
+
+// PatchMessagesCalls gets all the calls that were made to PatchMessages.
+// Check the length with:
+//
+//	len(mockedDatabase.PatchMessagesCalls())
+func (mock *DatabaseMock) PatchMessagesCalls() []struct {
+	Patches []model.Patch
+	Ctx     context.Context
+} {
+	var calls []struct {
+		Patches []model.Patch
+		Ctx     context.Context
+	}
+	mock.lockPatchMessages.RLock()
+	calls = mock.calls.PatchMessages
+	mock.lockPatchMessages.RUnlock()
+	return calls
+}
+
+// PurgeMessages calls PurgeMessagesFunc.
+func (mock *DatabaseMock) PurgeMessages(before time.Time, ctx context.Context) (int64, error) {
+	if mock.PurgeMessagesFunc == nil {
+		panic("DatabaseMock.PurgeMessagesFunc: method is nil but Database.PurgeMessages was just called")
+	}
+	callInfo := struct {
+		Before time.Time
+		Ctx    context.Context
+	}{
+		Before: before,
+		Ctx:    ctx,
+	}
+	mock.lockPurgeMessages.Lock()
+	mock.calls.PurgeMessages = append(mock.calls.PurgeMessages, callInfo)
+	mock.lockPurgeMessages.Unlock()
+	return mock.PurgeMessagesFunc(before, ctx)
+}
+
+// PurgeMessagesCalls gets all the calls that were made to PurgeMessages.
+// Check the length with:
+//
+//	len(mockedDatabase.PurgeMessagesCalls())
+func (mock *DatabaseMock) PurgeMessagesCalls() []struct {
+	Before time.Time
+	Ctx    context.Context
+} {
+	var calls []struct {
+		Before time.Time
+		Ctx    context.Context
+	}
+	mock.lockPurgeMessages.RLock()
+	calls = mock.calls.PurgeMessages
+	mock.lockPurgeMessages.RUnlock()
+	return calls
+}
+
+// ReleaseIdempotencyKey calls ReleaseIdempotencyKeyFunc.
+func (mock *DatabaseMock) ReleaseIdempotencyKey(key string, ctx context.Context) error {
+	if mock.ReleaseIdempotencyKeyFunc == nil {
+		panic("DatabaseMock.ReleaseIdempotencyKeyFunc: method is nil but Database.ReleaseIdempotencyKey was just called")
+	}
+	callInfo := struct {
+		Key string
+		Ctx context.Context
+	}{
+		Key: key,
+		Ctx: ctx,
+	}
+	mock.lockReleaseIdempotencyKey.Lock()
+	mock.calls.ReleaseIdempotencyKey = append(mock.calls.ReleaseIdempotencyKey, callInfo)
+	mock.lockReleaseIdempotencyKey.Unlock()
+	return mock.ReleaseIdempotencyKeyFunc(key, ctx)
+}
+
+// ReleaseIdempotencyKeyCalls gets all the calls that were made to ReleaseIdempotencyKey.
+// Check the length with:
+//
+//	len(mockedDatabase.ReleaseIdempotencyKeyCalls())
+func (mock *DatabaseMock) ReleaseIdempotencyKeyCalls() []struct {
+	Key string
+	Ctx context.Context
+} {
+	var calls []struct {
+		Key string
+		Ctx context.Context
+	}
+	mock.lockReleaseIdempotencyKey.RLock()
+	calls = mock.calls.ReleaseIdempotencyKey
+	mock.lockReleaseIdempotencyKey.RUnlock()
+	return calls
+}
+
+// RestoreMessage calls RestoreMessageFunc.
+func (mock *DatabaseMock) RestoreMessage(id string, ctx context.Context) (model.Message, error) {
+	if mock.RestoreMessageFunc == nil {
+		panic("DatabaseMock.RestoreMessageFunc: method is nil but Database.RestoreMessage was just called")
+	}
+	callInfo := struct {
+		ID  string
+		Ctx context.Context
+	}{
+		ID:  id,
+		Ctx: ctx,
+	}
+	mock.lockRestoreMessage.Lock()
+	mock.calls.RestoreMessage = append(mock.calls.RestoreMessage, callInfo)
+	mock.lockRestoreMessage.Unlock()
+	return mock.RestoreMessageFunc(id, ctx)
+}
+
+// RestoreMessageCalls gets all the calls that were made to RestoreMessage.
+// Check the length with:
+//
+//	len(mockedDatabase.RestoreMessageCalls())
+func (mock *DatabaseMock) RestoreMessageCalls() []struct {
+	ID  string
+	Ctx context.Context
+} {
+	var calls []struct {
+		ID  string
+		Ctx context.Context
+	}
+	mock.lockRestoreMessage.RLock()
+	calls = mock.calls.RestoreMessage
+	mock.lockRestoreMessage.RUnlock()
+	return calls
+}
+
 // SaveMessage calls SaveMessageFunc.
 func (mock *DatabaseMock) SaveMessage(message model.Message, ctx context.Context) (model.Message, error) {
 	if mock.SaveMessageFunc == nil {
@@ -244,26 +986,94 @@ func (mock *DatabaseMock) SaveMessageCalls() []struct {
 	return calls
 }
 
+// SaveMessages calls SaveMessagesFunc.
+func (mock *DatabaseMock) SaveMessages(messages []model.Message, ctx context.Context) ([]model.BatchResult, error) {
+	if mock.SaveMessagesFunc == nil {
+		panic("DatabaseMock.SaveMessagesFunc: method is nil but Database.SaveMessages was just called")
+	}
+	callInfo := struct {
+		Messages []model.Message
+		Ctx      context.Context
+	}{
+		Messages: messages,
+		Ctx:      ctx,
+	}
+	mock.lockSaveMessages.Lock()
+	mock.calls.SaveMessages = append(mock.calls.SaveMessages, callInfo)
+	mock.lockSaveMessages.Unlock()
+	return mock.SaveMessagesFunc(messages, ctx)
+}
+
+// SaveMessagesCalls gets all the calls that were made to SaveMessages.
+// Check the length with:
+//
+//	len(mockedDatabase.SaveMessagesCalls())
+func (mock *DatabaseMock) SaveMessagesCalls() []struct {
+	Messages []model.Message
+	Ctx      context.Context
+} {
+	var calls []struct {
+		Messages []model.Message
+		Ctx      context.Context
+	}
+	mock.lockSaveMessages.RLock()
+	calls = mock.calls.SaveMessages
+	mock.lockSaveMessages.RUnlock()
+	return calls
+}
+
+// SearchMessages calls SearchMessagesFunc.
+func (mock *DatabaseMock) SearchMessages(query model.SearchQuery, ctx context.Context) ([]model.SearchHit, error) {
+	if mock.SearchMessagesFunc == nil {
+		panic("DatabaseMock.SearchMessagesFunc: method is nil but Database.SearchMessages was just called")
+	}
+	callInfo := struct {
+		Query model.SearchQuery
+		Ctx   context.Context
+	}{
+		Query: query,
+		Ctx:   ctx,
+	}
+	mock.lockSearchMessages.Lock()
+	mock.calls.SearchMessages = append(mock.calls.SearchMessages, callInfo)
+	mock.lockSearchMessages.Unlock()
+	return mock.SearchMessagesFunc(query, ctx)
+}
+
+// SearchMessagesCalls gets all the calls that were made to SearchMessages.
+// Check the length with:
+//
+//	len(mockedDatabase.SearchMessagesCalls())
+func (mock *DatabaseMock) SearchMessagesCalls() []struct {
+	Query model.SearchQuery
+	Ctx   context.Context
+} {
+	var calls []struct {
+		Query model.SearchQuery
+		Ctx   context.Context
+	}
+	mock.lockSearchMessages.RLock()
+	calls = mock.calls.SearchMessages
+	mock.lockSearchMessages.RUnlock()
+	return calls
+}
+
 // UpdateMessage calls UpdateMessageFunc.
-func (mock *DatabaseMock) UpdateMessage(id string, content string, isPalindrome bool, ctx context.Context) (model.Message, error) {
+func (mock *DatabaseMock) UpdateMessage(message model.Message, ctx context.Context) (model.Message, error) {
 	if mock.UpdateMessageFunc == nil {
 		panic("DatabaseMock.UpdateMessageFunc: method is nil but Database.UpdateMessage was just called")
 	}
 	callInfo := struct {
-		ID           string
-		Content      string
-		IsPalindrome bool
-		Ctx          context.Context
+		Message model.Message
+		Ctx     context.Context
 	}{
-		ID:           id,
-		Content:      content,
-		IsPalindrome: isPalindrome,
-		Ctx:          ctx,
+		Message: message,
+		Ctx:     ctx,
 	}
 	mock.lockUpdateMessage.Lock()
 	mock.calls.UpdateMessage = append(mock.calls.UpdateMessage, callInfo)
 	mock.lockUpdateMessage.Unlock()
-	return mock.UpdateMessageFunc(id, content, isPalindrome, ctx)
+	return mock.UpdateMessageFunc(message, ctx)
 }
 
 // UpdateMessageCalls gets all the calls that were made to UpdateMessage.
@@ -271,16 +1081,12 @@ func (mock *DatabaseMock) UpdateMessage(id string, content string, isPalindrome
 //
 //	len(mockedDatabase.UpdateMessageCalls())
 func (mock *DatabaseMock) UpdateMessageCalls() []struct {
-	ID           string
-	Content      string
-	IsPalindrome bool
-	Ctx          context.Context
+	Message model.Message
+	Ctx     context.Context
 } {
 	var calls []struct {
-		ID           string
-		Content      string
-		IsPalindrome bool
-		Ctx          context.Context
+		Message model.Message
+		Ctx     context.Context
 	}
 	mock.lockUpdateMessage.RLock()
 	calls = mock.calls.UpdateMessage